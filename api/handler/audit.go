@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+)
+
+// Formats audit records can be listed in. The JSON one is paginated, while
+// the others export every matching record at once.
+const (
+	auditFormatJSON   = "json"
+	auditFormatNDJSON = "ndjson"
+	auditFormatCSV    = "csv"
+)
+
+const mimeNDJSON = "application/x-ndjson"
+
+var auditCSVHeader = []string{
+	"id", "created_at", "user_id", "action", "resource_type", "resource_id", "origin_ip", "data",
+	"chain_seq", "prev_hash", "hash",
+}
+
+type auditListRequest struct {
+	UserID       int64  `query:"user_id" validate:"min=0"`
+	Action       string `query:"action" validate:"max=50"`
+	ResourceType string `query:"resource_type" validate:"max=50"`
+	ResourceID   int64  `query:"resource_id" validate:"min=0"`
+	OriginIP     string `query:"origin_ip" validate:"omitempty,cidr|ip"`
+	// CreatedFrom and CreatedBefore are RFC 3339 timestamps.
+	CreatedFrom   time.Time `query:"created_from"`
+	CreatedBefore time.Time `query:"created_before"`
+	Cursor        string    `query:"cursor"`
+	Limit         int       `query:"limit" validate:"min=1,max=100"`
+	Format        string    `query:"format" validate:"oneof=json ndjson csv"`
+}
+
+func newAuditListRequest() auditListRequest {
+	return auditListRequest{Limit: defaultListLimit, Format: auditFormatJSON}
+}
+
+func (r auditListRequest) filter() audit.Filter {
+	return audit.Filter{
+		UserID:        r.UserID,
+		Action:        audit.Action(r.Action),
+		ResourceType:  audit.ResourceType(r.ResourceType),
+		ResourceID:    r.ResourceID,
+		Network:       r.OriginIP,
+		CreatedFrom:   r.CreatedFrom,
+		CreatedBefore: r.CreatedBefore,
+	}
+}
+
+type auditListResponse struct {
+	Data []entity.AuditRecord `json:"data"`
+	// NextCursor is to be sent back as the cursor to get the next page of
+	// records, it's null on the last one.
+	NextCursor *string `json:"next_cursor"`
+}
+
+type Audit struct {
+	Handler
+	uc usecase.Audit
+}
+
+func NewAudit(uc usecase.Audit) Audit {
+	return Audit{
+		Handler: New(),
+		uc:      uc,
+	}
+}
+
+// List replies with the audit records matching the given filters, newest
+// first. Meant for admins only.
+func (a Audit) List(c echo.Context) error {
+	lr := newAuditListRequest()
+	if err := a.BindAndValidate(c, &lr); err != nil {
+		return err
+	}
+
+	switch lr.Format {
+	case auditFormatNDJSON:
+		return a.exportNDJSON(c, lr)
+	case auditFormatCSV:
+		return a.exportCSV(c, lr)
+	}
+
+	page, err := a.uc.List(c.Request().Context(), lr.filter(), lr.Cursor, lr.Limit)
+	if err != nil {
+		return err
+	}
+
+	res := auditListResponse{Data: page.Rows}
+	if res.Data == nil {
+		res.Data = []entity.AuditRecord{}
+	}
+	if page.NextCursor != "" {
+		res.NextCursor = &page.NextCursor
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// exportNDJSON streams every matching record as a JSON object per line.
+func (a Audit) exportNDJSON(c echo.Context, lr auditListRequest) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeNDJSON)
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	return a.uc.Export(c.Request().Context(), lr.filter(), lr.Cursor, func(ar entity.AuditRecord) error {
+		return enc.Encode(ar)
+	})
+}
+
+// exportCSV streams every matching record as a CSV row, preceded by a header.
+func (a Audit) exportCSV(c echo.Context, lr auditListRequest) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-records.csv"`)
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	if err := w.Write(auditCSVHeader); err != nil {
+		return err
+	}
+
+	err := a.uc.Export(c.Request().Context(), lr.filter(), lr.Cursor, func(ar entity.AuditRecord) error {
+		return w.Write([]string{
+			strconv.FormatInt(ar.ID, 10),
+			ar.CreatedAt.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(ar.UserID, 10),
+			ar.Action.String(),
+			ar.ResourceType.String(),
+			strconv.FormatInt(ar.ResourceID, 10),
+			ar.OriginIP,
+			string(ar.Data),
+			chainSeq(ar),
+			ar.PrevHash,
+			ar.Hash,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// chainSeq leaves the position of records not chained yet blank.
+func chainSeq(ar entity.AuditRecord) string {
+	if ar.ChainSeq == 0 {
+		return ""
+	}
+	return strconv.FormatInt(ar.ChainSeq, 10)
+}
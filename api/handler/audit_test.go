@@ -0,0 +1,243 @@
+//go:build unit
+// +build unit
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditList(t *testing.T) {
+	e := httpserver.New()
+
+	records := []entity.AuditRecord{
+		{
+			ID:           2,
+			Action:       audit.ActionCancelRide,
+			CreatedAt:    time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+			Data:         json.RawMessage(`{"stripe_refund_id":"re_123"}`),
+			OriginIP:     "10.0.0.2/32",
+			ResourceID:   7,
+			ResourceType: audit.ResourceTypeRide,
+			UserID:       1,
+			PrevHash:     "aa",
+			Hash:         "bb",
+			ChainSeq:     2,
+		},
+		{
+			ID:           1,
+			Action:       audit.ActionCreateRide,
+			CreatedAt:    time.Date(2022, 1, 1, 3, 4, 5, 0, time.UTC),
+			Data:         json.RawMessage(`{"origin_lat":0}`),
+			OriginIP:     "10.0.0.1/32",
+			ResourceID:   7,
+			ResourceType: audit.ResourceTypeRide,
+			UserID:       1,
+			Hash:         "aa",
+			ChainSeq:     1,
+		},
+	}
+
+	newContext := func(query string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit-records?"+query, nil)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	exportRecords := func(args mock.Arguments) {
+		fn, ok := args.Get(3).(func(entity.AuditRecord) error)
+		require.True(t, ok)
+		for _, ar := range records {
+			require.NoError(t, fn(ar))
+		}
+	}
+
+	t.Run("Invalid params", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+
+		for _, query := range []string{
+			"user_id=-1",
+			"user_id=foo",
+			"resource_id=-1",
+			"origin_ip=10.0.0.0/33",
+			"origin_ip=foo",
+			"created_from=yesterday",
+			"limit=0",
+			"limit=101",
+			"format=xml",
+		} {
+			c, _ := newContext(query)
+
+			err := handler.List(c)
+
+			var he *echo.HTTPError
+			if assert.ErrorAs(t, err, &he, query) {
+				assert.Equal(t, http.StatusBadRequest, he.Code, query)
+			}
+		}
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("Error on List", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+		expErr := errors.New("error List")
+
+		uc.On("List", mock.Anything, audit.Filter{}, "", defaultListLimit).
+			Once().
+			Return(data.Page[entity.AuditRecord]{}, expErr)
+
+		c, _ := newContext("")
+
+		err := handler.List(c)
+		assert.Equal(t, expErr, err)
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("Filtered page", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+
+		filter := audit.Filter{
+			UserID:        1,
+			Action:        audit.ActionCreateRide,
+			ResourceType:  audit.ResourceTypeRide,
+			ResourceID:    7,
+			Network:       "10.0.0.0/8",
+			CreatedFrom:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+		}
+		uc.On("List", mock.Anything, filter, "cursor", 2).
+			Once().
+			Return(data.Page[entity.AuditRecord]{Rows: records, NextCursor: "next"}, nil)
+
+		c, rec := newContext(strings.Join([]string{
+			"user_id=1",
+			"action=CREATE_RIDE",
+			"resource_type=RIDE",
+			"resource_id=7",
+			"origin_ip=10.0.0.0/8",
+			"created_from=2022-01-01T00:00:00Z",
+			"created_before=2022-02-01T00:00:00Z",
+			"cursor=cursor",
+			"limit=2",
+		}, "&"))
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var res auditListResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, records, res.Data)
+			if assert.NotNil(t, res.NextCursor) {
+				assert.Equal(t, "next", *res.NextCursor)
+			}
+		}
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("Empty last page", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+
+		uc.On("List", mock.Anything, audit.Filter{Network: "10.0.0.1"}, "", defaultListLimit).
+			Once().
+			Return(data.Page[entity.AuditRecord]{}, nil)
+
+		c, rec := newContext("origin_ip=10.0.0.1")
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"data": [], "next_cursor": null}`, rec.Body.String())
+		}
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("NDJSON export", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+
+		uc.On("Export", mock.Anything, audit.Filter{UserID: 1}, "", mock.Anything).
+			Once().
+			Return(nil).
+			Run(exportRecords)
+
+		c, rec := newContext("user_id=1&format=ndjson")
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, mimeNDJSON, rec.Header().Get(echo.HeaderContentType))
+
+			lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+			if assert.Len(t, lines, len(records)) {
+				for i, line := range lines {
+					var ar entity.AuditRecord
+					require.NoError(t, json.Unmarshal([]byte(line), &ar))
+					assert.Equal(t, records[i], ar)
+				}
+			}
+		}
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("CSV export", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+
+		uc.On("Export", mock.Anything, audit.Filter{}, "", mock.Anything).
+			Once().
+			Return(nil).
+			Run(exportRecords)
+
+		c, rec := newContext("format=csv")
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "audit-records.csv")
+
+			expected := "id,created_at,user_id,action,resource_type,resource_id,origin_ip,data,chain_seq,prev_hash,hash\n" +
+				`2,2022-01-02T03:04:05Z,1,CANCEL_RIDE,RIDE,7,10.0.0.2/32,"{""stripe_refund_id"":""re_123""}",2,aa,bb` + "\n" +
+				`1,2022-01-01T03:04:05Z,1,CREATE_RIDE,RIDE,7,10.0.0.1/32,"{""origin_lat"":0}",1,,aa` + "\n"
+			assert.Equal(t, expected, rec.Body.String())
+		}
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("Error on Export", func(t *testing.T) {
+		uc := &mocks.Audit{}
+		handler := NewAudit(uc)
+		expErr := errors.New("error Export")
+
+		uc.On("Export", mock.Anything, audit.Filter{}, "", mock.Anything).
+			Once().
+			Return(expErr)
+
+		c, _ := newContext("format=csv")
+
+		err := handler.List(c)
+		assert.Equal(t, expErr, err)
+		uc.AssertExpectations(t)
+	})
+}
@@ -29,6 +29,16 @@ func (h Handler) BindAndValidate(c echo.Context, i interface{}) error {
 		return nil
 	}
 
+	if err := h.binder.BindPathParams(c, i); err != nil {
+		return err
+	}
+
+	if c.Request().Method == http.MethodGet {
+		if err := h.binder.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+
 	if err := h.binder.BindHeaders(c, i); err != nil {
 		return err
 	}
@@ -43,16 +53,23 @@ func (h Handler) BindAndValidate(c echo.Context, i interface{}) error {
 	return nil
 }
 
-func (h Handler) IdempotencyKey(c echo.Context) (ik entity.IdempotencyKey, err error) {
+// User returns the authenticated user making the request.
+func (h Handler) User(c echo.Context) (user entity.User, err error) {
 	user, ok := context.GetUser(c)
 	if !ok {
 		err = echo.NewHTTPError(http.StatusUnauthorized, entity.ErrPermissionDenied.Error())
+	}
+	return
+}
+
+func (h Handler) IdempotencyKey(c echo.Context) (ik entity.IdempotencyKey, err error) {
+	user, err := h.User(c)
+	if err != nil {
 		return
 	}
 
-	ik, ok = context.GetIdemKey(c)
-	if !ok {
-		err = echo.NewHTTPError(http.StatusBadRequest, "missing idempotency-key")
+	ik, err = h.AnonymousIdempotencyKey(c)
+	if err != nil {
 		return
 	}
 
@@ -60,3 +77,24 @@ func (h Handler) IdempotencyKey(c echo.Context) (ik entity.IdempotencyKey, err e
 	ik.User = &user
 	return
 }
+
+// AnonymousIdempotencyKey returns the key sent along with requests made by no
+// user at all, such as signups.
+func (h Handler) AnonymousIdempotencyKey(c echo.Context) (ik entity.IdempotencyKey, err error) {
+	ik, ok := context.GetIdemKey(c)
+	if !ok {
+		err = echo.NewHTTPError(http.StatusBadRequest, "missing idempotency-key")
+	}
+	return
+}
+
+// Respond writes the response stored on a finished idempotency key, which must
+// hold both a response code and body. Both the first call and its retries go
+// through here, so that they get byte-identical payloads and the same headers.
+func Respond(c echo.Context, ik entity.IdempotencyKey) error {
+	for k, v := range ik.ResponseHeaders {
+		c.Response().Header().Set(k, v)
+	}
+
+	return c.JSONBlob(int(*ik.ResponseCode), ik.ResponseBody)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"errors"
 	"math"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
@@ -25,6 +26,28 @@ func newCreateRequest() createRequest {
 	}
 }
 
+type rideRequest struct {
+	ID int64 `param:"id" validate:"min=1"`
+}
+
+type listRequest struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+}
+
+func newListRequest() listRequest {
+	return listRequest{Limit: defaultListLimit}
+}
+
+const defaultListLimit = 20
+
+type listResponse struct {
+	Data []entity.Ride `json:"data"`
+	// NextCursor is to be sent back as the cursor to get the next page of
+	// rides, it's null on the last one.
+	NextCursor *string `json:"next_cursor"`
+}
+
 type Ride struct {
 	Handler
 	uc usecase.Ride
@@ -64,5 +87,78 @@ func (r Ride) Create(c echo.Context) error {
 		return errors.New("create ride: invalid response")
 	}
 
-	return c.JSON(int(*ik.ResponseCode), ik.ResponseBody)
+	return Respond(c, ik)
+}
+
+// Cancel cancels one of the user's rides, replying with the ride once its
+// charge is refunded.
+func (r Ride) Cancel(c echo.Context) error {
+	ik, err := r.IdempotencyKey(c)
+	if err != nil {
+		return err
+	}
+
+	rr := rideRequest{}
+	if err := r.BindAndValidate(c, &rr); err != nil {
+		return err
+	}
+
+	err = r.uc.Cancel(c.Request().Context(), &ik, rr.ID)
+	if err != nil {
+		return err
+	}
+
+	if ik.ResponseCode == nil || ik.ResponseBody == nil {
+		return errors.New("cancel ride: invalid response")
+	}
+
+	return Respond(c, ik)
+}
+
+// Get replies with one of the user's rides.
+func (r Ride) Get(c echo.Context) error {
+	user, err := r.User(c)
+	if err != nil {
+		return err
+	}
+
+	rr := rideRequest{}
+	if err := r.BindAndValidate(c, &rr); err != nil {
+		return err
+	}
+
+	rd, err := r.uc.Get(c.Request().Context(), user.ID, rr.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rd)
+}
+
+// List replies with a page of the user's rides, newest first.
+func (r Ride) List(c echo.Context) error {
+	user, err := r.User(c)
+	if err != nil {
+		return err
+	}
+
+	lr := newListRequest()
+	if err := r.BindAndValidate(c, &lr); err != nil {
+		return err
+	}
+
+	page, err := r.uc.List(c.Request().Context(), user.ID, lr.Cursor, lr.Limit)
+	if err != nil {
+		return err
+	}
+
+	res := listResponse{Data: page.Rows}
+	if res.Data == nil {
+		res.Data = []entity.Ride{}
+	}
+	if page.NextCursor != "" {
+		res.NextCursor = &page.NextCursor
+	}
+
+	return c.JSON(http.StatusOK, res)
 }
@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/labstack/echo/v4"
@@ -18,10 +19,10 @@ import (
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
 	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"github.com/stretchr/testify/require"
 )
 
 func TestCreateValidate(t *testing.T) {
@@ -62,13 +63,13 @@ func TestCreateValidate(t *testing.T) {
 	for _, tc := range tests {
 		if !tc.fail {
 			rCode := idempotency.ResponseCodeOK
-			rBody := idempotency.ResponseBody{Message: "ok"}
+			rBody := json.RawMessage(`{"message":"ok"}`)
 
 			uc.On("Create", callArgs...).Once().Return(nil).Run(func(args mock.Arguments) {
 				arg, ok := args.Get(1).(*entity.IdempotencyKey)
 				assert.True(t, ok)
 				arg.ResponseCode = &rCode
-				arg.ResponseBody = &rBody
+				arg.ResponseBody = rBody
 			})
 		}
 
@@ -94,7 +95,7 @@ func TestCreateValidate(t *testing.T) {
 		} else {
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, rec.Code)
-			assert.Equal(t, "{\"message\":\"ok\"}\n", rec.Body.String())
+			assert.Equal(t, `{"message":"ok"}`, rec.Body.String())
 		}
 	}
 }
@@ -133,7 +134,7 @@ func TestCreate(t *testing.T) {
 	t.Run("Error on create ride", func(t *testing.T) {
 		payload := `{"origin_lat": 0.0, "origin_lon": 0.0, "target_lat": 0.0, "target_lon": 0.0}`
 		rCode := idempotency.ResponseCodeOK
-		rBody := idempotency.ResponseBody{Message: "filled"}
+		rBody := json.RawMessage(`{"message":"filled"}`)
 		tests := []struct {
 			desc     string
 			retError error
@@ -170,7 +171,7 @@ func TestCreate(t *testing.T) {
 				retFunc: func(args mock.Arguments) {
 					arg, ok := args.Get(1).(*entity.IdempotencyKey)
 					assert.True(t, ok)
-					arg.ResponseBody = &rBody
+					arg.ResponseBody = rBody
 				},
 			},
 			{
@@ -207,15 +208,16 @@ func TestCreate(t *testing.T) {
 	t.Run("Success on create ride", func(t *testing.T) {
 		payload := `{"origin_lat": 0.0, "origin_lon": 0.0, "target_lat": 0.0, "target_lon": 0.0}`
 		rCode := idempotency.ResponseCodeOK
-		rBody := idempotency.ResponseBody{Message: gofakeit.UUID()}
-		body, err := json.Marshal(rBody)
-		require.NoError(t, err)
+		// formatting is kept as it is, so that replays are byte-identical
+		rBody := json.RawMessage(fmt.Sprintf(`{ "id": %v,  "user_id": 1 }`, gofakeit.Number(1, 1000)))
+		location := fmt.Sprintf("/rides/%v", gofakeit.Number(1, 1000))
 
 		uc.On("Create", callArgs...).Once().Return(nil).Run(func(args mock.Arguments) {
 			arg, ok := args.Get(1).(*entity.IdempotencyKey)
 			assert.True(t, ok)
 			arg.ResponseCode = &rCode
-			arg.ResponseBody = &rBody
+			arg.ResponseBody = rBody
+			arg.ResponseHeaders = idempotency.ResponseHeaders{echo.HeaderLocation: location}
 		})
 
 		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
@@ -227,11 +229,291 @@ func TestCreate(t *testing.T) {
 		context.AddUser(c, entity.User{})
 		context.AddIdemKey(c, entity.IdempotencyKey{})
 
-		err = handler.Create(c)
+		err := handler.Create(c)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, string(rBody), rec.Body.String())
+			assert.Equal(t, location, rec.Header().Get(echo.HeaderLocation))
+		}
+	})
+}
+
+func TestGet(t *testing.T) {
+	e := echo.New()
+	uc := &mocks.Ride{}
+
+	handler := NewRide(uc)
+	user := entity.User{ID: int64(gofakeit.Number(1, 1000))}
+
+	newContext := func(id string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/rides/"+id, nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetPath("/rides/:id")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("User info not found", func(t *testing.T) {
+		c, _ := newContext("1")
+
+		err := handler.Get(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusUnauthorized, he.Code)
+		}
+	})
+
+	t.Run("Invalid ride id", func(t *testing.T) {
+		for _, id := range []string{"foo", "0", "-1"} {
+			c, _ := newContext(id)
+			context.AddUser(c, user)
+
+			err := handler.Get(c)
+
+			var he *echo.HTTPError
+			if assert.ErrorAs(t, err, &he) {
+				assert.Equal(t, http.StatusBadRequest, he.Code)
+			}
+		}
+	})
+
+	t.Run("Ride not found", func(t *testing.T) {
+		uc.On("Get", mock.Anything, user.ID, int64(2)).
+			Once().
+			Return(entity.Ride{}, entity.ErrNotFound)
+
+		c, _ := newContext("2")
+		context.AddUser(c, user)
+
+		err := handler.Get(c)
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+	})
+
+	t.Run("Success on get ride", func(t *testing.T) {
+		chargeID := gofakeit.UUID()
+		rd := entity.Ride{
+			ID:             3,
+			CreatedAt:      time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+			Amount:         2000,
+			Currency:       "usd",
+			StripeChargeID: &chargeID,
+			UserID:         user.ID,
+		}
+
+		uc.On("Get", mock.Anything, user.ID, int64(3)).
+			Once().
+			Return(rd, nil)
+
+		c, rec := newContext("3")
+		context.AddUser(c, user)
+
+		err := handler.Get(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var res map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, chargeID, res["stripe_charge_id"])
+			assert.Equal(t, "2022-01-02T03:04:05Z", res["created_at"])
+			assert.NotContains(t, res, "IdempotencyKeyID")
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	e := echo.New()
+	uc := &mocks.Ride{}
+
+	handler := NewRide(uc)
+	user := entity.User{ID: int64(gofakeit.Number(1, 1000))}
+
+	newContext := func(target string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("User info not found", func(t *testing.T) {
+		c, _ := newContext("/rides")
+
+		err := handler.List(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusUnauthorized, he.Code)
+		}
+	})
+
+	t.Run("Invalid query params", func(t *testing.T) {
+		for _, q := range []string{"limit=0", "limit=101", "limit=foo"} {
+			c, _ := newContext("/rides?" + q)
+			context.AddUser(c, user)
+
+			err := handler.List(c)
+
+			var he *echo.HTTPError
+			if assert.ErrorAs(t, err, &he, q) {
+				assert.Equal(t, http.StatusBadRequest, he.Code, q)
+			}
+		}
+	})
+
+	t.Run("Error on list rides", func(t *testing.T) {
+		expErr := errors.New("error List")
+		uc.On("List", mock.Anything, user.ID, "", defaultListLimit).
+			Once().
+			Return(data.Page[entity.Ride]{}, expErr)
+
+		c, _ := newContext("/rides")
+		context.AddUser(c, user)
+
+		err := handler.List(c)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Empty list", func(t *testing.T) {
+		uc.On("List", mock.Anything, user.ID, "", defaultListLimit).
+			Once().
+			Return(data.Page[entity.Ride]{}, nil)
+
+		c, rec := newContext("/rides")
+		context.AddUser(c, user)
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"data": [], "next_cursor": null}`, rec.Body.String())
+		}
+	})
+
+	t.Run("Success on list rides", func(t *testing.T) {
+		rides := []entity.Ride{{ID: 9, UserID: user.ID}, {ID: 7, UserID: user.ID}}
+		uc.On("List", mock.Anything, user.ID, "cursor", 2).
+			Once().
+			Return(data.Page[entity.Ride]{Rows: rides, NextCursor: "next"}, nil)
+
+		c, rec := newContext("/rides?cursor=cursor&limit=2")
+		context.AddUser(c, user)
+
+		err := handler.List(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var res struct {
+				Data       []entity.Ride `json:"data"`
+				NextCursor *string       `json:"next_cursor"`
+			}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Len(t, res.Data, 2)
+			if assert.NotNil(t, res.NextCursor) {
+				assert.Equal(t, "next", *res.NextCursor)
+			}
+		}
+	})
+
+	uc.AssertExpectations(t)
+}
+
+func TestCancel(t *testing.T) {
+	e := echo.New()
+	uc := &mocks.Ride{}
+
+	handler := NewRide(uc)
+	user := entity.User{ID: int64(gofakeit.Number(1, 1000))}
+
+	newContext := func(id string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/rides/"+id+"/cancel", nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetPath("/rides/:id/cancel")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("User info not found", func(t *testing.T) {
+		c, _ := newContext("1")
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Cancel(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusUnauthorized, he.Code)
+		}
+	})
+
+	t.Run("Invalid ride id", func(t *testing.T) {
+		c, _ := newContext("foo")
+		context.AddUser(c, user)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Cancel(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+		}
+	})
+
+	t.Run("Error on cancel ride", func(t *testing.T) {
+		retErr := entity.ErrIdemKeyRequestInProgress
+		uc.On("Cancel", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), int64(2)).
+			Once().
+			Return(retErr)
+
+		c, _ := newContext("2")
+		context.AddUser(c, user)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
 
+		err := handler.Cancel(c)
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Empty idempotency key response", func(t *testing.T) {
+		uc.On("Cancel", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), int64(3)).
+			Once().
+			Return(nil)
+
+		c, _ := newContext("3")
+		context.AddUser(c, user)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Cancel(c)
+		assert.EqualError(t, err, "cancel ride: invalid response")
+	})
+
+	t.Run("Success on cancel ride", func(t *testing.T) {
+		rCode := idempotency.ResponseCodeOK
+		rBody := json.RawMessage(`{"id": 4, "stripe_refund_id": "re_123"}`)
+
+		uc.On("Cancel", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), int64(4)).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				arg, ok := args.Get(1).(*entity.IdempotencyKey)
+				assert.True(t, ok)
+				assert.Equal(t, user.ID, arg.UserID)
+				arg.ResponseCode = &rCode
+				arg.ResponseBody = rBody
+			})
+
+		c, rec := newContext("4")
+		context.AddUser(c, user)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Cancel(c)
 		if assert.NoError(t, err) {
 			assert.Equal(t, http.StatusOK, rec.Code)
-			assert.Contains(t, rec.Body.String(), string(body))
+			assert.Equal(t, string(rBody), rec.Body.String())
 		}
 	})
+
+	uc.AssertExpectations(t)
 }
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+)
+
+type signupRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type User struct {
+	Handler
+	uc usecase.User
+}
+
+func NewUser(uc usecase.User) User {
+	return User{
+		Handler: New(),
+		uc:      uc,
+	}
+}
+
+// Create signs a new user up, no authentication needed.
+func (u User) Create(c echo.Context) error {
+	ik, err := u.AnonymousIdempotencyKey(c)
+	if err != nil {
+		return err
+	}
+
+	sr := signupRequest{}
+	if err := u.BindAndValidate(c, &sr); err != nil {
+		return err
+	}
+
+	usr := &entity.User{Email: sr.Email}
+
+	err = u.uc.Create(c.Request().Context(), &ik, usr)
+	if err != nil {
+		return err
+	}
+
+	if ik.ResponseCode == nil || ik.ResponseBody == nil {
+		return errors.New("create user: invalid response")
+	}
+
+	return Respond(c, ik)
+}
@@ -0,0 +1,121 @@
+//go:build unit
+// +build unit
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSignup(t *testing.T) {
+	e := httpserver.New()
+	uc := &mocks.User{}
+
+	handler := NewUser(uc)
+
+	newContext := func(payload string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(payload))
+		rec := httptest.NewRecorder()
+
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Idempotency key not found", func(t *testing.T) {
+		c, _ := newContext(`{"email": "jane@example.com"}`)
+
+		err := handler.Create(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+		}
+	})
+
+	t.Run("Invalid email", func(t *testing.T) {
+		for _, payload := range []string{`{}`, `{"email": ""}`, `{"email": "jane"}`} {
+			c, _ := newContext(payload)
+			context.AddIdemKey(c, entity.IdempotencyKey{})
+
+			err := handler.Create(c)
+
+			var he *echo.HTTPError
+			if assert.ErrorAs(t, err, &he, payload) {
+				assert.Equal(t, http.StatusBadRequest, he.Code, payload)
+			}
+		}
+	})
+
+	t.Run("Error on create user", func(t *testing.T) {
+		retErr := entity.ErrIdemKeyRequestInProgress
+		uc.On("Create", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(retErr)
+
+		c, _ := newContext(`{"email": "jane@example.com"}`)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Create(c)
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Empty idempotency key response", func(t *testing.T) {
+		uc.On("Create", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil)
+
+		c, _ := newContext(`{"email": "jane@example.com"}`)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Create(c)
+		assert.EqualError(t, err, "create user: invalid response")
+	})
+
+	t.Run("Success on create user", func(t *testing.T) {
+		rCode := idempotency.ResponseCodeOK
+		rBody := json.RawMessage(`{"id": 1, "email": "jane@example.com"}`)
+
+		uc.On("Create", mock.Anything, mock.AnythingOfType("*entity.IdempotencyKey"), mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				ik, ok := args.Get(1).(*entity.IdempotencyKey)
+				assert.True(t, ok)
+				assert.Zero(t, ik.UserID)
+				assert.Nil(t, ik.User)
+
+				usr, ok := args.Get(2).(*entity.User)
+				assert.True(t, ok)
+				assert.Equal(t, "jane@example.com", usr.Email)
+
+				ik.ResponseCode = &rCode
+				ik.ResponseBody = rBody
+				ik.ResponseHeaders = idempotency.ResponseHeaders{echo.HeaderLocation: "/users/1"}
+			})
+
+		c, rec := newContext(`{"email": "jane@example.com"}`)
+		context.AddIdemKey(c, entity.IdempotencyKey{})
+
+		err := handler.Create(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "/users/1", rec.Header().Get(echo.HeaderLocation))
+			assert.Equal(t, string(rBody), rec.Body.String())
+		}
+	})
+
+	uc.AssertExpectations(t)
+}
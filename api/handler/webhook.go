@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+)
+
+// HeaderStripeSignature carries the signature of webhooks sent by Stripe.
+const HeaderStripeSignature = "Stripe-Signature"
+
+// maxWebhookBytes caps the size of webhook payloads, which Stripe keeps well
+// below it.
+const maxWebhookBytes = 64 * 1024
+
+type Webhook struct {
+	secret string
+	uc     usecase.Webhook
+}
+
+func NewWebhook(cfg config.Config, uc usecase.Webhook) Webhook {
+	return Webhook{
+		secret: cfg.StripeWebhookSecret,
+		uc:     uc,
+	}
+}
+
+// Stripe receives the events sent by Stripe, which are only accepted when
+// signed with the configured webhook secret. The payload must be read as is,
+// since the signature is computed over its exact bytes.
+func (w Webhook) Stripe(c echo.Context) error {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxWebhookBytes))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook payload")
+	}
+
+	evt, err := payment.ParseWebhook(payload, c.Request().Header.Get(HeaderStripeSignature), w.secret)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := w.uc.HandleStripeEvent(c.Request().Context(), evt); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,131 @@
+//go:build unit
+// +build unit
+
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStripeWebhook(t *testing.T) {
+	e := httpserver.New()
+	secret := "whsec_test"
+	payload := []byte(`{"id": "evt_123", "type": "charge.refunded", "data": {"object": {"id": "ch_123"}}}`)
+
+	newContext := func(body []byte, signature string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/stripe", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if signature != "" {
+			req.Header.Set(HeaderStripeSignature, signature)
+		}
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Invalid signatures", func(t *testing.T) {
+		uc := &mocks.Webhook{}
+		handler := NewWebhook(config.Config{StripeWebhookSecret: secret}, uc)
+
+		for desc, sig := range map[string]string{
+			"missing signature": "",
+			"other secret":      payment.SignWebhook(payload, "whsec_other", time.Now()),
+			"stale signature":   payment.SignWebhook(payload, secret, time.Now().Add(-time.Hour)),
+		} {
+			c, _ := newContext(payload, sig)
+
+			err := handler.Stripe(c)
+
+			var he *echo.HTTPError
+			if assert.ErrorAs(t, err, &he, desc) {
+				assert.Equal(t, http.StatusBadRequest, he.Code, desc)
+			}
+		}
+		uc.AssertNotCalled(t, "HandleStripeEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("No secret configured", func(t *testing.T) {
+		uc := &mocks.Webhook{}
+		handler := NewWebhook(config.Config{}, uc)
+
+		c, _ := newContext(payload, payment.SignWebhook(payload, "", time.Now()))
+
+		err := handler.Stripe(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+		}
+		uc.AssertNotCalled(t, "HandleStripeEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Payload too large", func(t *testing.T) {
+		uc := &mocks.Webhook{}
+		handler := NewWebhook(config.Config{StripeWebhookSecret: secret}, uc)
+
+		large := bytes.Repeat([]byte(" "), maxWebhookBytes+1)
+		c, _ := newContext(large, payment.SignWebhook(large, secret, time.Now()))
+
+		err := handler.Stripe(c)
+
+		var he *echo.HTTPError
+		if assert.ErrorAs(t, err, &he) {
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+		}
+		uc.AssertNotCalled(t, "HandleStripeEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on HandleStripeEvent", func(t *testing.T) {
+		retErr := errors.New("err HandleStripeEvent")
+
+		uc := &mocks.Webhook{}
+		handler := NewWebhook(config.Config{StripeWebhookSecret: secret}, uc)
+
+		uc.On("HandleStripeEvent", mock.Anything, mock.AnythingOfType("payment.Event")).
+			Once().
+			Return(retErr)
+
+		c, _ := newContext(payload, payment.SignWebhook(payload, secret, time.Now()))
+
+		err := handler.Stripe(c)
+		assert.Equal(t, retErr, err)
+		uc.AssertExpectations(t)
+	})
+
+	t.Run("Success on HandleStripeEvent", func(t *testing.T) {
+		uc := &mocks.Webhook{}
+		handler := NewWebhook(config.Config{StripeWebhookSecret: secret}, uc)
+
+		uc.On("HandleStripeEvent", mock.Anything, mock.AnythingOfType("payment.Event")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				evt, ok := args.Get(1).(payment.Event)
+				assert.True(t, ok)
+				assert.Equal(t, "evt_123", evt.ID)
+				assert.Equal(t, payment.EventChargeRefunded, evt.Type)
+				assert.Equal(t, payload, []byte(evt.Payload))
+			})
+
+		c, rec := newContext(payload, payment.SignWebhook(payload, secret, time.Now()))
+
+		err := handler.Stripe(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		uc.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+)
+
+// Admin only lets admins through, so it must come after the User middleware.
+// Requests made by any other user are forbidden.
+func Admin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := context.GetUser(c)
+			if !ok {
+				return unauthorized(c, "")
+			}
+
+			if !user.IsAdmin {
+				return echo.NewHTTPError(http.StatusForbidden, entity.ErrPermissionDenied.Error())
+			}
+
+			return next(c)
+		}
+	}
+}
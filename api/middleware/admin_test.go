@@ -0,0 +1,56 @@
+//go:build unit
+// +build unit
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	tests := []struct {
+		desc string
+		user *entity.User
+		ret  int
+	}{
+		{desc: "no user", ret: http.StatusUnauthorized},
+		{desc: "regular user", user: &entity.User{ID: int64(gofakeit.Number(1, 1000))}, ret: http.StatusForbidden},
+		{desc: "admin", user: &entity.User{ID: int64(gofakeit.Number(1, 1000)), IsAdmin: true}, ret: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		var called bool
+
+		e := httpserver.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if tc.user != nil {
+					context.AddUser(c, *tc.user)
+				}
+				return next(c)
+			}
+		})
+		e.Use(Admin())
+		e.GET("/admin", func(c echo.Context) error {
+			called = true
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.ret, rec.Code, tc.desc)
+		assert.Equal(t, tc.ret == http.StatusOK, called, tc.desc)
+	}
+}
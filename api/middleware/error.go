@@ -6,6 +6,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 )
 
 func ErrorMapper() echo.MiddlewareFunc {
@@ -17,8 +18,14 @@ func ErrorMapper() echo.MiddlewareFunc {
 			}
 
 			switch {
+			case errors.Is(err, entity.ErrNotFound):
+				err = echo.NewHTTPError(http.StatusNotFound, err.Error())
 			case errors.Is(err, entity.ErrIdemKeyParamsMismatch) || errors.Is(err, entity.ErrIdemKeyRequestInProgress):
 				err = echo.NewHTTPError(http.StatusConflict, err.Error())
+			case errors.Is(err, data.ErrInvalidCursor):
+				err = echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case errors.Is(err, data.ErrStaleRecord):
+				err = echo.NewHTTPError(http.StatusConflict, err.Error())
 			case errors.Is(err, entity.ErrPaymentProvider):
 				err = echo.NewHTTPError(http.StatusPaymentRequired, err.Error())
 			case errors.Is(err, entity.ErrPaymentProviderGeneric):
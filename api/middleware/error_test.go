@@ -0,0 +1,54 @@
+//go:build unit
+// +build unit
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMapper(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		ret  int
+	}{
+		{desc: "no error", ret: http.StatusOK},
+		{desc: "not found", err: entity.ErrNotFound, ret: http.StatusNotFound},
+		{desc: "params mismatch", err: entity.ErrIdemKeyBodyMismatch, ret: http.StatusConflict},
+		{desc: "request in progress", err: entity.ErrIdemKeyRequestInProgress, ret: http.StatusConflict},
+		{desc: "invalid cursor", err: data.ErrInvalidCursor, ret: http.StatusBadRequest},
+		{desc: "stale record", err: fmt.Errorf("update ride: %w", data.ErrStaleRecord), ret: http.StatusConflict},
+		{desc: "payment provider", err: entity.ErrPaymentProvider, ret: http.StatusPaymentRequired},
+		{desc: "payment provider generic", err: entity.ErrPaymentProviderGeneric, ret: http.StatusServiceUnavailable},
+		{desc: "unknown", err: errors.New("err unknown"), ret: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		e := httpserver.New()
+		e.Use(ErrorMapper())
+		e.GET("/", func(c echo.Context) error {
+			if tc.err != nil {
+				return tc.err
+			}
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.ret, rec.Code, tc.desc)
+	}
+}
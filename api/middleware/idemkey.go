@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -9,14 +10,26 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/api/handler"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
 )
 
+// HeaderIdempotentReplayed flags responses replayed from a finished key.
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
 type idemKeyRequest struct {
 	IdemKey string `header:"idempotency-key" validate:"required,max=100"`
 }
 
-func IdempotencyKey() echo.MiddlewareFunc {
+// IdempotencyKey reads the key sent along with the request. It must run after
+// the User middleware (unless the route needs no authentication), so that
+// requests retried with an already finished key are answered with the stored
+// response without ever reaching the use case.
+func IdempotencyKey(store datastore.IdempotencyKey) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			binder := &echo.DefaultBinder{}
@@ -39,11 +52,38 @@ func IdempotencyKey() echo.MiddlewareFunc {
 			// Restore the io.ReadCloser to it's original state
 			c.Request().Body = ioutil.NopCloser(bytes.NewBuffer(rawBody))
 
+			// Request params are stored as JSON, so requests without a body
+			// (e.g. cancellations) get an empty object instead.
+			params := rawBody
+			if len(bytes.TrimSpace(params)) == 0 {
+				params = []byte("{}")
+			}
+
 			ik := entity.IdempotencyKey{
 				IdempotencyKey: ikr.IdemKey,
 				RequestMethod:  c.Request().Method,
 				RequestPath:    c.Request().RequestURI,
-				RequestParams:  rawBody,
+				RequestParams:  params,
+			}
+			ik.RequestFingerprint = idemkey.Fingerprint(ik.RequestMethod, ik.RequestPath, ik.RequestParams)
+
+			// requests made by no user, such as signups, have anonymous keys
+			user, _ := context.GetUser(c)
+
+			key, err := store.FindOne(
+				c.Request().Context(),
+				datastore.IdemKeyWithKey(ik.IdempotencyKey),
+				datastore.IdemKeyWithUserID(user.ID),
+			)
+
+			switch {
+			case err == nil:
+				if replayable(key, ik) {
+					c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+					return handler.Respond(c, key)
+				}
+			case !errors.Is(err, data.ErrRecordNotFound):
+				return err
 			}
 
 			context.AddIdemKey(c, ik)
@@ -52,3 +92,13 @@ func IdempotencyKey() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// replayable tells whether the stored key holds a final response for the very
+// same request. Anything else is left for the use case to sort out, be it
+// resuming the work or rejecting mismatching params.
+func replayable(stored, ik entity.IdempotencyKey) bool {
+	return stored.RecoveryPoint == idempotency.RecoveryPointFinished &&
+		stored.ResponseCode != nil &&
+		stored.ResponseBody != nil &&
+		idemkey.CompareRequest(stored, ik) == nil
+}
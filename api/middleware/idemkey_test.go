@@ -5,6 +5,7 @@ package middleware
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -14,13 +15,49 @@ import (
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/labstack/echo/v4"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
+func TestIdemKeyEmptyBody(t *testing.T) {
+	store := &mocks.IdempotencyKey{}
+	store.On("FindOne", mock.Anything, mock.Anything, mock.Anything).
+		Return(entity.IdempotencyKey{}, data.ErrRecordNotFound)
+
+	e := httpserver.New()
+	e.Use(IdempotencyKey(store))
+
+	e.POST("/rides/1/cancel", func(c echo.Context) error {
+		ik, ok := context.GetIdemKey(c)
+
+		assert.True(t, ok)
+		assert.Equal(t, json.RawMessage("{}"), ik.RequestParams)
+		assert.Equal(t, idemkey.Fingerprint(http.MethodPost, "/rides/1/cancel", []byte("{}")), ik.RequestFingerprint)
+
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/rides/1/cancel", nil)
+	rec := httptest.NewRecorder()
+	req.Header.Set("idempotency-key", gofakeit.UUID())
+
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
 func TestIdemKey(t *testing.T) {
+	store := &mocks.IdempotencyKey{}
+	store.On("FindOne", mock.Anything, mock.Anything, mock.Anything).
+		Return(entity.IdempotencyKey{}, data.ErrRecordNotFound)
+
 	e := httpserver.New()
-	e.Use(IdempotencyKey())
+	e.Use(IdempotencyKey(store))
 
 	payload := "{\"key\":\"value\"}"
 
@@ -31,6 +68,7 @@ func TestIdemKey(t *testing.T) {
 		assert.Equal(t, http.MethodPost, ik.RequestMethod)
 		assert.Equal(t, "/", ik.RequestPath)
 		assert.Equal(t, json.RawMessage(payload), ik.RequestParams)
+		assert.Equal(t, idemkey.Fingerprint(http.MethodPost, "/", []byte(payload)), ik.RequestFingerprint)
 
 		return c.NoContent(http.StatusOK)
 	})
@@ -65,3 +103,134 @@ func TestIdemKey(t *testing.T) {
 		}
 	}
 }
+
+func TestIdemKeyReplay(t *testing.T) {
+	payload := "{\"key\":\"value\"}"
+	user := entity.User{ID: int64(gofakeit.Number(1, 1000))}
+
+	rCode := idempotency.ResponseCodeOK
+	rBody := json.RawMessage(`{"id": 1,  "origin_lat": 0.0}`)
+
+	finished := entity.IdempotencyKey{
+		IdempotencyKey:     gofakeit.UUID(),
+		RequestMethod:      http.MethodPost,
+		RequestPath:        "/",
+		RequestParams:      json.RawMessage(`{ "key": "value" }`),
+		RequestFingerprint: idemkey.Fingerprint(http.MethodPost, "/", []byte(payload)),
+		RecoveryPoint:      idempotency.RecoveryPointFinished,
+		ResponseCode:       &rCode,
+		ResponseBody:       rBody,
+		ResponseHeaders: idempotency.ResponseHeaders{
+			echo.HeaderLocation: "/rides/1",
+		},
+		UserID: user.ID,
+	}
+
+	started := finished
+	started.RecoveryPoint = idempotency.RecoveryPointStarted
+	started.ResponseCode = nil
+	started.ResponseBody = nil
+	started.ResponseHeaders = nil
+
+	mismatch := finished
+	mismatch.RequestParams = json.RawMessage(`{"key": "other value"}`)
+	mismatch.RequestFingerprint = idemkey.Fingerprint(http.MethodPost, "/", mismatch.RequestParams)
+
+	tests := []struct {
+		desc     string
+		key      entity.IdempotencyKey
+		err      error
+		code     int
+		replayed bool
+	}{
+		{desc: "replay finished key", key: finished, code: http.StatusOK, replayed: true},
+		{desc: "key not found", err: data.ErrRecordNotFound, code: http.StatusOK},
+		{desc: "key not finished", key: started, code: http.StatusOK},
+		{desc: "params mismatch", key: mismatch, code: http.StatusOK},
+		{desc: "lookup error", err: errors.New("it failed"), code: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		store := &mocks.IdempotencyKey{}
+		store.On("FindOne", mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(tc.key, tc.err)
+
+		var called bool
+
+		e := httpserver.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				context.AddUser(c, user)
+				return next(c)
+			}
+		})
+		e.Use(IdempotencyKey(store))
+		e.POST("/", func(c echo.Context) error {
+			called = true
+			return c.NoContent(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		rec := httptest.NewRecorder()
+
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("idempotency-key", finished.IdempotencyKey)
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.code, rec.Code, tc.desc)
+		store.AssertNumberOfCalls(t, "FindOne", 1)
+
+		if tc.replayed {
+			assert.False(t, called, tc.desc)
+			assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed), tc.desc)
+			assert.Equal(t, "/rides/1", rec.Header().Get(echo.HeaderLocation), tc.desc)
+			assert.Equal(t, string(rBody), rec.Body.String(), tc.desc)
+		} else {
+			assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed), tc.desc)
+		}
+	}
+}
+
+func TestIdemKeyAnonymousReplay(t *testing.T) {
+	rCode := idempotency.ResponseCodeOK
+	rBody := json.RawMessage(`{"id":1,"email":"jane@example.com"}`)
+	payload := `{"email":"jane@example.com"}`
+
+	finished := entity.IdempotencyKey{
+		IdempotencyKey:     gofakeit.UUID(),
+		RequestMethod:      http.MethodPost,
+		RequestPath:        "/users",
+		RequestParams:      json.RawMessage(payload),
+		RequestFingerprint: idemkey.Fingerprint(http.MethodPost, "/users", []byte(payload)),
+		RecoveryPoint:      idempotency.RecoveryPointFinished,
+		ResponseCode:       &rCode,
+		ResponseBody:       rBody,
+	}
+
+	store := &mocks.IdempotencyKey{}
+	store.On("FindOne", mock.Anything, mock.Anything, mock.Anything).
+		Once().
+		Return(finished, nil)
+
+	e := httpserver.New()
+	e.Use(IdempotencyKey(store))
+	e.POST("/users", func(c echo.Context) error {
+		t.Error("handler must not be called on replays")
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(payload))
+	rec := httptest.NewRecorder()
+
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("idempotency-key", finished.IdempotencyKey)
+
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, string(rBody), rec.Body.String())
+	store.AssertExpectations(t)
+}
@@ -1,40 +1,55 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/apikey"
 )
 
-type userRequest struct {
-	UserKey string `header:"authorization" validate:"required"`
-}
+const (
+	authScheme = "Bearer"
+	authRealm  = "go-rocket-ride"
+)
 
-func User(store datastore.User) echo.MiddlewareFunc {
+// User authenticates requests by the API key sent as a bearer token in the
+// `Authorization` header, making the key's user available to the handlers.
+// Unknown and revoked keys are rejected alike.
+func User(keys datastore.APIKey, users datastore.User) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			binder := &echo.DefaultBinder{}
-			validate := validator.New()
-			ur := userRequest{}
+			token, ok := bearerToken(c.Request().Header.Get(echo.HeaderAuthorization))
+			if !ok {
+				return unauthorized(c, "")
+			}
+
+			ctx := c.Request().Context()
 
-			if err := binder.BindHeaders(c, &ur); err != nil {
+			key, err := keys.FindOne(ctx, datastore.APIKeyWithHash(apikey.Hash(token)))
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return unauthorized(c, "invalid_token")
+			}
+			if err != nil {
 				return err
 			}
 
-			if err := validate.Struct(&ur); err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			if key.RevokedAt != nil {
+				return unauthorized(c, "invalid_token")
 			}
 
-			// This is obviously something you shouldn't do in a real application, but for
-			// now we're just going to trust that the user is whoever they said they were
-			// from an email in the `Authorization` header.
-			user, err := store.FindOne(c.Request().Context(), datastore.UserWithEmail(ur.UserKey))
+			user, err := users.FindOne(ctx, datastore.UserWithID(key.UserID))
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return unauthorized(c, "invalid_token")
+			}
 			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, entity.ErrPermissionDenied.Error())
+				return err
 			}
 
 			context.AddUser(c, user)
@@ -43,3 +58,27 @@ func User(store datastore.User) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// bearerToken extracts the token out of an `Authorization` header value.
+func bearerToken(auth string) (string, bool) {
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, authScheme) {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// unauthorized rejects the request, telling the client how to authenticate
+// through the `WWW-Authenticate` header as per RFC 6750. Requests carrying no
+// credentials at all get no error code.
+func unauthorized(c echo.Context, code string) error {
+	challenge := fmt.Sprintf("%s realm=%q", authScheme, authRealm)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q", code)
+	}
+
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return echo.NewHTTPError(http.StatusUnauthorized, entity.ErrPermissionDenied.Error())
+}
@@ -8,48 +8,134 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/labstack/echo/v4"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/api/context"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestUser(t *testing.T) {
+	mockKey := &mocks.APIKey{}
 	mockUser := &mocks.User{}
 
 	e := httpserver.New()
-	e.Use(User(mockUser))
+	e.Use(User(mockKey, mockUser))
 
+	user := entity.User{ID: int64(gofakeit.Number(1, 1000)), Email: gofakeit.Email()}
 	e.POST("/", func(c echo.Context) error {
+		u, ok := context.GetUser(c)
+		assert.True(t, ok)
+		assert.Equal(t, user, u)
 		return c.NoContent(http.StatusOK)
 	})
 
+	revokedAt := time.Now().UTC()
+	challenge := `Bearer realm="go-rocket-ride"`
+	invalid := `Bearer realm="go-rocket-ride", error="invalid_token"`
+
 	tests := []struct {
-		mock   bool
-		fail   bool
-		header string
-		value  string
-		ret    int
+		desc      string
+		header    string
+		value     string
+		key       entity.APIKey
+		keyErr    error
+		userErr   error
+		ret       int
+		challenge string
 	}{
-		{mock: false, fail: true, header: "", value: "", ret: http.StatusBadRequest},
-		{mock: false, fail: true, header: gofakeit.AnimalType(), value: gofakeit.Email(), ret: http.StatusBadRequest},
-		{mock: true, fail: true, header: "authorization", value: gofakeit.Email(), ret: http.StatusUnauthorized},
-		{mock: true, fail: false, header: "authorization", value: gofakeit.Email(), ret: http.StatusOK},
+		{
+			desc:      "missing header",
+			ret:       http.StatusUnauthorized,
+			challenge: challenge,
+		},
+		{
+			desc:      "wrong header",
+			header:    gofakeit.AnimalType(),
+			value:     "Bearer foo",
+			ret:       http.StatusUnauthorized,
+			challenge: challenge,
+		},
+		{
+			desc:      "email as credentials",
+			header:    "authorization",
+			value:     gofakeit.Email(),
+			ret:       http.StatusUnauthorized,
+			challenge: challenge,
+		},
+		{
+			desc:      "other scheme",
+			header:    "authorization",
+			value:     "Basic Zm9vOmJhcg==",
+			ret:       http.StatusUnauthorized,
+			challenge: challenge,
+		},
+		{
+			desc:      "empty token",
+			header:    "authorization",
+			value:     "Bearer  ",
+			ret:       http.StatusUnauthorized,
+			challenge: challenge,
+		},
+		{
+			desc:      "unknown key",
+			header:    "authorization",
+			value:     "Bearer rr_foo",
+			keyErr:    data.ErrRecordNotFound,
+			ret:       http.StatusUnauthorized,
+			challenge: invalid,
+		},
+		{
+			desc:      "revoked key",
+			header:    "authorization",
+			value:     "Bearer rr_foo",
+			key:       entity.APIKey{UserID: user.ID, RevokedAt: &revokedAt},
+			ret:       http.StatusUnauthorized,
+			challenge: invalid,
+		},
+		{
+			desc:   "error on key lookup",
+			header: "authorization",
+			value:  "Bearer rr_foo",
+			keyErr: errors.New("it failed"),
+			ret:    http.StatusInternalServerError,
+		},
+		{
+			desc:    "error on user lookup",
+			header:  "authorization",
+			value:   "Bearer rr_foo",
+			key:     entity.APIKey{UserID: user.ID},
+			userErr: errors.New("it failed"),
+			ret:     http.StatusInternalServerError,
+		},
+		{
+			desc:   "valid key",
+			header: "authorization",
+			value:  "Bearer rr_foo",
+			key:    entity.APIKey{UserID: user.ID},
+			ret:    http.StatusOK,
+		},
+		{
+			desc:   "case-insensitive scheme",
+			header: "authorization",
+			value:  "bearer rr_foo",
+			key:    entity.APIKey{UserID: user.ID},
+			ret:    http.StatusOK,
+		},
 	}
 
 	for _, tc := range tests {
-		if tc.mock {
-			if tc.fail {
-				mockUser.On("FindOne", mock.Anything, mock.Anything).
-					Once().
-					Return(entity.User{}, errors.New("it failed"))
-			} else {
-				mockUser.On("FindOne", mock.Anything, mock.Anything).Once().Return(entity.User{}, nil)
-			}
+		if tc.challenge != challenge {
+			mockKey.On("FindOne", mock.Anything, mock.Anything).Once().Return(tc.key, tc.keyErr)
+		}
+		if tc.keyErr == nil && tc.key.UserID != 0 && tc.key.RevokedAt == nil {
+			mockUser.On("FindOne", mock.Anything, mock.Anything).Once().Return(user, tc.userErr)
 		}
 
 		req := httptest.NewRequest(http.MethodPost, "/", nil)
@@ -61,6 +147,10 @@ func TestUser(t *testing.T) {
 		}
 
 		e.ServeHTTP(rec, req)
-		assert.Equal(t, tc.ret, rec.Code)
+		assert.Equal(t, tc.ret, rec.Code, tc.desc)
+		assert.Equal(t, tc.challenge, rec.Header().Get(echo.HeaderWWWAuthenticate), tc.desc)
 	}
+
+	mockKey.AssertExpectations(t)
+	mockUser.AssertExpectations(t)
 }
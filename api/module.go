@@ -6,23 +6,53 @@ import (
 	"github.com/rafael-piovesan/go-rocket-ride/v2/api/middleware"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/pricing"
 	"go.uber.org/fx"
 )
 
-func routes(e *echo.Echo, userStore datastore.User, ride handler.Ride) {
+func routes(
+	e *echo.Echo,
+	apiKeyStore datastore.APIKey,
+	userStore datastore.User,
+	idemKeyStore datastore.IdempotencyKey,
+	ride handler.Ride,
+	user handler.User,
+	webhook handler.Webhook,
+	auditLog handler.Audit,
+) {
 	e.Use(middleware.OriginIP())
 	e.Use(middleware.ErrorMapper())
-	e.Use(middleware.User(userStore))
-	e.Use(middleware.IdempotencyKey())
+
+	auth := middleware.User(apiKeyStore, userStore)
+	idem := middleware.IdempotencyKey(idemKeyStore)
+	admin := middleware.Admin()
 
 	// Routes
-	e.POST("/", ride.Create)
+	e.POST("/", ride.Create, auth, idem)
+	e.GET("/rides", ride.List, auth)
+	e.GET("/rides/:id", ride.Get, auth)
+	e.POST("/rides/:id/cancel", ride.Cancel, auth, idem)
+	e.POST(usecase.SignupPath, user.Create, idem)
+	e.POST("/webhooks/stripe", webhook.Stripe)
+	e.GET("/admin/audit-records", auditLog.List, auth, admin)
 }
 
 var Module = fx.Options(
+	// the api only stages jobs, working them is up to the enqueuer
+	fx.Supply(usecase.JobHandlers{}),
 	fx.Provide(
+		idemkey.New,
+		pricing.New,
+		usecase.NewJobs,
 		usecase.NewRide,
+		usecase.NewUser,
+		usecase.NewWebhook,
+		usecase.NewAudit,
 		handler.NewRide,
+		handler.NewUser,
+		handler.NewWebhook,
+		handler.NewAudit,
 	),
 	fx.Invoke(routes),
 )
@@ -4,6 +4,7 @@ import (
 	"github.com/rafael-piovesan/go-rocket-ride/v2/api"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/httpserver"
@@ -18,8 +19,12 @@ func main() {
 			db.Connect,
 			db.ConnectionHandle,
 			uow.New,
+			datastore.NewAPIKey,
+			datastore.NewAuditRecord,
 			datastore.NewIdempotencyKey,
+			datastore.NewRide,
 			datastore.NewUser,
+			payment.NewStripe,
 			httpserver.New,
 		),
 		// Loading HTTP routes & handlers
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/apikey"
+)
+
+func main() {
+	email := flag.String("email", "", "create a new API key for the user with this email")
+	revoke := flag.Int64("revoke", 0, "revoke the API key with this id")
+	flag.Parse()
+
+	if err := run(context.Background(), *email, *revoke); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, email string, revoke int64) error {
+	if (email == "") == (revoke == 0) {
+		flag.Usage()
+		return errors.New("either -email or -revoke must be given")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	keys := apikey.New(datastore.NewAPIKey(conn))
+
+	if revoke != 0 {
+		if err := keys.Revoke(ctx, revoke); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "revoked API key %v\n", revoke)
+		return nil
+	}
+
+	user, err := datastore.NewUser(conn).FindOne(ctx, datastore.UserWithEmail(email))
+	if err != nil {
+		return fmt.Errorf("find user %v: %w", email, err)
+	}
+
+	key, ak, err := keys.Create(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// the key is only ever shown here, since just its hash is stored
+	fmt.Fprintf(os.Stdout, "created API key %v for %v: %v\n", ak.ID, user.Email, key)
+	return nil
+}
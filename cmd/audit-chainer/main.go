@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/worker"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/auditchain"
+	"go.uber.org/fx"
+)
+
+func main() {
+	fx.New(
+		fx.Provide(
+			config.Load,
+			db.Connect,
+			uow.New,
+			auditchain.NewChainer,
+			newWorker,
+		),
+		fx.Invoke(worker.Invoke),
+	).Run()
+}
+
+func newWorker(cfg config.Config, uc auditchain.Chainer) worker.Worker {
+	interval := time.Duration(cfg.WorkerInterval) * time.Second
+
+	return worker.New("audit-chainer", interval, func(ctx context.Context) error {
+		n, err := uc.Chain(ctx)
+		if n > 0 {
+			log.Infof("chained %v audit record(s)", n)
+		}
+		return err
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/auditchain"
+)
+
+func main() {
+	ok, err := run(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// run verifies the audit records' hash chain, telling whether it's intact.
+func run(ctx context.Context) (bool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := db.Connect(cfg)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	rep, err := auditchain.New(cfg, uow.New(conn)).Verify(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if rep.Unchained > 0 {
+		fmt.Fprintf(os.Stdout, "skipped %v audit record(s) not chained yet\n", rep.Unchained)
+	}
+
+	if rep.Broken != nil {
+		fmt.Fprintf(
+			os.Stderr,
+			"audit chain broken at record %v, after %v intact record(s): %v\n",
+			rep.Broken.RecordID, rep.Checked, rep.Broken.Reason,
+		)
+		return false, nil
+	}
+
+	fmt.Fprintf(os.Stdout, "audit chain intact: %v record(s) verified\n", rep.Checked)
+	return true, nil
+}
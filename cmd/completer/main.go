@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/stripemock"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/worker"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/pricing"
+	"go.uber.org/fx"
+)
+
+func main() {
+	fx.New(
+		// the completer only stages jobs, working them is up to the enqueuer
+		fx.Supply(usecase.JobHandlers{}),
+		fx.Provide(
+			config.Load,
+			db.Connect,
+			db.ConnectionHandle,
+			uow.New,
+			datastore.NewIdempotencyKey,
+			datastore.NewRide,
+			datastore.NewUser,
+			payment.NewStripe,
+			idemkey.New,
+			pricing.New,
+			usecase.NewJobs,
+			usecase.NewRide,
+			usecase.NewUser,
+			usecase.NewCompleter,
+			newWorker,
+		),
+		// Replace the original Stripe API Backend with its mock
+		fx.Invoke(stripemock.Init),
+		fx.Invoke(worker.Invoke),
+	).Run()
+}
+
+func newWorker(cfg config.Config, uc usecase.Completer) worker.Worker {
+	interval := time.Duration(cfg.WorkerInterval) * time.Second
+
+	return worker.New("completer", interval, func(ctx context.Context) error {
+		n, err := uc.Complete(ctx)
+		if n > 0 {
+			log.Infof("completed %v abandoned request(s)", n)
+		}
+		return err
+	})
+}
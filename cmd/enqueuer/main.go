@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/notify"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/worker"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+	"go.uber.org/fx"
+)
+
+func main() {
+	fx.New(
+		fx.Provide(
+			config.Load,
+			db.Connect,
+			db.ConnectionHandle,
+			uow.New,
+			datastore.NewRide,
+			datastore.NewUser,
+			notify.NewMailer,
+			notify.New,
+			jobHandlers,
+			usecase.NewJobs,
+			usecase.NewEnqueuer,
+			newWorker,
+		),
+		fx.Invoke(worker.Invoke),
+	).Run()
+}
+
+func jobHandlers(n notify.Notifier) usecase.JobHandlers {
+	return usecase.JobHandlers{
+		SendReceipt:            n.SendReceipt,
+		SendCancellationNotice: logJob[stagedjob.JobArgCancellationNotice],
+		SendWelcome:            logJob[stagedjob.JobArgWelcome],
+	}
+}
+
+// logJob stands in for a real job queue client (e.g. Sidekiq, Faktory) for the
+// jobs that aren't worked yet, for now it only logs their args.
+func logJob[T any](_ context.Context, args T) error {
+	log.Infof("enqueued job: %T %+v", args, args)
+	return nil
+}
+
+func newWorker(cfg config.Config, uc usecase.Enqueuer) worker.Worker {
+	interval := time.Duration(cfg.WorkerInterval) * time.Second
+
+	return worker.New("enqueuer", interval, func(ctx context.Context) error {
+		n, err := uc.Enqueue(ctx)
+		if n > 0 {
+			log.Infof("enqueued %v staged job(s)", n)
+		}
+		return err
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/worker"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase"
+	"go.uber.org/fx"
+)
+
+func main() {
+	fx.New(
+		fx.Provide(
+			config.Load,
+			db.Connect,
+			uow.New,
+			usecase.NewReaper,
+			newWorker,
+		),
+		fx.Invoke(worker.Invoke),
+	).Run()
+}
+
+func newWorker(cfg config.Config, uc usecase.Reaper) worker.Worker {
+	interval := time.Duration(cfg.WorkerInterval) * time.Second
+
+	return worker.New("reaper", interval, func(ctx context.Context) error {
+		n, err := uc.Reap(ctx)
+		if n > 0 {
+			log.Infof("reaped %v idempotency key(s)", n)
+		}
+		return err
+	})
+}
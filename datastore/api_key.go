@@ -0,0 +1,27 @@
+package datastore
+
+import (
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/uptrace/bun"
+)
+
+type APIKey interface {
+	data.ICRUDStore[entity.APIKey]
+}
+
+func NewAPIKey(db bun.IDB) APIKey {
+	return data.New[entity.APIKey](db)
+}
+
+func APIKeyWithHash(h string) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("key_hash = ?", h)
+	}
+}
+
+func APIKeyWithID(id int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("id = ?", id)
+	}
+}
@@ -0,0 +1,99 @@
+//go:build integration
+// +build integration
+
+package datastore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/migrate"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/testcontainer"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/testfixtures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	ctx := context.Background()
+
+	// database up
+	dsn, terminate, err := testcontainer.NewPostgresContainer()
+	require.NoError(t, err)
+	defer func() { _ = terminate(ctx) }()
+
+	// migrations up
+	err = migrate.Up(dsn, "db/migrations")
+	require.NoError(t, err)
+
+	// test fixtures up
+	userID := int64(gofakeit.Number(0, 1000))
+	err = testfixtures.Load(dsn, []string{"db/fixtures/users"}, map[string]interface{}{
+		"UserId":    userID,
+		"UserEmail": gofakeit.Email(),
+	})
+	require.NoError(t, err)
+
+	// conntect to database
+	db, _ := db.Connect(config.Config{DBSource: dsn})
+	store := NewAPIKey(db)
+
+	// test entity
+	h := sha256.Sum256([]byte(gofakeit.UUID()))
+	ak := &entity.APIKey{
+		KeyHash: hex.EncodeToString(h[:]),
+		UserID:  userID,
+	}
+
+	t.Run("API Key not found", func(t *testing.T) {
+		_, err := store.FindOne(ctx, APIKeyWithHash(ak.KeyHash))
+		assert.ErrorIs(t, err, data.ErrRecordNotFound)
+	})
+
+	t.Run("Create API Key", func(t *testing.T) {
+		err := store.Save(ctx, ak)
+		if assert.NoError(t, err) {
+			assert.Greater(t, ak.ID, int64(0))
+			assert.False(t, ak.CreatedAt.IsZero())
+		}
+	})
+
+	t.Run("Create API Key with duplicate hash", func(t *testing.T) {
+		dup := &entity.APIKey{KeyHash: ak.KeyHash, UserID: userID}
+		err := store.Save(ctx, dup)
+		assert.Error(t, err)
+	})
+
+	t.Run("Revoke API Key", func(t *testing.T) {
+		now := time.Now()
+		revokedAt := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
+		ak.RevokedAt = &revokedAt
+
+		err := store.Update(ctx, ak)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Get API Key", func(t *testing.T) {
+		res, err := store.FindOne(ctx, APIKeyWithHash(ak.KeyHash))
+		if assert.NoError(t, err) {
+			assert.Equal(t, ak.ID, res.ID)
+			assert.Equal(t, ak.UserID, res.UserID)
+			if assert.NotNil(t, res.RevokedAt) {
+				assert.True(t, ak.RevokedAt.Equal(*res.RevokedAt))
+			}
+		}
+
+		res, err = store.FindOne(ctx, APIKeyWithID(ak.ID))
+		if assert.NoError(t, err) {
+			assert.Equal(t, ak.KeyHash, res.KeyHash)
+		}
+	})
+}
@@ -1,15 +1,193 @@
 package datastore
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/uptrace/bun"
 )
 
+// AuditRecord stores the records of the hash chain. Unlike other stores, it
+// doesn't offer the whole data.ICRUDStore: rows written by anything but Save
+// and Chain would break the chain, so records can only be read, saved and
+// then linked to the chain.
 type AuditRecord interface {
-	data.ICRUDStore[entity.AuditRecord]
+	FindAll(context.Context, ...data.SelectCriteria) ([]entity.AuditRecord, error)
+	FindOne(context.Context, ...data.SelectCriteria) (entity.AuditRecord, error)
+	FindPage(context.Context, data.PageRequest, ...data.SelectCriteria) (data.Page[entity.AuditRecord], error)
+	Count(context.Context, ...data.SelectCriteria) (int, error)
+	Save(context.Context, *entity.AuditRecord) error
+	// Chain stores the links of the given records, already hashed and in the
+	// order they're appended, and moves the chain head to the last of them.
+	// The head must have been locked by LockChainHead in the same transaction.
+	Chain(context.Context, []entity.AuditRecord) error
+	// ChainHead returns the head of the chain, pointing at its newest record.
+	ChainHead(context.Context) (entity.AuditChainHead, error)
+	// LockChainHead is like ChainHead, though it also locks the head until the
+	// transaction ends, so that records are chained one after the other.
+	LockChainHead(context.Context) (entity.AuditChainHead, error)
+}
+
+type auditRecordStore struct {
+	records data.ICRUDStore[entity.AuditRecord]
+	db      bun.IDB
 }
 
 func NewAuditRecord(db bun.IDB) AuditRecord {
-	return data.New[entity.AuditRecord](db)
+	return &auditRecordStore{
+		records: data.New[entity.AuditRecord](db),
+		db:      db,
+	}
+}
+
+func (s *auditRecordStore) FindAll(ctx context.Context, sc ...data.SelectCriteria) ([]entity.AuditRecord, error) {
+	return s.records.FindAll(ctx, sc...)
+}
+
+func (s *auditRecordStore) FindOne(ctx context.Context, sc ...data.SelectCriteria) (entity.AuditRecord, error) {
+	return s.records.FindOne(ctx, sc...)
+}
+
+func (s *auditRecordStore) FindPage(
+	ctx context.Context,
+	pr data.PageRequest,
+	sc ...data.SelectCriteria,
+) (data.Page[entity.AuditRecord], error) {
+	return s.records.FindPage(ctx, pr, sc...)
+}
+
+func (s *auditRecordStore) Count(ctx context.Context, sc ...data.SelectCriteria) (int, error) {
+	return s.records.Count(ctx, sc...)
+}
+
+// Save stores the record unchained, leaving it to be linked to the chain
+// later on, so that transactions saving records don't wait on one another for
+// the chain head.
+func (s *auditRecordStore) Save(ctx context.Context, ar *entity.AuditRecord) error {
+	return s.records.Save(ctx, ar)
+}
+
+func (s *auditRecordStore) Chain(ctx context.Context, records []entity.AuditRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	_, err := s.records.UpdateMany(ctx, records, "prev_hash", "hash", "chain_seq")
+	if err != nil {
+		return err
+	}
+
+	last := records[len(records)-1]
+	_, err = s.db.NewUpdate().
+		Table("audit_chain_head").
+		Set("hash = ?", last.Hash).
+		Set("seq = ?", last.ChainSeq).
+		Where("id = 1").
+		Exec(ctx)
+	return err
+}
+
+func (s *auditRecordStore) ChainHead(ctx context.Context) (entity.AuditChainHead, error) {
+	return s.chainHead(ctx, false)
+}
+
+func (s *auditRecordStore) LockChainHead(ctx context.Context) (entity.AuditChainHead, error) {
+	return s.chainHead(ctx, true)
+}
+
+func (s *auditRecordStore) chainHead(ctx context.Context, lock bool) (entity.AuditChainHead, error) {
+	var (
+		hash sql.NullString
+		seq  int64
+	)
+
+	q := s.db.NewSelect().
+		Table("audit_chain_head").
+		Column("hash", "seq").
+		Where("id = 1")
+	if lock {
+		q = q.For("UPDATE")
+	}
+
+	err := q.Scan(ctx, &hash, &seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.AuditChainHead{}, data.ErrRecordNotFound
+	}
+	if err != nil {
+		return entity.AuditChainHead{}, err
+	}
+
+	return entity.AuditChainHead{Hash: hash.String, Seq: seq}, nil
+}
+
+func AuditRecordWithUserID(uid int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("user_id = ?", uid)
+	}
+}
+
+func AuditRecordWithAction(a audit.Action) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("action = ?", a)
+	}
+}
+
+func AuditRecordWithResourceType(rt audit.ResourceType) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("resource_type = ?", rt)
+	}
+}
+
+func AuditRecordWithResourceID(id int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("resource_id = ?", id)
+	}
+}
+
+// AuditRecordWithinNetwork selects records whose origin IP is contained by
+// the given network, in CIDR notation. A single IP address matches itself.
+func AuditRecordWithinNetwork(cidr string) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("origin_ip <<= ?::inet", cidr)
+	}
+}
+
+// AuditRecordCreatedFrom selects records created at or after the given time.
+func AuditRecordCreatedFrom(t time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("created_at >= ?", t)
+	}
+}
+
+// AuditRecordCreatedBefore selects records created before the given time.
+func AuditRecordCreatedBefore(t time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("created_at < ?", t)
+	}
+}
+
+// AuditRecordChained selects records already linked to the chain.
+func AuditRecordChained() data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("chain_seq IS NOT NULL")
+	}
+}
+
+// AuditRecordUnchained selects records not linked to the chain yet.
+func AuditRecordUnchained() data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("chain_seq IS NULL")
+	}
+}
+
+// AuditRecordOldestFirst returns at most n records, oldest first.
+func AuditRecordOldestFirst(n int) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Order("id ASC").Limit(n)
+	}
 }
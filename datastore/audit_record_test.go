@@ -6,11 +6,13 @@ package datastore
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/migrate"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/testcontainer"
@@ -64,4 +66,138 @@ func TestAuditRecord(t *testing.T) {
 			assert.Greater(t, ar.ID, int64(0))
 		}
 	})
+
+	t.Run("Find Audit Records By Criteria", func(t *testing.T) {
+		otherUserID := userID + 1
+		err := NewUser(db).Save(ctx, &entity.User{ID: otherUserID, Email: gofakeit.Email()})
+		require.NoError(t, err)
+
+		base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		records := []*entity.AuditRecord{
+			{
+				Action:       audit.ActionCreateRide,
+				CreatedAt:    base,
+				OriginIP:     "10.0.0.1/32",
+				ResourceID:   1,
+				ResourceType: audit.ResourceTypeRide,
+				UserID:       otherUserID,
+			},
+			{
+				Action:       audit.ActionCancelRide,
+				CreatedAt:    base.Add(time.Hour),
+				OriginIP:     "10.1.0.1/32",
+				ResourceID:   1,
+				ResourceType: audit.ResourceTypeRide,
+				UserID:       otherUserID,
+			},
+			{
+				Action:       audit.ActionCreateUser,
+				CreatedAt:    base.Add(2 * time.Hour),
+				OriginIP:     "192.168.0.1/32",
+				ResourceID:   otherUserID,
+				ResourceType: audit.ResourceTypeUser,
+				UserID:       otherUserID,
+			},
+		}
+		for _, ar := range records {
+			ar.Data = []byte("{}")
+			require.NoError(t, store.Save(ctx, ar))
+		}
+
+		ids := func(sc ...data.SelectCriteria) []int64 {
+			pr := data.PageRequest{OrderBy: []data.Order{data.Desc("id")}}
+			page, err := store.FindPage(ctx, pr, append(sc, AuditRecordWithUserID(otherUserID))...)
+			require.NoError(t, err)
+
+			var ids []int64
+			for _, ar := range page.Rows {
+				ids = append(ids, ar.ID)
+			}
+			return ids
+		}
+
+		assert.Equal(t, []int64{records[2].ID, records[1].ID, records[0].ID}, ids())
+		assert.Equal(t, []int64{records[1].ID}, ids(AuditRecordWithAction(audit.ActionCancelRide)))
+		assert.Equal(
+			t,
+			[]int64{records[1].ID, records[0].ID},
+			ids(AuditRecordWithResourceType(audit.ResourceTypeRide), AuditRecordWithResourceID(1)),
+		)
+		assert.Equal(t, []int64{records[1].ID, records[0].ID}, ids(AuditRecordWithinNetwork("10.0.0.0/8")))
+		assert.Equal(t, []int64{records[0].ID}, ids(AuditRecordWithinNetwork("10.0.0.1")))
+		assert.Equal(
+			t,
+			[]int64{records[1].ID},
+			ids(AuditRecordCreatedFrom(base.Add(time.Hour)), AuditRecordCreatedBefore(base.Add(2*time.Hour))),
+		)
+		assert.Empty(t, ids(AuditRecordWithUserID(userID)))
+	})
+	t.Run("Chain Audit Records", func(t *testing.T) {
+		for _, d := range []string{
+			`{"b": 1, "a": "foo"}`,
+			// JSONB rewrites numbers, e.g. 1e1 is stored as 10
+			`{"origin_lat": 1e1, "origin_lon": -1.5E+2, "target_lat": 0.1e-1}`,
+			`{}`,
+		} {
+			ar := &entity.AuditRecord{
+				Action:       audit.ActionCreateRide,
+				Data:         []byte(d),
+				OriginIP:     gofakeit.IPv4Address(),
+				ResourceID:   int64(gofakeit.Number(0, 1000)),
+				ResourceType: audit.ResourceTypeRide,
+				UserID:       userID,
+			}
+			require.NoError(t, store.Save(ctx, ar))
+
+			// records are saved unchained
+			assert.Empty(t, ar.Hash)
+			assert.Zero(t, ar.ChainSeq)
+		}
+
+		// link them the way the chainer does
+		key := []byte(gofakeit.Password(true, true, true, false, false, 32))
+		head, err := store.LockChainHead(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, entity.AuditChainHead{}, head)
+
+		records, err := store.FindAll(ctx, AuditRecordUnchained(), AuditRecordOldestFirst(100))
+		require.NoError(t, err)
+		require.NotEmpty(t, records)
+
+		for i := range records {
+			records[i].PrevHash = head.Hash
+			records[i].ChainSeq = head.Seq + 1
+			records[i].Hash, err = records[i].ChainHash(key)
+			require.NoError(t, err)
+			head = entity.AuditChainHead{Hash: records[i].Hash, Seq: records[i].ChainSeq}
+		}
+		require.NoError(t, store.Chain(ctx, records))
+
+		n, err := store.Count(ctx, AuditRecordUnchained())
+		if assert.NoError(t, err) {
+			assert.Zero(t, n)
+		}
+
+		chained, err := store.FindAll(ctx, AuditRecordChained(), AuditRecordOldestFirst(100))
+		require.NoError(t, err)
+		require.Len(t, chained, len(records))
+
+		var prev string
+		for i, ar := range chained {
+			assert.Equal(t, int64(i+1), ar.ChainSeq)
+			assert.Equal(t, prev, ar.PrevHash)
+
+			// records read back hash just like they did when chained
+			h, err := ar.ChainHash(key)
+			if assert.NoError(t, err) {
+				assert.Equal(t, ar.Hash, h)
+			}
+			prev = ar.Hash
+		}
+
+		stored, err := store.ChainHead(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, head, stored)
+		}
+	})
 }
@@ -1,7 +1,10 @@
 package datastore
 
 import (
+	"time"
+
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/uptrace/bun"
 )
@@ -20,8 +23,58 @@ func IdemKeyWithKey(key string) data.SelectCriteria {
 	}
 }
 
+// IdemKeyWithUserID selects keys sent by the given user, or by no user at all
+// (e.g., when signing up) if it's zero.
 func IdemKeyWithUserID(uid int64) data.SelectCriteria {
 	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if uid == 0 {
+			return q.Where("user_id IS NULL")
+		}
 		return q.Where("user_id = ?", uid)
 	}
 }
+
+// IdemKeyAbandonedBefore selects unfinished keys that haven't been worked on
+// since the given time, either because they were unlocked after an error or
+// because their lock has expired. Keys awaiting the customer's action aren't
+// abandoned, they're left for the client to retry once it's taken (see
+// IdemKeyAwaitingActionBefore).
+func IdemKeyAbandonedBefore(t time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("recovery_point NOT IN (?)", bun.In([]idempotency.RecoveryPoint{
+				idempotency.RecoveryPointFinished,
+				idempotency.RecoveryPointAwaitingAction,
+			})).
+			Where("last_run_at < ?", t).
+			WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Where("locked_at IS NULL").WhereOr("locked_at < ?", t)
+			})
+	}
+}
+
+// IdemKeyAwaitingActionBefore selects unlocked keys awaiting the customer's
+// action that haven't been checked on since the given time.
+func IdemKeyAwaitingActionBefore(t time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("recovery_point = ?", idempotency.RecoveryPointAwaitingAction).
+			Where("last_run_at < ?", t).
+			Where("locked_at IS NULL")
+	}
+}
+
+// IdemKeyFinishedBefore selects finished keys created before the given time.
+func IdemKeyFinishedBefore(t time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("recovery_point = ?", idempotency.RecoveryPointFinished).
+			Where("created_at < ?", t)
+	}
+}
+
+func IdemKeyWithLimit(n int) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Order("id").Limit(n)
+	}
+}
@@ -5,6 +5,7 @@ package datastore
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -60,6 +61,7 @@ func TestIdempotencyKey(t *testing.T) {
 		RecoveryPoint:  idempotency.RecoveryPointStarted,
 		UserID:         userID,
 	}
+	ik.RequestFingerprint = fmt.Sprintf("%064x", gofakeit.Uint64())
 
 	t.Run("Idempotency Key not found", func(t *testing.T) {
 		_, err := store.FindOne(ctx, IdemKeyWithKey(idemKey), IdemKeyWithUserID(userID))
@@ -85,13 +87,16 @@ func TestIdempotencyKey(t *testing.T) {
 		idx := gofakeit.Number(0, len(rps)-1)
 
 		resCode := idempotency.ResponseCodeOK
-		resBody := idempotency.ResponseBody{Message: "OK"}
+		// stored as is, whitespace and key order included
+		resBody := json.RawMessage(`{"id": 1,  "amount": 2000}`)
+		resHeaders := idempotency.ResponseHeaders{"Location": "/rides/1"}
 
 		ik.LastRunAt = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
 		ik.LockedAt = nil
 		ik.RecoveryPoint = rps[idx]
 		ik.ResponseCode = &resCode
-		ik.ResponseBody = &resBody
+		ik.ResponseBody = resBody
+		ik.ResponseHeaders = resHeaders
 
 		err := store.Update(ctx, ik)
 		assert.NoError(t, err)
@@ -103,4 +108,148 @@ func TestIdempotencyKey(t *testing.T) {
 			assert.Equal(t, *ik, res)
 		}
 	})
+
+	t.Run("Find Abandoned Idempotency Keys", func(t *testing.T) {
+		past := time.Date(now.Year()-1, now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
+
+		abandoned := &entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			LockedAt:       nil,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+			UserID:         userID,
+		}
+		err := store.Save(ctx, abandoned)
+		require.NoError(t, err)
+
+		expired := &entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			LockedAt:       &past,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
+			UserID:         userID,
+		}
+		err = store.Save(ctx, expired)
+		require.NoError(t, err)
+
+		finished := &entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			LockedAt:       nil,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointFinished,
+			UserID:         userID,
+		}
+		err = store.Save(ctx, finished)
+		require.NoError(t, err)
+
+		// suspended until the customer acts on the payment
+		awaiting := &entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			LockedAt:       nil,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointAwaitingAction,
+			UserID:         userID,
+		}
+		err = store.Save(ctx, awaiting)
+		require.NoError(t, err)
+
+		res, err := store.FindAll(ctx, IdemKeyAbandonedBefore(past.Add(time.Minute)), IdemKeyWithLimit(10))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 2)
+			assert.Equal(t, abandoned.ID, res[0].ID)
+			assert.Equal(t, expired.ID, res[1].ID)
+		}
+
+		res, err = store.FindAll(ctx, IdemKeyAbandonedBefore(past.Add(time.Minute)), IdemKeyWithLimit(1))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 1)
+		}
+
+		// the ones awaiting action are looked for apart
+		res, err = store.FindAll(ctx, IdemKeyAwaitingActionBefore(past.Add(time.Minute)), IdemKeyWithLimit(10))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 1)
+			assert.Equal(t, awaiting.ID, res[0].ID)
+		}
+	})
+
+	t.Run("Find Finished Idempotency Keys", func(t *testing.T) {
+		past := time.Date(now.Year()-1, now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
+
+		finished := &entity.IdempotencyKey{
+			CreatedAt:      past,
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointFinished,
+			UserID:         userID,
+		}
+		err := store.Save(ctx, finished)
+		require.NoError(t, err)
+
+		unfinished := &entity.IdempotencyKey{
+			CreatedAt:      past,
+			IdempotencyKey: gofakeit.UUID(),
+			LastRunAt:      past,
+			RequestMethod:  gofakeit.HTTPMethod(),
+			RequestParams:  []byte("{\"data\": \"foo\"}"),
+			RequestPath:    fmt.Sprintf("/%s/%s", gofakeit.AnimalType(), gofakeit.Animal()),
+			RecoveryPoint:  idempotency.RecoveryPointStarted,
+			UserID:         userID,
+		}
+		err = store.Save(ctx, unfinished)
+		require.NoError(t, err)
+
+		res, err := store.FindAll(ctx, IdemKeyFinishedBefore(past.Add(time.Minute)), IdemKeyWithLimit(10))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 1)
+			assert.Equal(t, finished.ID, res[0].ID)
+		}
+	})
+
+	t.Run("Anonymous Idempotency Keys", func(t *testing.T) {
+		anonymous := &entity.IdempotencyKey{
+			IdempotencyKey: idemKey,
+			LastRunAt:      ttime,
+			RequestMethod:  "POST",
+			RequestParams:  []byte("{\"email\": \"jane@example.com\"}"),
+			RequestPath:    "/users",
+			RecoveryPoint:  idempotency.RecoveryPointSignupStarted,
+		}
+
+		// the same key may be used both by a user and anonymously
+		err := store.Save(ctx, anonymous)
+		require.NoError(t, err)
+
+		res, err := store.FindOne(ctx, IdemKeyWithKey(idemKey), IdemKeyWithUserID(0))
+		if assert.NoError(t, err) {
+			assert.Equal(t, anonymous.ID, res.ID)
+			assert.Zero(t, res.UserID)
+		}
+
+		// but only once anonymously
+		err = store.Save(ctx, &entity.IdempotencyKey{
+			IdempotencyKey: idemKey,
+			LastRunAt:      ttime,
+			RequestMethod:  "POST",
+			RequestParams:  []byte("{}"),
+			RequestPath:    "/users",
+			RecoveryPoint:  idempotency.RecoveryPointSignupStarted,
+		})
+		assert.Error(t, err)
+	})
 }
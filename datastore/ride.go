@@ -19,3 +19,21 @@ func RideWithIdemKeyID(kid int64) data.SelectCriteria {
 		return q.Where("idempotency_key_id = ?", kid)
 	}
 }
+
+func RideWithID(id int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("id = ?", id)
+	}
+}
+
+func RideWithStripeChargeID(id string) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("stripe_charge_id = ?", id)
+	}
+}
+
+func RideWithUserID(uid int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("user_id = ?", uid)
+	}
+}
@@ -6,6 +6,7 @@ package datastore
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
@@ -60,6 +61,8 @@ func TestRide(t *testing.T) {
 		OriginLon:        0.0,
 		TargetLat:        0.0,
 		TargetLon:        0.0,
+		Amount:           2000,
+		Currency:         "usd",
 		UserID:           userID,
 	}
 
@@ -79,6 +82,16 @@ func TestRide(t *testing.T) {
 		stripeID := gofakeit.UUID()
 		ride.StripeChargeID = &stripeID
 
+		refundID := gofakeit.UUID()
+		canceledAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+		ride.StripeRefundID = &refundID
+		ride.CanceledAt = &canceledAt
+
+		disputeID := gofakeit.UUID()
+		disputedAt := time.Date(2022, 1, 3, 4, 5, 6, 0, time.UTC)
+		ride.StripeDisputeID = &disputeID
+		ride.DisputedAt = &disputedAt
+
 		err := store.Update(ctx, ride)
 		assert.NoError(t, err)
 	})
@@ -89,4 +102,54 @@ func TestRide(t *testing.T) {
 			assert.Equal(t, *ride, res)
 		}
 	})
+
+	t.Run("Get Ride By Stripe Charge ID", func(t *testing.T) {
+		res, err := store.FindOne(ctx, RideWithStripeChargeID(*ride.StripeChargeID))
+		if assert.NoError(t, err) {
+			assert.Equal(t, *ride, res)
+		}
+
+		_, err = store.FindOne(ctx, RideWithStripeChargeID(gofakeit.UUID()))
+		assert.ErrorIs(t, err, data.ErrRecordNotFound)
+	})
+
+	t.Run("Get Ride By User ID", func(t *testing.T) {
+		res, err := store.FindOne(ctx, RideWithID(ride.ID), RideWithUserID(userID))
+		if assert.NoError(t, err) {
+			assert.Equal(t, *ride, res)
+		}
+
+		_, err = store.FindOne(ctx, RideWithID(ride.ID), RideWithUserID(userID+1))
+		assert.ErrorIs(t, err, data.ErrRecordNotFound)
+	})
+
+	t.Run("Page Rides Newest First", func(t *testing.T) {
+		older := ride
+		newer := &entity.Ride{Amount: 3000, Currency: "usd", UserID: userID}
+		err := store.Save(ctx, newer)
+		require.NoError(t, err)
+
+		pr := data.PageRequest{Limit: 1, OrderBy: []data.Order{data.Desc("id")}}
+		page, err := store.FindPage(ctx, pr, RideWithUserID(userID))
+		if assert.NoError(t, err) {
+			if assert.Len(t, page.Rows, 1) {
+				assert.Equal(t, newer.ID, page.Rows[0].ID)
+			}
+			assert.NotEmpty(t, page.NextCursor)
+		}
+
+		pr.Cursor = page.NextCursor
+		page, err = store.FindPage(ctx, pr, RideWithUserID(userID))
+		if assert.NoError(t, err) {
+			if assert.Len(t, page.Rows, 1) {
+				assert.Equal(t, older.ID, page.Rows[0].ID)
+			}
+			assert.Empty(t, page.NextCursor)
+		}
+
+		page, err = store.FindPage(ctx, data.PageRequest{}, RideWithUserID(userID+1))
+		if assert.NoError(t, err) {
+			assert.Empty(t, page.Rows)
+		}
+	})
 }
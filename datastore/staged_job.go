@@ -1,7 +1,10 @@
 package datastore
 
 import (
+	"time"
+
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/uptrace/bun"
 )
@@ -13,3 +16,32 @@ type StagedJob interface {
 func NewStagedJob(db bun.IDB) StagedJob {
 	return data.New[entity.StagedJob](db)
 }
+
+func StagedJobWithIDs(ids ...int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("id IN (?)", bun.In(ids))
+	}
+}
+
+func StagedJobWithNames(names ...stagedjob.JobName) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("job_name IN (?)", bun.In(names))
+	}
+}
+
+// StagedJobDue selects the staged jobs due to be run by 'now', which leaves out
+// the ones waiting to be retried and the dead ones.
+func StagedJobDue(now time.Time) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("failed_at IS NULL").Where("run_at <= ?", now)
+	}
+}
+
+// StagedJobLockBatch selects the oldest 'size' staged jobs and locks them for
+// the duration of the current transaction. Rows already locked by someone else
+// are skipped, so that concurrent enqueuers never work on the same jobs.
+func StagedJobLockBatch(size int) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Order("id").Limit(size).Apply(data.SkipLocked())
+	}
+}
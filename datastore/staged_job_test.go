@@ -6,10 +6,12 @@ package datastore
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/migrate"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/testcontainer"
@@ -45,4 +47,92 @@ func TestStagedJob(t *testing.T) {
 			assert.Greater(t, sj.ID, int64(0))
 		}
 	})
+
+	t.Run("Find Staged Jobs By Name", func(t *testing.T) {
+		other := &entity.StagedJob{
+			JobName: stagedjob.JobName("other_job"),
+			JobArgs: []byte("{\"data\": \"bar\"}"),
+		}
+		err := store.Save(ctx, other)
+		require.NoError(t, err)
+
+		res, err := store.FindAll(ctx, StagedJobWithNames(stagedjob.JobNameSendReceipt))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 1)
+			assert.Equal(t, stagedjob.JobNameSendReceipt, res[0].JobName)
+		}
+	})
+
+	t.Run("Lock Staged Jobs Batch", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer func() { _ = tx.Rollback() }()
+
+		// the first job gets locked by the transaction above
+		locked, err := NewStagedJob(tx).FindAll(ctx, StagedJobLockBatch(1))
+		require.NoError(t, err)
+		require.Len(t, locked, 1)
+
+		// so it must be skipped by anyone else looking for jobs
+		res, err := store.FindAll(ctx, StagedJobLockBatch(10))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 1)
+			assert.NotEqual(t, locked[0].ID, res[0].ID)
+		}
+	})
+	t.Run("Update Staged Jobs Args", func(t *testing.T) {
+		jobs, err := store.FindAll(ctx, data.OrderBy(data.Asc("id")))
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+
+		// job_args is JSONB, escapes included
+		jobs[0].JobArgs = []byte("{\"data\": \"foo \\\"bar\\\"\"}")
+		jobs[1].JobArgs = []byte("{\"data\": [1, 2]}")
+
+		n, err := store.UpdateMany(ctx, jobs, "job_args")
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		res, err := store.FindAll(ctx, data.OrderBy(data.Asc("id")))
+		if assert.NoError(t, err) && assert.Len(t, res, 2) {
+			assert.JSONEq(t, string(jobs[0].JobArgs), string(res[0].JobArgs))
+			assert.JSONEq(t, string(jobs[1].JobArgs), string(res[1].JobArgs))
+		}
+	})
+	t.Run("Find Due Staged Jobs", func(t *testing.T) {
+		now := time.Now().UTC()
+		failedAt := now.Add(-1 * time.Minute)
+
+		retried := &entity.StagedJob{
+			JobName:  stagedjob.JobNameSendReceipt,
+			JobArgs:  []byte("{}"),
+			Attempts: 1,
+			RunAt:    now.Add(time.Minute),
+		}
+		dead := &entity.StagedJob{
+			JobName:   stagedjob.JobNameSendReceipt,
+			JobArgs:   []byte("{}"),
+			Attempts:  5,
+			LastError: "err handler",
+			FailedAt:  &failedAt,
+		}
+		for _, sj := range []*entity.StagedJob{retried, dead} {
+			require.NoError(t, store.Save(ctx, sj))
+		}
+
+		res, err := store.FindAll(ctx, StagedJobDue(now))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 2)
+			for _, sj := range res {
+				assert.NotEqual(t, retried.ID, sj.ID)
+				assert.NotEqual(t, dead.ID, sj.ID)
+			}
+		}
+
+		// the retried job is due once its time has come
+		res, err = store.FindAll(ctx, StagedJobDue(now.Add(2*time.Minute)))
+		if assert.NoError(t, err) {
+			assert.Len(t, res, 3)
+		}
+	})
 }
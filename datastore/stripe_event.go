@@ -0,0 +1,21 @@
+package datastore
+
+import (
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/uptrace/bun"
+)
+
+type StripeEvent interface {
+	data.ICRUDStore[entity.StripeEvent]
+}
+
+func NewStripeEvent(db bun.IDB) StripeEvent {
+	return data.New[entity.StripeEvent](db)
+}
+
+func StripeEventWithEventID(id string) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("stripe_event_id = ?", id)
+	}
+}
@@ -0,0 +1,72 @@
+//go:build integration
+// +build integration
+
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/migrate"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/testcontainer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripeEvent(t *testing.T) {
+	ctx := context.Background()
+
+	// database up
+	dsn, terminate, err := testcontainer.NewPostgresContainer()
+	require.NoError(t, err)
+	defer func() { _ = terminate(ctx) }()
+
+	// migrations up
+	err = migrate.Up(dsn, "db/migrations")
+	require.NoError(t, err)
+
+	// connect to database
+	db, _ := db.Connect(config.Config{DBSource: dsn})
+	store := NewStripeEvent(db)
+
+	evt := &entity.StripeEvent{
+		StripeEventID: "evt_123",
+		Type:          "charge.refunded",
+		Data:          []byte("{\"id\": \"evt_123\"}"),
+	}
+
+	t.Run("Stripe Event not found", func(t *testing.T) {
+		_, err := store.FindOne(ctx, StripeEventWithEventID(evt.StripeEventID))
+		assert.ErrorIs(t, err, data.ErrRecordNotFound)
+	})
+
+	t.Run("Create Stripe Event", func(t *testing.T) {
+		err := store.Save(ctx, evt)
+		if assert.NoError(t, err) {
+			assert.Greater(t, evt.ID, int64(0))
+		}
+	})
+
+	t.Run("Get Stripe Event By Event ID", func(t *testing.T) {
+		res, err := store.FindOne(ctx, StripeEventWithEventID(evt.StripeEventID))
+		if assert.NoError(t, err) {
+			assert.Equal(t, evt.ID, res.ID)
+			assert.Equal(t, evt.Type, res.Type)
+			assert.JSONEq(t, string(evt.Data), string(res.Data))
+		}
+	})
+
+	t.Run("Duplicate Stripe Event", func(t *testing.T) {
+		dup := &entity.StripeEvent{
+			StripeEventID: evt.StripeEventID,
+			Type:          evt.Type,
+			Data:          evt.Data,
+		}
+		err := store.Save(ctx, dup)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,94 @@
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+const (
+	defaultMaxRetries = 3
+	baseRetryDelay    = 10 * time.Millisecond
+	maxRetryDelay     = time.Second
+)
+
+// Option configures the DB transaction a UnitOfWorkBlock is run in.
+type Option func(*options)
+
+type options struct {
+	tx         sql.TxOptions
+	maxRetries int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{maxRetries: defaultMaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithIsolation sets the transaction's isolation level, by default it's the
+// one set for the database (READ COMMITTED for Postgres).
+func WithIsolation(level sql.IsolationLevel) Option {
+	return func(o *options) {
+		o.tx.Isolation = level
+	}
+}
+
+// ReadOnly runs the transaction in read-only mode.
+func ReadOnly() Option {
+	return func(o *options) {
+		o.tx.ReadOnly = true
+	}
+}
+
+// WithMaxRetries sets how many times the UnitOfWorkBlock is re-run when its
+// transaction is aborted due to a serialization failure or a deadlock. Zero
+// disables retries altogether.
+func WithMaxRetries(n int) Option {
+	return func(o *options) {
+		o.maxRetries = n
+	}
+}
+
+// retryable tells whether the transaction was aborted by Postgres due to a
+// conflict with a concurrent one, so that it's safe to run it once again.
+func retryable(err error) bool {
+	switch data.SQLState(err) {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	return false
+}
+
+// retry calls 'fn' until it succeeds, fails with an error other than a
+// transaction conflict, or the max number of retries is reached. Retries are
+// spaced out by an exponential backoff with full jitter.
+func retry(ctx context.Context, maxRetries int, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= maxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	d := baseRetryDelay << attempt
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1) //nolint:gosec // jitter doesn't need a secure source
+}
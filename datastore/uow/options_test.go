@@ -0,0 +1,142 @@
+//go:build unit
+// +build unit
+
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sqlStateErr string
+
+func (e sqlStateErr) Field(k byte) string {
+	if k == 'C' {
+		return string(e)
+	}
+	return ""
+}
+
+func (e sqlStateErr) Error() string {
+	return fmt.Sprintf("SQLSTATE=%s", string(e))
+}
+
+func TestOptions(t *testing.T) {
+	o := newOptions()
+	assert.Equal(t, defaultMaxRetries, o.maxRetries)
+	assert.Equal(t, sql.TxOptions{}, o.tx)
+
+	o = newOptions(WithIsolation(sql.LevelSerializable), ReadOnly(), WithMaxRetries(0))
+	assert.Equal(t, 0, o.maxRetries)
+	assert.Equal(t, sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, o.tx)
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{err: nil, retryable: false},
+		{err: errors.New("foo"), retryable: false},
+		{err: sqlStateErr("23505"), retryable: false},
+		{err: sqlStateErr("40001"), retryable: true},
+		{err: sqlStateErr("40P01"), retryable: true},
+		{err: fmt.Errorf("commit: %w", sqlStateErr("40001")), retryable: true},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.retryable, retryable(tc.err), tc.err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success on first run", func(t *testing.T) {
+		var calls int
+		err := retry(ctx, 3, func() error {
+			calls++
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("No retry on other errors", func(t *testing.T) {
+		retErr := errors.New("foo")
+
+		var calls int
+		err := retry(ctx, 3, func() error {
+			calls++
+			return retErr
+		})
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Retry on conflicts", func(t *testing.T) {
+		var calls int
+		err := retry(ctx, 3, func() error {
+			calls++
+			if calls < 3 {
+				return sqlStateErr("40001")
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Give up after max retries", func(t *testing.T) {
+		var calls int
+		err := retry(ctx, 2, func() error {
+			calls++
+			return sqlStateErr("40P01")
+		})
+
+		assert.Equal(t, sqlStateErr("40P01"), err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Retries disabled", func(t *testing.T) {
+		var calls int
+		err := retry(ctx, 0, func() error {
+			calls++
+			return sqlStateErr("40001")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Stop on context canceled", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		var calls int
+		err := retry(cancelCtx, 3, func() error {
+			calls++
+			return sqlStateErr("40001")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 100; attempt++ {
+		d := backoff(attempt)
+		assert.Greater(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, maxRetryDelay)
+	}
+}
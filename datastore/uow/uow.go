@@ -2,7 +2,6 @@ package uow
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/uptrace/bun"
@@ -13,6 +12,7 @@ type uowStore struct {
 	idemKeys     datastore.IdempotencyKey
 	rides        datastore.Ride
 	stagedJobs   datastore.StagedJob
+	stripeEvents datastore.StripeEvent
 	users        datastore.User
 }
 
@@ -24,6 +24,7 @@ type UnitOfWorkStore interface {
 	IdempotencyKeys() datastore.IdempotencyKey
 	Rides() datastore.Ride
 	StagedJobs() datastore.StagedJob
+	StripeEvents() datastore.StripeEvent
 	Users() datastore.User
 }
 
@@ -43,6 +44,10 @@ func (u uowStore) StagedJobs() datastore.StagedJob {
 	return u.stagedJobs
 }
 
+func (u uowStore) StripeEvents() datastore.StripeEvent {
+	return u.stripeEvents
+}
+
 func (u uowStore) Users() datastore.User {
 	return u.users
 }
@@ -54,7 +59,7 @@ type unitOfWork struct {
 }
 
 type UnitOfWork interface {
-	Do(context.Context, UnitOfWorkBlock) error
+	Do(context.Context, UnitOfWorkBlock, ...Option) error
 }
 
 func New(db *bun.DB) UnitOfWork {
@@ -62,15 +67,22 @@ func New(db *bun.DB) UnitOfWork {
 }
 
 // Do executes the given UnitOfWorkBlock atomically (iniside a DB transaction).
-func (s *unitOfWork) Do(ctx context.Context, fn UnitOfWorkBlock) error {
-	return s.conn.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
-		newStore := &uowStore{
-			auditRecords: datastore.NewAuditRecord(tx),
-			idemKeys:     datastore.NewIdempotencyKey(tx),
-			rides:        datastore.NewRide(tx),
-			stagedJobs:   datastore.NewStagedJob(tx),
-			users:        datastore.NewUser(tx),
-		}
-		return fn(newStore)
+// Transactions aborted due to serialization failures or deadlocks are retried
+// from scratch, so the block must not leak any changes made by a failed run.
+func (s *unitOfWork) Do(ctx context.Context, fn UnitOfWorkBlock, opts ...Option) error {
+	o := newOptions(opts...)
+
+	return retry(ctx, o.maxRetries, func() error {
+		return s.conn.RunInTx(ctx, &o.tx, func(ctx context.Context, tx bun.Tx) error {
+			newStore := &uowStore{
+				auditRecords: datastore.NewAuditRecord(tx),
+				idemKeys:     datastore.NewIdempotencyKey(tx),
+				rides:        datastore.NewRide(tx),
+				stagedJobs:   datastore.NewStagedJob(tx),
+				stripeEvents: datastore.NewStripeEvent(tx),
+				users:        datastore.NewUser(tx),
+			}
+			return fn(newStore)
+		})
 	})
 }
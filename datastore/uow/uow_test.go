@@ -5,6 +5,7 @@ package uow
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
 
@@ -65,6 +66,8 @@ func TestSQLStore(t *testing.T) {
 		OriginLon:        0.0,
 		TargetLat:        0.0,
 		TargetLon:        0.0,
+		Amount:           2000,
+		Currency:         "usd",
 		UserID:           userID,
 	}
 
@@ -170,6 +173,18 @@ func TestSQLStore(t *testing.T) {
 		}
 	})
 
+	t.Run("Rollback on read-only", func(t *testing.T) {
+		err = uow.Do(ctx, func(uows UnitOfWorkStore) error {
+			rd := *ride
+			return uows.Rides().Save(ctx, &rd)
+		}, ReadOnly(), WithIsolation(sql.LevelSerializable))
+
+		if assert.Error(t, err) {
+			_, err = rides.FindOne(ctx, datastore.RideWithIdemKeyID(keyID))
+			assert.ErrorIs(t, err, data.ErrRecordNotFound)
+		}
+	})
+
 	t.Run("Commit on success", func(t *testing.T) {
 		_, err := rides.FindOne(ctx, datastore.RideWithIdemKeyID(keyID))
 		require.ErrorIs(t, err, data.ErrRecordNotFound)
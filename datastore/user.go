@@ -21,3 +21,9 @@ func UserWithEmail(e string) data.SelectCriteria {
 		return q.Where("email = ?", strings.ToLower(e))
 	}
 }
+
+func UserWithID(id int64) data.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("id = ?", id)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/db"
@@ -54,4 +55,34 @@ func TestUser(t *testing.T) {
 		assert.Equal(t, userID, u.ID)
 		assert.Equal(t, userEmail, u.Email)
 	})
+
+	t.Run("User found by ID", func(t *testing.T) {
+		u, err := store.FindOne(ctx, UserWithID(userID))
+		assert.NoError(t, err)
+		assert.Equal(t, userID, u.ID)
+		assert.Equal(t, userEmail, u.Email)
+	})
+
+	t.Run("User without a customer", func(t *testing.T) {
+		u := &entity.User{ID: userID + 1, Email: gofakeit.Email()}
+		err := store.Save(ctx, u)
+		require.NoError(t, err)
+
+		res, err := store.FindOne(ctx, UserWithID(u.ID))
+		if assert.NoError(t, err) {
+			assert.Empty(t, res.StripeCustomerID)
+			assert.False(t, res.IsAdmin)
+		}
+	})
+
+	t.Run("Admin user", func(t *testing.T) {
+		u := &entity.User{ID: userID + 2, Email: gofakeit.Email(), IsAdmin: true}
+		err := store.Save(ctx, u)
+		require.NoError(t, err)
+
+		res, err := store.FindOne(ctx, UserWithID(u.ID))
+		if assert.NoError(t, err) {
+			assert.True(t, res.IsAdmin)
+		}
+	})
 }
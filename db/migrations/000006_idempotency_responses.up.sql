@@ -0,0 +1,9 @@
+--
+-- Finished requests may store any JSON body along with a selected set of
+-- response headers, such as Location. Bodies are kept as plain text since
+-- JSONB normalizes whitespace and key order, and replays must be
+-- byte-identical to the original response.
+--
+ALTER TABLE idempotency_keys
+    ALTER COLUMN response_body TYPE TEXT USING response_body::TEXT,
+    ADD COLUMN response_headers JSONB NULL;
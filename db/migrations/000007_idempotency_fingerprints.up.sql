@@ -0,0 +1,8 @@
+--
+-- SHA-256 fingerprint of the incoming request, computed out of its method,
+-- path and normalized body. Keys stored before it are left empty and get
+-- their fingerprint computed on the fly.
+--
+ALTER TABLE idempotency_keys
+    ADD COLUMN request_fingerprint TEXT NULL
+        CHECK (char_length(request_fingerprint) = 64);
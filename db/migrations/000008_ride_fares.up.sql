@@ -0,0 +1,14 @@
+--
+-- Fare charged for a ride, computed from the distance flown. The amount is
+-- given in the currency's smallest unit (e.g., cents for USD). Rides created
+-- so far were all charged a fixed $20.
+--
+ALTER TABLE rides
+    ADD COLUMN amount   BIGINT NOT NULL DEFAULT 2000
+        CHECK (amount >= 0),
+    ADD COLUMN currency TEXT   NOT NULL DEFAULT 'usd'
+        CHECK (char_length(currency) = 3);
+
+ALTER TABLE rides
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN currency DROP DEFAULT;
@@ -0,0 +1,10 @@
+--
+-- Charged rides can be canceled, getting their charge refunded.
+--
+ALTER TABLE rides
+    -- NULL for rides that haven't been canceled
+    ADD COLUMN canceled_at      TIMESTAMPTZ     NULL,
+
+    -- ID of Stripe refund like re_123; NULL until we have one
+    ADD COLUMN stripe_refund_id TEXT            UNIQUE
+        CHECK (char_length(stripe_refund_id) <= 50);
@@ -0,0 +1,22 @@
+--
+-- A relation to hold the API keys used by users to authenticate their
+-- requests. Keys are random, so a SHA-256 hash is enough to keep them safe
+-- at rest, while still allowing them to be looked up.
+--
+CREATE TABLE api_keys (
+    id                 BIGSERIAL       PRIMARY KEY,
+    created_at         TIMESTAMPTZ     NOT NULL DEFAULT now(),
+
+    -- hex encoded SHA-256 hash of the key
+    key_hash           TEXT            NOT NULL UNIQUE
+        CHECK (char_length(key_hash) = 64),
+
+    -- revoked keys are kept around, but can't be used anymore
+    revoked_at         TIMESTAMPTZ     NULL,
+
+    user_id            BIGINT          NOT NULL
+        REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX api_keys_user_id
+    ON api_keys (user_id);
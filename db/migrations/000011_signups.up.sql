@@ -0,0 +1,17 @@
+--
+-- Users sign up through the API now, which first inserts the user and only
+-- then creates its Stripe customer. The customer stays NULL in between.
+--
+ALTER TABLE users
+    ALTER COLUMN stripe_customer_id DROP NOT NULL;
+
+--
+-- Signups are protected by idempotency keys as well, but they're sent by no
+-- user at all. Those keys are unique on their own.
+--
+ALTER TABLE idempotency_keys
+    ALTER COLUMN user_id DROP NOT NULL;
+
+CREATE UNIQUE INDEX idempotency_keys_anonymous_idempotency_key
+    ON idempotency_keys (idempotency_key)
+    WHERE user_id IS NULL;
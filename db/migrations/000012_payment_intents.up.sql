@@ -0,0 +1,13 @@
+--
+-- Rides are charged through Stripe PaymentIntents, which may need the customer
+-- to take some action (e.g. 3-D Secure) before they succeed. The charge ID is
+-- only set once they do.
+--
+ALTER TABLE rides
+    -- ID of Stripe payment intent like pi_123; NULL until we have one
+    ADD COLUMN stripe_payment_intent_id     TEXT    UNIQUE
+        CHECK (char_length(stripe_payment_intent_id) <= 50),
+
+    -- last known status of the payment intent, like requires_action
+    ADD COLUMN stripe_payment_intent_status TEXT    NULL
+        CHECK (char_length(stripe_payment_intent_status) <= 50);
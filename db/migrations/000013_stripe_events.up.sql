@@ -0,0 +1,30 @@
+--
+-- A relation to hold the events Stripe sends us through webhooks. They may be
+-- delivered more than once, so they're deduplicated by their IDs.
+--
+CREATE TABLE stripe_events (
+    id                 BIGSERIAL       PRIMARY KEY,
+    created_at         TIMESTAMPTZ     NOT NULL DEFAULT now(),
+
+    -- ID of Stripe event like evt_123
+    stripe_event_id    TEXT            NOT NULL UNIQUE
+        CHECK (char_length(stripe_event_id) <= 255),
+
+    -- event type, for example "charge.refunded"
+    type               TEXT            NOT NULL
+        CHECK (char_length(type) <= 100),
+
+    -- the event exactly as it was received
+    data               JSONB           NOT NULL
+);
+
+--
+-- Charges may be disputed by customers with their banks.
+--
+ALTER TABLE rides
+    -- NULL for rides that haven't been disputed
+    ADD COLUMN disputed_at       TIMESTAMPTZ     NULL,
+
+    -- ID of Stripe dispute like dp_123; NULL until we have one
+    ADD COLUMN stripe_dispute_id TEXT            UNIQUE
+        CHECK (char_length(stripe_dispute_id) <= 50);
@@ -0,0 +1,20 @@
+--
+-- Admins can query the audit records of every user through the API.
+--
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+--
+-- Audit records are queried newest first, filtered by any of these.
+--
+CREATE INDEX audit_records_user_id
+    ON audit_records (user_id);
+
+CREATE INDEX audit_records_resource
+    ON audit_records (resource_type, resource_id);
+
+CREATE INDEX audit_records_created_at
+    ON audit_records (created_at);
+
+CREATE INDEX audit_records_origin_ip
+    ON audit_records USING gist (origin_ip inet_ops);
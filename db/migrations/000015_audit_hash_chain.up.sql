@@ -0,0 +1,29 @@
+--
+-- Audit records are chained together by their hashes, each one computed over
+-- the record's content plus the hash of the record before it. Editing or
+-- deleting any record breaks the chain from that point on.
+--
+ALTER TABLE audit_records
+    -- hex encoded SHA-256 hashes; NULL for records written before the chain
+    ADD COLUMN prev_hash TEXT NULL
+        CHECK (char_length(prev_hash) = 64),
+
+    ADD COLUMN hash      TEXT NULL UNIQUE
+        CHECK (char_length(hash) = 64);
+
+--
+-- A single row relation pointing at the newest record of the chain. Appending
+-- a record locks it, so that records are chained one after the other even by
+-- concurrent transactions. It also tells whether the newest records of the
+-- chain were deleted.
+--
+CREATE TABLE audit_chain_head (
+    id                 SMALLINT        PRIMARY KEY
+        CHECK (id = 1),
+
+    -- hash of the newest record; NULL while there's none
+    hash               TEXT            NULL
+        CHECK (char_length(hash) = 64)
+);
+
+INSERT INTO audit_chain_head (id) VALUES (1);
@@ -0,0 +1,10 @@
+--
+-- Idempotency keys and rides are updated under optimistic locking: updates
+-- only go through if the row's version is still the one it was read with, and
+-- bump it, so that concurrent updates can't silently overwrite each other.
+--
+ALTER TABLE idempotency_keys
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE rides
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
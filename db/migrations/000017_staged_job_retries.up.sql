@@ -0,0 +1,15 @@
+--
+-- Staged jobs whose handler fails are retried with a backoff: 'attempts'
+-- counts their failed runs and they aren't run again before 'run_at'. Once
+-- they've failed too many times they're dead, 'failed_at' is set and they're
+-- left aside for someone to look into 'last_error'.
+--
+ALTER TABLE staged_jobs
+    ADD COLUMN attempts   INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN run_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN last_error TEXT        NULL,
+    ADD COLUMN failed_at  TIMESTAMPTZ NULL;
+
+CREATE INDEX staged_jobs_run_at
+    ON staged_jobs (run_at)
+    WHERE failed_at IS NULL;
@@ -0,0 +1,5 @@
+--
+-- Rides are listed and fetched by their user, newest first.
+--
+CREATE INDEX rides_user_id
+    ON rides (user_id, id DESC);
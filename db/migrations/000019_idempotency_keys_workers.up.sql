@@ -0,0 +1,12 @@
+--
+-- The completer looks for keys abandoned halfway through since some time and
+-- the reaper for keys finished before their retention window: each one only
+-- ever scans the keys at the recovery points it cares about.
+--
+CREATE INDEX idempotency_keys_abandoned
+    ON idempotency_keys (last_run_at)
+    WHERE recovery_point NOT IN ('FINISHED', 'AWAITING_ACTION');
+
+CREATE INDEX idempotency_keys_finished
+    ON idempotency_keys (created_at)
+    WHERE recovery_point = 'FINISHED';
@@ -0,0 +1,7 @@
+--
+-- Besides the abandoned keys, the completer checks on the keys awaiting the
+-- customer's action every once in a while.
+--
+CREATE INDEX idempotency_keys_awaiting_action
+    ON idempotency_keys (last_run_at)
+    WHERE recovery_point = 'AWAITING_ACTION';
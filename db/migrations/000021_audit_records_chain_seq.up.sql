@@ -0,0 +1,32 @@
+--
+-- Audit records are saved unchained, along with whatever they audit, and only
+-- linked to the chain afterwards by the audit chainer, in short transactions
+-- of its own. That keeps concurrent requests from waiting on the chain head,
+-- though records no longer commit in id order, so chain_seq tells where each
+-- one sits in the chain instead.
+--
+ALTER TABLE audit_records
+    -- NULL for records not chained yet
+    ADD COLUMN chain_seq BIGINT NULL UNIQUE;
+
+-- records were chained in id order so far
+UPDATE audit_records r
+   SET chain_seq = c.seq
+  FROM (
+    SELECT id, row_number() OVER (ORDER BY id) AS seq
+      FROM audit_records
+     WHERE hash IS NOT NULL
+  ) c
+ WHERE r.id = c.id;
+
+ALTER TABLE audit_chain_head
+    -- chain_seq of the newest record; 0 while there's none
+    ADD COLUMN seq BIGINT NOT NULL DEFAULT 0;
+
+UPDATE audit_chain_head
+   SET seq = (SELECT count(*) FROM audit_records WHERE hash IS NOT NULL);
+
+-- the chainer picks the records not chained yet, oldest first
+CREATE INDEX audit_records_unchained
+    ON audit_records (id)
+    WHERE chain_seq IS NULL;
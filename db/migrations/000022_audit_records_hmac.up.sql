@@ -0,0 +1,16 @@
+--
+-- Records are hashed with HMAC-SHA256 from now on, keyed by a secret kept out
+-- of the database (the AUDIT_CHAIN_KEY setting), so that whoever gets to write
+-- to it can't rehash the records they edit. Records chained so far, hashed
+-- with plain SHA-256, are unlinked to be chained again by the audit chainer:
+-- verify the chain before migrating, since it's trusted as it is from then on.
+--
+UPDATE audit_records
+   SET prev_hash = NULL,
+       hash      = NULL,
+       chain_seq = NULL
+ WHERE chain_seq IS NOT NULL;
+
+UPDATE audit_chain_head
+   SET hash = NULL,
+       seq  = 0;
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// APIKey grants its user access to the API. Only a hash of the key is
+// stored, the key itself is handed out once when created.
+type APIKey struct {
+	ID        int64
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	KeyHash   string
+	RevokedAt *time.Time
+	UserID    int64
+}
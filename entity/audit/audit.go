@@ -3,7 +3,24 @@ package audit
 type Action string
 
 const (
-	ActionCreateRide Action = "CREATE_RIDE"
+	ActionCreateRide  Action = "CREATE_RIDE"
+	ActionCancelRide  Action = "CANCEL_RIDE"
+	ActionCreateUser  Action = "CREATE_USER"
+	ActionDisputeRide Action = "DISPUTE_RIDE"
+	ActionRefundRide  Action = "REFUND_RIDE"
+
+	// actions taken along the atomic phases of rides
+	ActionChargeRide              Action = "CHARGE_RIDE"
+	ActionChargePending           Action = "CHARGE_PENDING"
+	ActionChargeFailed            Action = "CHARGE_FAILED"
+	ActionStageReceipt            Action = "STAGE_RECEIPT"
+	ActionCancelRejected          Action = "CANCEL_REJECTED"
+	ActionRefundFailed            Action = "REFUND_FAILED"
+	ActionStageCancellationNotice Action = "STAGE_CANCELLATION_NOTICE"
+
+	// actions taken on idempotency keys themselves
+	ActionIdemKeyLockStolen Action = "IDEM_KEY_LOCK_STOLEN"
+	ActionIdemKeyMismatch   Action = "IDEM_KEY_MISMATCH"
 )
 
 func (a Action) String() string {
@@ -14,6 +31,9 @@ type ResourceType string
 
 const (
 	ResourceTypeRide ResourceType = "RIDE"
+	ResourceTypeUser ResourceType = "USER"
+
+	ResourceTypeIdempotencyKey ResourceType = "IDEMPOTENCY_KEY"
 )
 
 func (a ResourceType) String() string {
@@ -0,0 +1,17 @@
+package audit
+
+import "time"
+
+// Filter narrows audit records down. Its zero valued fields match any record.
+type Filter struct {
+	UserID       int64
+	Action       Action
+	ResourceType ResourceType
+	ResourceID   int64
+	// Network holds the origin IPs to match in CIDR notation, or a single IP.
+	Network string
+	// CreatedFrom and CreatedBefore bound the records' creation time, the
+	// former inclusively and the latter exclusively.
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+}
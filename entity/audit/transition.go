@@ -0,0 +1,15 @@
+package audit
+
+import "github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+
+// Transition is the Data of audit records written as requests protected by
+// idempotency keys move from one recovery point to another. Following the
+// records of a key in order tells the whole history of its request.
+type Transition struct {
+	IdempotencyKeyID    int64                     `json:"idempotency_key_id"`
+	RecoveryPointBefore idempotency.RecoveryPoint `json:"recovery_point_before"`
+	RecoveryPointAfter  idempotency.RecoveryPoint `json:"recovery_point_after"`
+	// Details holds whatever else is worth knowing about the transition, such
+	// as the ids handed out by Stripe or the reason a request was rejected.
+	Details interface{} `json:"details,omitempty"`
+}
@@ -1,19 +1,122 @@
 package entity
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
 )
 
 type AuditRecord struct {
-	ID           int64
-	Action       audit.Action
-	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
-	Data         json.RawMessage
-	OriginIP     string
-	ResourceID   int64
-	ResourceType audit.ResourceType
-	UserID       int64
+	ID           int64              `json:"id"`
+	Action       audit.Action       `json:"action"`
+	CreatedAt    time.Time          `json:"created_at" bun:",nullzero,notnull,default:current_timestamp"`
+	Data         json.RawMessage    `json:"data"`
+	OriginIP     string             `json:"origin_ip"`
+	ResourceID   int64              `json:"resource_id"`
+	ResourceType audit.ResourceType `json:"resource_type"`
+	UserID       int64              `json:"user_id"`
+	// PrevHash is the hash of the record before this one in the chain, it's
+	// empty for the very first one.
+	PrevHash string `json:"prev_hash" bun:",nullzero"`
+	// Hash is empty for records not chained yet.
+	Hash string `json:"hash" bun:",nullzero"`
+	// ChainSeq is the position of the record in the chain, starting at 1.
+	// It's zero for records not chained yet.
+	ChainSeq int64 `json:"chain_seq" bun:",nullzero"`
+}
+
+// AuditChainHead points at the newest record of the chain. Both fields are
+// zero while there's none.
+type AuditChainHead struct {
+	Hash string
+	Seq  int64
+}
+
+// canonicalAuditRecord holds the content of a record the way it's hashed.
+// Fields are normalized, so that the hash doesn't change once the record is
+// stored and read back.
+type canonicalAuditRecord struct {
+	PrevHash     string          `json:"prev_hash"`
+	Action       string          `json:"action"`
+	CreatedAt    string          `json:"created_at"`
+	Data         json.RawMessage `json:"data"`
+	OriginIP     string          `json:"origin_ip"`
+	ResourceID   int64           `json:"resource_id"`
+	ResourceType string          `json:"resource_type"`
+	UserID       int64           `json:"user_id"`
+}
+
+// ChainHash returns the hex encoded HMAC-SHA256 of the record's content plus
+// its PrevHash, keyed by the given secret. Keeping the key out of the database
+// is what stops whoever can write to it from rehashing the records they edit.
+// The ID is left out, since it's only known once stored, while CreatedAt only
+// counts up to microseconds, which is what Postgres keeps.
+func (ar AuditRecord) ChainHash(key []byte) (string, error) {
+	data, err := canonicalJSON(ar.Data)
+	if err != nil {
+		return "", fmt.Errorf("audit record data: %w", err)
+	}
+
+	ip, err := canonicalPrefix(ar.OriginIP)
+	if err != nil {
+		return "", fmt.Errorf("audit record origin ip: %w", err)
+	}
+
+	b, err := json.Marshal(canonicalAuditRecord{
+		PrevHash:     ar.PrevHash,
+		Action:       ar.Action.String(),
+		CreatedAt:    ar.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		Data:         data,
+		OriginIP:     ip,
+		ResourceID:   ar.ResourceID,
+		ResourceType: ar.ResourceType.String(),
+		UserID:       ar.UserID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// canonicalJSON re-encodes JSON with sorted keys and no insignificant spaces,
+// since Postgres' JSONB doesn't keep them as they were written. Numbers are
+// kept as written, so they must be written the way JSONB does already, which
+// is why records are only hashed once Postgres normalized their data.
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// canonicalPrefix writes IPs in CIDR notation, the way Postgres returns them.
+func canonicalPrefix(s string) (string, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", err
+		}
+		return p.Masked().String(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
 }
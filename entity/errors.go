@@ -1,6 +1,9 @@
 package entity
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrPermissionDenied            = errors.New("permission denied")
@@ -10,5 +13,14 @@ var (
 	ErrIdemKeyUnknownRecoveryPoint = errors.New("unknown recovery point")
 	ErrPaymentProvider             = errors.New("card error from payment processor")
 	ErrPaymentProviderGeneric      = errors.New("generic error from payment processor")
+	ErrPaymentActionRequired       = errors.New("payment requires customer action")
+	ErrPaymentProcessing           = errors.New("payment still processing")
 	ErrInternalError               = errors.New("internal error")
+	ErrRideAlreadyCanceled         = errors.New("ride already canceled")
+	ErrRideNotCharged              = errors.New("ride not charged yet")
+	ErrEmailTaken                  = errors.New("email already taken")
+
+	ErrIdemKeyMethodMismatch = fmt.Errorf("%w: request method differs", ErrIdemKeyParamsMismatch)
+	ErrIdemKeyPathMismatch   = fmt.Errorf("%w: request path differs", ErrIdemKeyParamsMismatch)
+	ErrIdemKeyBodyMismatch   = fmt.Errorf("%w: request body differs", ErrIdemKeyParamsMismatch)
 )
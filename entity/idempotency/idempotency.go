@@ -11,6 +11,19 @@ const (
 	RecoveryPointCreated  RecoveryPoint = "CREATED"
 	RecoveryPointCharged  RecoveryPoint = "CHARGED"
 	RecoveryPointFinished RecoveryPoint = "FINISHED"
+
+	// RecoveryPointAwaitingAction holds rides whose payment waits on the
+	// customer, e.g. to authenticate it through 3-D Secure.
+	RecoveryPointAwaitingAction RecoveryPoint = "AWAITING_ACTION"
+
+	// recovery points of ride cancellations
+	RecoveryPointCancelStarted RecoveryPoint = "CANCEL_STARTED"
+	RecoveryPointRefunded      RecoveryPoint = "REFUNDED"
+
+	// recovery points of user signups
+	RecoveryPointSignupStarted   RecoveryPoint = "SIGNUP_STARTED"
+	RecoveryPointUserCreated     RecoveryPoint = "USER_CREATED"
+	RecoveryPointCustomerCreated RecoveryPoint = "CUSTOMER_CREATED"
 )
 
 func (r RecoveryPoint) String() string {
@@ -21,11 +34,18 @@ type ResponseCode int
 
 const (
 	ResponseCodeOK                ResponseCode = http.StatusOK
+	ResponseCodeNotFound          ResponseCode = http.StatusNotFound
 	ResponseCodeConflict          ResponseCode = http.StatusConflict
 	ResponseCodeErrPayment        ResponseCode = http.StatusPaymentRequired
 	ResponseCodeErrPaymentGeneric ResponseCode = http.StatusServiceUnavailable
 )
 
-type ResponseBody struct {
+// ResponseHeaders holds the selected set of headers, such as Location, that
+// are replayed along with a stored response.
+type ResponseHeaders map[string]string
+
+// Message is the response body for requests with nothing else to say than a
+// short message, such as errors.
+type Message struct {
 	Message string `json:"message"`
 }
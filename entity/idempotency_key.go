@@ -8,17 +8,20 @@ import (
 )
 
 type IdempotencyKey struct {
-	ID             int64
-	CreatedAt      time.Time `bun:",nullzero,notnull,default:current_timestamp"`
-	IdempotencyKey string
-	LastRunAt      time.Time
-	LockedAt       *time.Time
-	RequestMethod  string
-	RequestParams  json.RawMessage
-	RequestPath    string
-	ResponseCode   *idempotency.ResponseCode
-	ResponseBody   *idempotency.ResponseBody
-	RecoveryPoint  idempotency.RecoveryPoint
-	UserID         int64
-	User           *User `json:"-" bun:"-"`
+	ID                 int64
+	CreatedAt          time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	IdempotencyKey     string
+	LastRunAt          time.Time
+	LockedAt           *time.Time
+	RequestFingerprint string `bun:",nullzero"`
+	RequestMethod      string
+	RequestParams      json.RawMessage
+	RequestPath        string
+	ResponseCode       *idempotency.ResponseCode
+	ResponseBody       json.RawMessage
+	ResponseHeaders    idempotency.ResponseHeaders `bun:",nullzero"`
+	RecoveryPoint      idempotency.RecoveryPoint
+	UserID             int64 `bun:",nullzero"`
+	Version            int
+	User               *User `json:"-" bun:"-"`
 }
@@ -3,13 +3,22 @@ package entity
 import "time"
 
 type Ride struct {
-	ID               int64
-	CreatedAt        time.Time `bun:",nullzero,notnull,default:current_timestamp"`
-	IdempotencyKeyID *int64
-	OriginLat        float64 `json:"origin_lat"`
-	OriginLon        float64 `json:"origin_lon"`
-	TargetLat        float64 `json:"target_lat"`
-	TargetLon        float64 `json:"target_lon"`
-	StripeChargeID   *string
-	UserID           int64
+	ID                        int64      `json:"id"`
+	CreatedAt                 time.Time  `json:"created_at" bun:",nullzero,notnull,default:current_timestamp"`
+	IdempotencyKeyID          *int64     `json:"-"`
+	OriginLat                 float64    `json:"origin_lat"`
+	OriginLon                 float64    `json:"origin_lon"`
+	TargetLat                 float64    `json:"target_lat"`
+	TargetLon                 float64    `json:"target_lon"`
+	Amount                    int64      `json:"amount"`
+	Currency                  string     `json:"currency"`
+	StripeChargeID            *string    `json:"stripe_charge_id"`
+	StripePaymentIntentID     *string    `json:"stripe_payment_intent_id"`
+	StripePaymentIntentStatus *string    `json:"stripe_payment_intent_status"`
+	CanceledAt                *time.Time `json:"canceled_at"`
+	StripeRefundID            *string    `json:"stripe_refund_id"`
+	DisputedAt                *time.Time `json:"disputed_at"`
+	StripeDisputeID           *string    `json:"stripe_dispute_id"`
+	UserID                    int64      `json:"user_id"`
+	Version                   int        `json:"-"`
 }
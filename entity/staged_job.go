@@ -2,6 +2,7 @@ package entity
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
 )
@@ -10,4 +11,10 @@ type StagedJob struct {
 	ID      int64
 	JobName stagedjob.JobName
 	JobArgs json.RawMessage
+	// Attempts counts the failed runs of the job, which isn't run again before
+	// RunAt. Dead jobs, the ones that failed too many times, have FailedAt set.
+	Attempts  int
+	RunAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	LastError string    `bun:",nullzero"`
+	FailedAt  *time.Time
 }
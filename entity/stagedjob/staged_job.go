@@ -1,17 +1,75 @@
 package stagedjob
 
+import "errors"
+
 type JobName string
 
 const (
-	JobNameSendReceipt JobName = "send_ride_receipt"
+	JobNameSendReceipt            JobName = "send_ride_receipt"
+	JobNameSendCancellationNotice JobName = "send_ride_cancellation_notice"
+	JobNameSendWelcome            JobName = "send_welcome_email"
 )
 
 func (j JobName) String() string {
 	return string(j)
 }
 
+var (
+	errMissingCurrency = errors.New("currency is required")
+	errMissingEmail    = errors.New("email is required")
+	errMissingRideID   = errors.New("ride_id is required")
+	errMissingUserID   = errors.New("user_id is required")
+)
+
 type JobArgReceipt struct {
 	Amount   int64  `json:"amount"`
 	Currency string `json:"currency"`
+	// RideID is missing from receipts staged before it was added, which is
+	// why it isn't required.
+	RideID int64 `json:"ride_id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (a JobArgReceipt) Validate() error {
+	switch {
+	case a.Currency == "":
+		return errMissingCurrency
+	case a.UserID <= 0:
+		return errMissingUserID
+	}
+	return nil
+}
+
+type JobArgCancellationNotice struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	RideID   int64  `json:"ride_id"`
 	UserID   int64  `json:"user_id"`
 }
+
+func (a JobArgCancellationNotice) Validate() error {
+	switch {
+	case a.Currency == "":
+		return errMissingCurrency
+	case a.RideID <= 0:
+		return errMissingRideID
+	case a.UserID <= 0:
+		return errMissingUserID
+	}
+	return nil
+}
+
+type JobArgWelcome struct {
+	Email  string `json:"email"`
+	UserID int64  `json:"user_id"`
+}
+
+func (a JobArgWelcome) Validate() error {
+	switch {
+	case a.Email == "":
+		return errMissingEmail
+	case a.UserID <= 0:
+		return errMissingUserID
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StripeEvent is an event delivered by Stripe through webhooks, stored so that
+// redeliveries of the same event are only processed once.
+type StripeEvent struct {
+	ID            int64
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	StripeEventID string
+	Type          string
+	Data          json.RawMessage
+}
@@ -1,7 +1,9 @@
 package entity
 
 type User struct {
-	ID               int64
-	Email            string
-	StripeCustomerID string
+	ID               int64  `json:"id"`
+	Email            string `json:"email"`
+	StripeCustomerID string `json:"stripe_customer_id,omitempty" bun:",nullzero"`
+	// IsAdmin grants access to the admin endpoints, such as the audit log.
+	IsAdmin bool `json:"-"`
 }
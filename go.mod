@@ -11,6 +11,7 @@ require (
 	github.com/stretchr/testify v1.7.1
 	github.com/testcontainers/testcontainers-go v0.12.0
 	github.com/uptrace/bun/driver/pgdriver v1.0.20
+	go.uber.org/fx v1.17.1
 )
 
 require (
@@ -42,7 +43,6 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	go.uber.org/dig v1.14.0 // indirect
-	go.uber.org/fx v1.17.1 // indirect
 	go.uber.org/multierr v1.5.0 // indirect
 	go.uber.org/zap v1.16.0 // indirect
 	golang.org/x/text v0.3.7 // indirect
@@ -19,12 +19,12 @@ type AuditRecord struct {
 	mock.Mock
 }
 
-// Delete provides a mock function with given fields: _a0, _a1
-func (_m *AuditRecord) Delete(_a0 context.Context, _a1 *entity.AuditRecord) error {
+// Chain provides a mock function with given fields: _a0, _a1
+func (_m *AuditRecord) Chain(_a0 context.Context, _a1 []entity.AuditRecord) error {
 	ret := _m.Called(_a0, _a1)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *entity.AuditRecord) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.AuditRecord) error); ok {
 		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
@@ -33,6 +33,55 @@ func (_m *AuditRecord) Delete(_a0 context.Context, _a1 *entity.AuditRecord) erro
 	return r0
 }
 
+// ChainHead provides a mock function with given fields: _a0
+func (_m *AuditRecord) ChainHead(_a0 context.Context) (entity.AuditChainHead, error) {
+	ret := _m.Called(_a0)
+
+	var r0 entity.AuditChainHead
+	if rf, ok := ret.Get(0).(func(context.Context) entity.AuditChainHead); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(entity.AuditChainHead)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Count provides a mock function with given fields: _a0, _a1
+func (_m *AuditRecord) Count(_a0 context.Context, _a1 ...data.SelectCriteria) (int, error) {
+	_va := make([]interface{}, len(_a1))
+	for _i := range _a1 {
+		_va[_i] = _a1[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, ...data.SelectCriteria) int); ok {
+		r0 = rf(_a0, _a1...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindAll provides a mock function with given fields: _a0, _a1
 func (_m *AuditRecord) FindAll(_a0 context.Context, _a1 ...data.SelectCriteria) ([]entity.AuditRecord, error) {
 	_va := make([]interface{}, len(_a1))
@@ -91,22 +140,57 @@ func (_m *AuditRecord) FindOne(_a0 context.Context, _a1 ...data.SelectCriteria)
 	return r0, r1
 }
 
-// Save provides a mock function with given fields: _a0, _a1
-func (_m *AuditRecord) Save(_a0 context.Context, _a1 *entity.AuditRecord) error {
-	ret := _m.Called(_a0, _a1)
+// FindPage provides a mock function with given fields: _a0, _a1, _a2
+func (_m *AuditRecord) FindPage(_a0 context.Context, _a1 data.PageRequest, _a2 ...data.SelectCriteria) (data.Page[entity.AuditRecord], error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *entity.AuditRecord) error); ok {
-		r0 = rf(_a0, _a1)
+	var r0 data.Page[entity.AuditRecord]
+	if rf, ok := ret.Get(0).(func(context.Context, data.PageRequest, ...data.SelectCriteria) data.Page[entity.AuditRecord]); ok {
+		r0 = rf(_a0, _a1, _a2...)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(data.Page[entity.AuditRecord])
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, data.PageRequest, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// Update provides a mock function with given fields: _a0, _a1
-func (_m *AuditRecord) Update(_a0 context.Context, _a1 *entity.AuditRecord) error {
+// LockChainHead provides a mock function with given fields: _a0
+func (_m *AuditRecord) LockChainHead(_a0 context.Context) (entity.AuditChainHead, error) {
+	ret := _m.Called(_a0)
+
+	var r0 entity.AuditChainHead
+	if rf, ok := ret.Get(0).(func(context.Context) entity.AuditChainHead); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(entity.AuditChainHead)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Save provides a mock function with given fields: _a0, _a1
+func (_m *AuditRecord) Save(_a0 context.Context, _a1 *entity.AuditRecord) error {
 	ret := _m.Called(_a0, _a1)
 
 	var r0 error
@@ -19,6 +19,34 @@ type IdempotencyKey struct {
 	mock.Mock
 }
 
+// Count provides a mock function with given fields: _a0, _a1
+func (_m *IdempotencyKey) Count(_a0 context.Context, _a1 ...data.SelectCriteria) (int, error) {
+	_va := make([]interface{}, len(_a1))
+	for _i := range _a1 {
+		_va[_i] = _a1[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, ...data.SelectCriteria) int); ok {
+		r0 = rf(_a0, _a1...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: _a0, _a1
 func (_m *IdempotencyKey) Delete(_a0 context.Context, _a1 *entity.IdempotencyKey) error {
 	ret := _m.Called(_a0, _a1)
@@ -33,6 +61,34 @@ func (_m *IdempotencyKey) Delete(_a0 context.Context, _a1 *entity.IdempotencyKey
 	return r0
 }
 
+// DeleteWhere provides a mock function with given fields: _a0, _a1
+func (_m *IdempotencyKey) DeleteWhere(_a0 context.Context, _a1 ...data.SelectCriteria) (int, error) {
+	_va := make([]interface{}, len(_a1))
+	for _i := range _a1 {
+		_va[_i] = _a1[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, ...data.SelectCriteria) int); ok {
+		r0 = rf(_a0, _a1...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindAll provides a mock function with given fields: _a0, _a1
 func (_m *IdempotencyKey) FindAll(_a0 context.Context, _a1 ...data.SelectCriteria) ([]entity.IdempotencyKey, error) {
 	_va := make([]interface{}, len(_a1))
@@ -91,6 +147,34 @@ func (_m *IdempotencyKey) FindOne(_a0 context.Context, _a1 ...data.SelectCriteri
 	return r0, r1
 }
 
+// FindPage provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdempotencyKey) FindPage(_a0 context.Context, _a1 data.PageRequest, _a2 ...data.SelectCriteria) (data.Page[entity.IdempotencyKey], error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 data.Page[entity.IdempotencyKey]
+	if rf, ok := ret.Get(0).(func(context.Context, data.PageRequest, ...data.SelectCriteria) data.Page[entity.IdempotencyKey]); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(data.Page[entity.IdempotencyKey])
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, data.PageRequest, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Save provides a mock function with given fields: _a0, _a1
 func (_m *IdempotencyKey) Save(_a0 context.Context, _a1 *entity.IdempotencyKey) error {
 	ret := _m.Called(_a0, _a1)
@@ -105,6 +189,55 @@ func (_m *IdempotencyKey) Save(_a0 context.Context, _a1 *entity.IdempotencyKey)
 	return r0
 }
 
+// SaveMany provides a mock function with given fields: _a0, _a1
+func (_m *IdempotencyKey) SaveMany(_a0 context.Context, _a1 []entity.IdempotencyKey) (int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.IdempotencyKey) int); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []entity.IdempotencyKey) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveOrGet provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdempotencyKey) SaveOrGet(_a0 context.Context, _a1 *entity.IdempotencyKey, _a2 ...data.SelectCriteria) (bool, error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.IdempotencyKey, ...data.SelectCriteria) bool); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entity.IdempotencyKey, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: _a0, _a1
 func (_m *IdempotencyKey) Update(_a0 context.Context, _a1 *entity.IdempotencyKey) error {
 	ret := _m.Called(_a0, _a1)
@@ -119,6 +252,48 @@ func (_m *IdempotencyKey) Update(_a0 context.Context, _a1 *entity.IdempotencyKey
 	return r0
 }
 
+// UpdateMany provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdempotencyKey) UpdateMany(_a0 context.Context, _a1 []entity.IdempotencyKey, _a2 ...string) (int, error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.IdempotencyKey, ...string) int); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []entity.IdempotencyKey, ...string) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Upsert provides a mock function with given fields: ctx, model, conflictCols, updateCols
+func (_m *IdempotencyKey) Upsert(ctx context.Context, model *entity.IdempotencyKey, conflictCols []string, updateCols []string) error {
+	ret := _m.Called(ctx, model, conflictCols, updateCols)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.IdempotencyKey, []string, []string) error); ok {
+		r0 = rf(ctx, model, conflictCols, updateCols)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewIdempotencyKey creates a new instance of IdempotencyKey. It also registers the testing.TB interface on the mock and a cleanup function to assert the mocks expectations.
 func NewIdempotencyKey(t testing.TB) *IdempotencyKey {
 	mock := &IdempotencyKey{}
@@ -19,6 +19,34 @@ type Ride struct {
 	mock.Mock
 }
 
+// Count provides a mock function with given fields: _a0, _a1
+func (_m *Ride) Count(_a0 context.Context, _a1 ...data.SelectCriteria) (int, error) {
+	_va := make([]interface{}, len(_a1))
+	for _i := range _a1 {
+		_va[_i] = _a1[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, ...data.SelectCriteria) int); ok {
+		r0 = rf(_a0, _a1...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: _a0, _a1
 func (_m *Ride) Delete(_a0 context.Context, _a1 *entity.Ride) error {
 	ret := _m.Called(_a0, _a1)
@@ -33,6 +61,34 @@ func (_m *Ride) Delete(_a0 context.Context, _a1 *entity.Ride) error {
 	return r0
 }
 
+// DeleteWhere provides a mock function with given fields: _a0, _a1
+func (_m *Ride) DeleteWhere(_a0 context.Context, _a1 ...data.SelectCriteria) (int, error) {
+	_va := make([]interface{}, len(_a1))
+	for _i := range _a1 {
+		_va[_i] = _a1[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, ...data.SelectCriteria) int); ok {
+		r0 = rf(_a0, _a1...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindAll provides a mock function with given fields: _a0, _a1
 func (_m *Ride) FindAll(_a0 context.Context, _a1 ...data.SelectCriteria) ([]entity.Ride, error) {
 	_va := make([]interface{}, len(_a1))
@@ -91,6 +147,34 @@ func (_m *Ride) FindOne(_a0 context.Context, _a1 ...data.SelectCriteria) (entity
 	return r0, r1
 }
 
+// FindPage provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Ride) FindPage(_a0 context.Context, _a1 data.PageRequest, _a2 ...data.SelectCriteria) (data.Page[entity.Ride], error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 data.Page[entity.Ride]
+	if rf, ok := ret.Get(0).(func(context.Context, data.PageRequest, ...data.SelectCriteria) data.Page[entity.Ride]); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(data.Page[entity.Ride])
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, data.PageRequest, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Save provides a mock function with given fields: _a0, _a1
 func (_m *Ride) Save(_a0 context.Context, _a1 *entity.Ride) error {
 	ret := _m.Called(_a0, _a1)
@@ -105,6 +189,55 @@ func (_m *Ride) Save(_a0 context.Context, _a1 *entity.Ride) error {
 	return r0
 }
 
+// SaveMany provides a mock function with given fields: _a0, _a1
+func (_m *Ride) SaveMany(_a0 context.Context, _a1 []entity.Ride) (int, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.Ride) int); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []entity.Ride) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveOrGet provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Ride) SaveOrGet(_a0 context.Context, _a1 *entity.Ride, _a2 ...data.SelectCriteria) (bool, error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Ride, ...data.SelectCriteria) bool); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entity.Ride, ...data.SelectCriteria) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: _a0, _a1
 func (_m *Ride) Update(_a0 context.Context, _a1 *entity.Ride) error {
 	ret := _m.Called(_a0, _a1)
@@ -119,6 +252,48 @@ func (_m *Ride) Update(_a0 context.Context, _a1 *entity.Ride) error {
 	return r0
 }
 
+// UpdateMany provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Ride) UpdateMany(_a0 context.Context, _a1 []entity.Ride, _a2 ...string) (int, error) {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.Ride, ...string) int); ok {
+		r0 = rf(_a0, _a1, _a2...)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []entity.Ride, ...string) error); ok {
+		r1 = rf(_a0, _a1, _a2...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Upsert provides a mock function with given fields: ctx, model, conflictCols, updateCols
+func (_m *Ride) Upsert(ctx context.Context, model *entity.Ride, conflictCols []string, updateCols []string) error {
+	ret := _m.Called(ctx, model, conflictCols, updateCols)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Ride, []string, []string) error); ok {
+		r0 = rf(ctx, model, conflictCols, updateCols)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRide creates a new instance of Ride. It also registers the testing.TB interface on the mock and a cleanup function to assert the mocks expectations.
 func NewRide(t testing.TB) *Ride {
 	mock := &Ride{}
@@ -16,13 +16,20 @@ type UnitOfWork struct {
 	mock.Mock
 }
 
-// Do provides a mock function with given fields: _a0, _a1
-func (_m *UnitOfWork) Do(_a0 context.Context, _a1 uow.UnitOfWorkBlock) error {
-	ret := _m.Called(_a0, _a1)
+// Do provides a mock function with given fields: _a0, _a1, _a2
+func (_m *UnitOfWork) Do(_a0 context.Context, _a1 uow.UnitOfWorkBlock, _a2 ...uow.Option) error {
+	_va := make([]interface{}, len(_a2))
+	for _i := range _a2 {
+		_va[_i] = _a2[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uow.UnitOfWorkBlock) error); ok {
-		r0 = rf(_a0, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, uow.UnitOfWorkBlock, ...uow.Option) error); ok {
+		r0 = rf(_a0, _a1, _a2...)
 	} else {
 		r0 = ret.Error(0)
 	}
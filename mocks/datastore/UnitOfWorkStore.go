@@ -78,6 +78,22 @@ func (_m *UnitOfWorkStore) StagedJobs() datastore.StagedJob {
 	return r0
 }
 
+// StripeEvents provides a mock function with given fields:
+func (_m *UnitOfWorkStore) StripeEvents() datastore.StripeEvent {
+	ret := _m.Called()
+
+	var r0 datastore.StripeEvent
+	if rf, ok := ret.Get(0).(func() datastore.StripeEvent); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(datastore.StripeEvent)
+		}
+	}
+
+	return r0
+}
+
 // Users provides a mock function with given fields:
 func (_m *UnitOfWorkStore) Users() datastore.User {
 	ret := _m.Called()
@@ -5,6 +5,8 @@ package mocks
 import (
 	context "context"
 
+	data "github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+
 	entity "github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	mock "github.com/stretchr/testify/mock"
 
@@ -16,6 +18,34 @@ type Ride struct {
 	mock.Mock
 }
 
+// Cancel provides a mock function with given fields: ctx, ik, rideID
+func (_m *Ride) Cancel(ctx context.Context, ik *entity.IdempotencyKey, rideID int64) error {
+	ret := _m.Called(ctx, ik, rideID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.IdempotencyKey, int64) error); ok {
+		r0 = rf(ctx, ik, rideID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Complete provides a mock function with given fields: _a0, _a1
+func (_m *Ride) Complete(_a0 context.Context, _a1 *entity.IdempotencyKey) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.IdempotencyKey) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: _a0, _a1, _a2
 func (_m *Ride) Create(_a0 context.Context, _a1 *entity.IdempotencyKey, _a2 *entity.Ride) error {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -30,6 +60,48 @@ func (_m *Ride) Create(_a0 context.Context, _a1 *entity.IdempotencyKey, _a2 *ent
 	return r0
 }
 
+// Get provides a mock function with given fields: ctx, userID, rideID
+func (_m *Ride) Get(ctx context.Context, userID int64, rideID int64) (entity.Ride, error) {
+	ret := _m.Called(ctx, userID, rideID)
+
+	var r0 entity.Ride
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) entity.Ride); ok {
+		r0 = rf(ctx, userID, rideID)
+	} else {
+		r0 = ret.Get(0).(entity.Ride)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, userID, rideID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, userID, cursor, limit
+func (_m *Ride) List(ctx context.Context, userID int64, cursor string, limit int) (data.Page[entity.Ride], error) {
+	ret := _m.Called(ctx, userID, cursor, limit)
+
+	var r0 data.Page[entity.Ride]
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, int) data.Page[entity.Ride]); ok {
+		r0 = rf(ctx, userID, cursor, limit)
+	} else {
+		r0 = ret.Get(0).(data.Page[entity.Ride])
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string, int) error); ok {
+		r1 = rf(ctx, userID, cursor, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewRide creates a new instance of Ride. It also registers the testing.TB interface on the mock and a cleanup function to assert the mocks expectations.
 func NewRide(t testing.TB) *Ride {
 	mock := &Ride{}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// File is a Mailer for local development, writing every message to its own
+// .eml file, which most email clients are able to open.
+type File struct {
+	dir string
+	seq uint64
+}
+
+// NewFile returns a Mailer writing messages to dir, which is created as needed.
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+func (f *File) Send(_ context.Context, m Message) error {
+	now := time.Now()
+
+	raw, err := m.encode(now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+
+	// files are named after the time they're written, so that they're listed
+	// in the order they were sent
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000"), atomic.AddUint64(&f.seq, 1))
+	return os.WriteFile(filepath.Join(f.dir, name), raw, 0o600)
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Mailer keeping every message it's given, for tests and local
+// development alike.
+type Memory struct {
+	// Err, when set, makes every call from then on fail with it.
+	Err error
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	if _, _, err := msg.addresses(); err != nil {
+		return err
+	}
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first.
+func (m *Memory) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,94 @@
+//go:build unit
+// +build unit
+
+package notify
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() Message {
+	return Message{
+		From:    "Rocket Rides <receipts@rocketrides.io>",
+		To:      "jane@example.com",
+		Subject: "Your receipt ✓",
+		Text:    "Total charged: USD 12.34, a line long enough to be wrapped by quoted-printable encoding.",
+		HTML:    `<p style="color: #333;">Total charged: <strong>USD 12.34</strong></p>`,
+	}
+}
+
+// parse reads back an encoded message, returning its headers along with the
+// content of its text and HTML parts.
+func parse(t *testing.T, raw []byte) (mail.Header, string, string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		ct, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		require.NoError(t, err)
+
+		// quoted-printable parts are decoded as they're read
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		parts[ct] = string(b)
+	}
+
+	return msg.Header, parts["text/plain"], parts["text/html"]
+}
+
+func TestEncode(t *testing.T) {
+	m := testMessage()
+	date := time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	raw, err := m.encode(date)
+	require.NoError(t, err)
+
+	hdr, text, html := parse(t, raw)
+
+	assert.Equal(t, `"Rocket Rides" <receipts@rocketrides.io>`, hdr.Get("From"))
+	assert.Equal(t, "<jane@example.com>", hdr.Get("To"))
+	assert.Equal(t, "1.0", hdr.Get("MIME-Version"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(hdr.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, m.Subject, subject)
+
+	sent, err := hdr.Date()
+	require.NoError(t, err)
+	assert.True(t, date.Equal(sent))
+
+	assert.Equal(t, m.Text, text)
+	assert.Equal(t, m.HTML, html)
+
+	for desc, change := range map[string]func(*Message){
+		"invalid sender":    func(m *Message) { m.From = "rocket rides" },
+		"invalid recipient": func(m *Message) { m.To = "" },
+	} {
+		invalid := testMessage()
+		change(&invalid)
+
+		_, err := invalid.encode(date)
+		assert.Error(t, err, desc)
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+type notifier struct {
+	from   string
+	mailer Mailer
+	users  datastore.User
+	rides  datastore.Ride
+}
+
+// Notifier works the jobs emailing users, its methods being meant to be
+// registered as the jobs' handlers.
+type Notifier interface {
+	SendReceipt(context.Context, stagedjob.JobArgReceipt) error
+}
+
+func New(cfg config.Config, mailer Mailer, users datastore.User, rides datastore.Ride) Notifier {
+	return &notifier{
+		from:   cfg.MailFrom,
+		mailer: mailer,
+		users:  users,
+		rides:  rides,
+	}
+}
+
+// SendReceipt emails the receipt of a ride to its user. The amount charged is
+// the one in the args, the ride being looked up only for its details.
+func (n *notifier) SendReceipt(ctx context.Context, args stagedjob.JobArgReceipt) error {
+	usr, err := n.users.FindOne(ctx, datastore.UserWithID(args.UserID))
+	if err != nil {
+		return err
+	}
+
+	r := Receipt{
+		Email:    usr.Email,
+		Amount:   args.Amount,
+		Currency: args.Currency,
+	}
+
+	if args.RideID != 0 {
+		rd, err := n.rides.FindOne(ctx, datastore.RideWithID(args.RideID))
+		if err != nil {
+			return err
+		}
+		r.Ride = &rd
+	}
+
+	msg, err := RenderReceipt(n.from, r)
+	if err != nil {
+		return err
+	}
+
+	return n.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,123 @@
+//go:build unit
+// +build unit
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendReceipt(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{MailFrom: from}
+
+	usr := entity.User{ID: 7, Email: "jane@example.com"}
+	rd := testRide()
+	args := stagedjob.JobArgReceipt{Amount: rd.Amount, Currency: rd.Currency, RideID: rd.ID, UserID: usr.ID}
+
+	t.Run("Error on FindOne User", func(t *testing.T) {
+		retErr := errors.New("err FindOne")
+
+		users := &mocks.User{}
+		users.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, retErr)
+
+		mailer := NewMemory()
+		err := New(cfg, mailer, users, &mocks.Ride{}).SendReceipt(ctx, args)
+
+		assert.Equal(t, retErr, err)
+		assert.Empty(t, mailer.Sent())
+	})
+
+	t.Run("Error on FindOne Ride", func(t *testing.T) {
+		retErr := errors.New("err FindOne")
+
+		users := &mocks.User{}
+		users.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(usr, nil)
+
+		rides := &mocks.Ride{}
+		rides.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.Ride{}, retErr)
+
+		mailer := NewMemory()
+		err := New(cfg, mailer, users, rides).SendReceipt(ctx, args)
+
+		assert.Equal(t, retErr, err)
+		assert.Empty(t, mailer.Sent())
+	})
+
+	t.Run("Error on Send", func(t *testing.T) {
+		users := &mocks.User{}
+		users.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(usr, nil)
+
+		rides := &mocks.Ride{}
+		rides.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(rd, nil)
+
+		mailer := NewMemory()
+		mailer.Err = errors.New("err Send")
+		err := New(cfg, mailer, users, rides).SendReceipt(ctx, args)
+
+		assert.Equal(t, mailer.Err, err)
+	})
+
+	t.Run("Success on SendReceipt", func(t *testing.T) {
+		users := &mocks.User{}
+		users.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(usr, nil)
+
+		rides := &mocks.Ride{}
+		rides.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(rd, nil)
+
+		mailer := NewMemory()
+		err := New(cfg, mailer, users, rides).SendReceipt(ctx, args)
+		require.NoError(t, err)
+
+		sent := mailer.Sent()
+		require.Len(t, sent, 1)
+		assert.Equal(t, from, sent[0].From)
+		assert.Equal(t, usr.Email, sent[0].To)
+		assert.Contains(t, sent[0].Text, "ride #42")
+		assert.Contains(t, sent[0].HTML, "USD 12.34")
+	})
+
+	t.Run("Success on SendReceipt without ride", func(t *testing.T) {
+		users := &mocks.User{}
+		users.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(usr, nil)
+
+		// receipts staged before ride_id was added don't have it
+		rides := &mocks.Ride{}
+		args := args
+		args.RideID = 0
+
+		mailer := NewMemory()
+		err := New(cfg, mailer, users, rides).SendReceipt(ctx, args)
+		require.NoError(t, err)
+
+		require.Len(t, mailer.Sent(), 1)
+		assert.Contains(t, mailer.Sent()[0].Text, "USD 12.34")
+		rides.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,42 @@
+// Package notify emails users about their rides, such as sending them receipts,
+// through whichever Mailer the config picks.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+// Message is an email carrying both a plain text and an HTML version of the
+// same content, leaving it up to the user's client to pick one.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages. Errors mean the message may not have been
+// delivered, so it's up to the caller to retry it.
+type Mailer interface {
+	Send(context.Context, Message) error
+}
+
+// NewMailer returns the Mailer set by the config's MailTransport.
+func NewMailer(cfg config.Config) (Mailer, error) {
+	switch cfg.MailTransport {
+	case "smtp":
+		timeout := time.Duration(cfg.SMTPTimeout) * time.Second
+		return NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, timeout), nil
+	case "file":
+		return NewFile(cfg.MailDir), nil
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", cfg.MailTransport)
+	}
+}
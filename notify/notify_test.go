@@ -0,0 +1,76 @@
+//go:build unit
+// +build unit
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMailer(t *testing.T) {
+	for transport, exp := range map[string]interface{}{
+		"smtp":   &SMTP{},
+		"file":   &File{},
+		"memory": &Memory{},
+	} {
+		m, err := NewMailer(config.Config{MailTransport: transport, MailDir: "mail", SMTPAddr: "localhost:1025"})
+		if assert.NoError(t, err, transport) {
+			assert.IsType(t, exp, m, transport)
+		}
+	}
+
+	_, err := NewMailer(config.Config{MailTransport: "pigeon"})
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	f := NewFile(dir)
+	m := testMessage()
+
+	// every message gets its own file, even when sent at once
+	require.NoError(t, f.Send(ctx, m))
+	require.NoError(t, f.Send(ctx, m))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	_, text, html := parse(t, raw)
+	assert.Equal(t, m.Text, text)
+	assert.Equal(t, m.HTML, html)
+
+	m.From = ""
+	assert.Error(t, f.Send(ctx, m))
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+
+	mem := NewMemory()
+	m := testMessage()
+
+	require.NoError(t, mem.Send(ctx, m))
+	assert.Equal(t, []Message{m}, mem.Sent())
+
+	invalid := m
+	invalid.To = "jane"
+	assert.Error(t, mem.Send(ctx, invalid))
+
+	mem.Err = errors.New("err Send")
+	assert.Equal(t, mem.Err, mem.Send(ctx, m))
+	assert.Len(t, mem.Sent(), 1)
+}
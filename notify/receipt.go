@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+)
+
+const receiptSubject = "Your Rocket Rides receipt"
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = map[string]interface{}{
+	"coords": func(lat, lon float64) string {
+		return fmt.Sprintf("%.5f, %.5f", lat, lon)
+	},
+}
+
+var (
+	receiptText = texttemplate.Must(
+		texttemplate.New("receipt.txt").Funcs(funcs).ParseFS(templates, "templates/receipt.txt"),
+	)
+	receiptHTML = htmltemplate.Must(
+		htmltemplate.New("receipt.html").Funcs(funcs).ParseFS(templates, "templates/receipt.html"),
+	)
+)
+
+// zeroDecimal lists the currencies whose smallest unit is the currency itself,
+// as Stripe has them.
+var zeroDecimal = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// Receipt is what a receipt is made of. The ride's details are left out when
+// Ride is nil.
+type Receipt struct {
+	Email    string
+	Amount   int64
+	Currency string
+	Ride     *entity.Ride
+}
+
+// Total formats the amount, given in the currency's smallest unit, the way
+// it's read (e.g, 'USD 12.34').
+func (r Receipt) Total() string {
+	currency := strings.ToUpper(r.Currency)
+	if zeroDecimal[strings.ToLower(r.Currency)] {
+		return fmt.Sprintf("%s %d", currency, r.Amount)
+	}
+	return fmt.Sprintf("%s %d.%02d", currency, r.Amount/100, r.Amount%100)
+}
+
+// RenderReceipt renders both versions of the receipt into a message addressed
+// to its user.
+func RenderReceipt(from string, r Receipt) (Message, error) {
+	var text, html bytes.Buffer
+
+	if err := receiptText.Execute(&text, r); err != nil {
+		return Message{}, err
+	}
+	if err := receiptHTML.Execute(&html, r); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		From:    from,
+		To:      r.Email,
+		Subject: receiptSubject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,88 @@
+//go:build unit
+// +build unit
+
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const from = "Rocket Rides <receipts@rocketrides.io>"
+
+func testRide() entity.Ride {
+	return entity.Ride{
+		ID:        42,
+		CreatedAt: time.Date(2022, 1, 2, 15, 4, 0, 0, time.UTC),
+		OriginLat: 37.7749,
+		OriginLon: -122.4194,
+		TargetLat: 37.8044,
+		TargetLon: -122.2712,
+		Amount:    1234,
+		Currency:  "usd",
+		UserID:    7,
+	}
+}
+
+func TestTotal(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		total    string
+	}{
+		{1234, "usd", "USD 12.34"},
+		{2000, "eur", "EUR 20.00"},
+		{5, "usd", "USD 0.05"},
+		{1234, "jpy", "JPY 1234"},
+		{1234, "KRW", "KRW 1234"},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.total, Receipt{Amount: tc.amount, Currency: tc.currency}.Total())
+	}
+}
+
+func TestRenderReceipt(t *testing.T) {
+	t.Run("With ride details", func(t *testing.T) {
+		rd := testRide()
+
+		m, err := RenderReceipt(from, Receipt{Email: "jane@example.com", Amount: 1234, Currency: "usd", Ride: &rd})
+		require.NoError(t, err)
+
+		assert.Equal(t, from, m.From)
+		assert.Equal(t, "jane@example.com", m.To)
+		assert.Equal(t, receiptSubject, m.Subject)
+
+		for _, body := range []string{m.Text, m.HTML} {
+			assert.Contains(t, body, "ride #42")
+			assert.Contains(t, body, "Jan 2, 2022 15:04 UTC")
+			assert.Contains(t, body, "37.77490, -122.41940")
+			assert.Contains(t, body, "37.80440, -122.27120")
+			assert.Contains(t, body, "USD 12.34")
+			assert.Contains(t, body, "jane@example.com")
+		}
+	})
+
+	t.Run("Without ride details", func(t *testing.T) {
+		m, err := RenderReceipt(from, Receipt{Email: "jane@example.com", Amount: 1234, Currency: "usd"})
+		require.NoError(t, err)
+
+		for _, body := range []string{m.Text, m.HTML} {
+			assert.Contains(t, body, "receipt for your ride.")
+			assert.NotContains(t, body, "Date")
+			assert.Contains(t, body, "USD 12.34")
+		}
+	})
+
+	t.Run("HTML is escaped", func(t *testing.T) {
+		m, err := RenderReceipt(from, Receipt{Email: "<b>jane</b>@example.com", Currency: "usd"})
+		require.NoError(t, err)
+
+		assert.Contains(t, m.Text, "<b>jane</b>@example.com")
+		assert.Contains(t, m.HTML, "&lt;b&gt;jane&lt;/b&gt;@example.com")
+	})
+}
@@ -0,0 +1,187 @@
+//go:build unit
+// +build unit
+
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received is what the SMTP stand-in got from a single session.
+type received struct {
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// smtpServer is a bare-bones SMTP server standing in for a real one, good
+// enough for net/smtp's client to deliver messages to it.
+type smtpServer struct {
+	ln       net.Listener
+	sessions chan received
+	// stall makes the server accept connections without ever greeting them.
+	stall bool
+}
+
+func newSMTPServer(t *testing.T, stall bool) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpServer{ln: ln, sessions: make(chan received, 1), stall: stall}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	if s.stall {
+		_, _ = conn.Read(make([]byte, 1))
+		return
+	}
+
+	var r received
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP ready")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			r.auth = arg
+			_ = tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			r.from = arg
+			_ = tp.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			r.to = append(r.to, arg)
+			_ = tp.PrintfLine("250 2.1.5 Ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			if r.data, err = tp.ReadDotBytes(); err != nil {
+				return
+			}
+			_ = tp.PrintfLine("250 2.0.0 Ok: queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 2.0.0 Bye")
+			s.sessions <- r
+			return
+		default:
+			_ = tp.PrintfLine("502 5.5.2 Command not recognized")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success on Send", func(t *testing.T) {
+		srv := newSMTPServer(t, false)
+		m := testMessage()
+
+		err := NewSMTP(srv.addr(), "", "", time.Second).Send(ctx, m)
+		require.NoError(t, err)
+
+		r := <-srv.sessions
+		assert.Empty(t, r.auth)
+		assert.Equal(t, "FROM:<receipts@rocketrides.io>", r.from)
+		assert.Equal(t, []string{"TO:<jane@example.com>"}, r.to)
+
+		_, text, html := parse(t, r.data)
+		assert.Equal(t, m.Text, text)
+		assert.Equal(t, m.HTML, html)
+	})
+
+	t.Run("Success on Send with auth", func(t *testing.T) {
+		srv := newSMTPServer(t, false)
+
+		err := NewSMTP(srv.addr(), "user", "pass", time.Second).Send(ctx, testMessage())
+		require.NoError(t, err)
+
+		r := <-srv.sessions
+		creds := base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass"))
+		assert.Equal(t, "PLAIN "+creds, r.auth)
+	})
+
+	t.Run("Error on invalid message", func(t *testing.T) {
+		srv := newSMTPServer(t, false)
+		m := testMessage()
+		m.To = "jane"
+
+		err := NewSMTP(srv.addr(), "", "", time.Second).Send(ctx, m)
+		assert.Error(t, err)
+	})
+
+	t.Run("Error on unreachable server", func(t *testing.T) {
+		srv := newSMTPServer(t, false)
+		addr := srv.addr()
+		srv.ln.Close()
+
+		err := NewSMTP(addr, "", "", time.Second).Send(ctx, testMessage())
+		assert.Error(t, err)
+	})
+
+	t.Run("Error on context done", func(t *testing.T) {
+		srv := newSMTPServer(t, true)
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() { done <- NewSMTP(srv.addr(), "", "", time.Second).Send(ctx, testMessage()) }()
+
+		select {
+		case err := <-done:
+			assert.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Send didn't return once the context was done")
+		}
+	})
+	t.Run("Error on stalled server", func(t *testing.T) {
+		srv := newSMTPServer(t, true)
+
+		// the context is never done, so it's up to the timeout
+		done := make(chan error, 1)
+		go func() { done <- NewSMTP(srv.addr(), "", "", 50*time.Millisecond).Send(ctx, testMessage()) }()
+
+		select {
+		case err := <-done:
+			assert.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Send didn't return once the timeout was over")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-memory Provider. Just like a real processor, calls retried
+// with the same idempotency key get the very same result back.
+type Fake struct {
+	// Err, when set, makes every call from then on fail with it.
+	Err error
+	// IntentStatus, when set, is the status new payment intents get instead
+	// of succeeding right away.
+	IntentStatus PaymentIntentStatus
+
+	mu        sync.Mutex
+	seq       int
+	created   map[string]PaymentIntent
+	intents   map[string]PaymentIntent
+	canceled  map[string]PaymentIntent
+	refunds   map[string]Refund
+	customers map[string]Customer
+	calls     fakeCalls
+}
+
+type fakeCalls struct {
+	intents       []PaymentIntentParams
+	cancellations []CancelPaymentIntentParams
+	refunds       []RefundParams
+	customers     []CustomerParams
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		created:   map[string]PaymentIntent{},
+		intents:   map[string]PaymentIntent{},
+		canceled:  map[string]PaymentIntent{},
+		refunds:   map[string]Refund{},
+		customers: map[string]Customer{},
+	}
+}
+
+func (f *Fake) CreatePaymentIntent(_ context.Context, pp PaymentIntentParams) (PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return PaymentIntent{}, f.Err
+	}
+
+	pi, ok := f.created[pp.IdempotencyKey]
+	if !ok {
+		pi = PaymentIntent{ID: f.nextID("pi"), Status: PaymentIntentSucceeded}
+		pi.ClientSecret = pi.ID + "_secret"
+		if f.IntentStatus != "" {
+			pi.Status = f.IntentStatus
+		}
+		f.settle(&pi)
+
+		f.created[pp.IdempotencyKey] = pi
+		f.intents[pi.ID] = pi
+		f.calls.intents = append(f.calls.intents, pp)
+	}
+	return pi, nil
+}
+
+func (f *Fake) GetPaymentIntent(_ context.Context, id string) (PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return PaymentIntent{}, f.Err
+	}
+
+	pi, ok := f.intents[id]
+	if !ok {
+		return PaymentIntent{}, NewGenericError("resource_missing", fmt.Sprintf("no such payment_intent: %v", id))
+	}
+	return pi, nil
+}
+
+// SetPaymentIntentStatus changes the status of an existing payment intent, as
+// if the customer had taken the action it required.
+func (f *Fake) SetPaymentIntentStatus(id string, status PaymentIntentStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pi, ok := f.intents[id]
+	if !ok {
+		return
+	}
+
+	pi.Status = status
+	f.settle(&pi)
+	f.intents[id] = pi
+}
+
+func (f *Fake) CancelPaymentIntent(_ context.Context, cp CancelPaymentIntentParams) (PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return PaymentIntent{}, f.Err
+	}
+
+	pi, ok := f.intents[cp.ID]
+	if !ok {
+		return PaymentIntent{}, NewGenericError("resource_missing", fmt.Sprintf("no such payment_intent: %v", cp.ID))
+	}
+
+	if _, ok := f.canceled[cp.IdempotencyKey]; !ok {
+		if pi.Status == PaymentIntentSucceeded || pi.Status == PaymentIntentCanceled {
+			msg := fmt.Sprintf("payment_intent %v can't be canceled as it's %v", pi.ID, pi.Status)
+			return PaymentIntent{}, NewGenericError("payment_intent_unexpected_state", msg)
+		}
+
+		pi.Status = PaymentIntentCanceled
+		f.intents[pi.ID] = pi
+		f.canceled[cp.IdempotencyKey] = pi
+		f.calls.cancellations = append(f.calls.cancellations, cp)
+	}
+	return f.canceled[cp.IdempotencyKey], nil
+}
+
+func (f *Fake) Refund(_ context.Context, rp RefundParams) (Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return Refund{}, f.Err
+	}
+
+	re, ok := f.refunds[rp.IdempotencyKey]
+	if !ok {
+		re = Refund{ID: f.nextID("re")}
+		f.refunds[rp.IdempotencyKey] = re
+		f.calls.refunds = append(f.calls.refunds, rp)
+	}
+	return re, nil
+}
+
+func (f *Fake) CreateCustomer(_ context.Context, cp CustomerParams) (Customer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return Customer{}, f.Err
+	}
+
+	c, ok := f.customers[cp.IdempotencyKey]
+	if !ok {
+		c = Customer{ID: f.nextID("cus")}
+		f.customers[cp.IdempotencyKey] = c
+		f.calls.customers = append(f.calls.customers, cp)
+	}
+	return c, nil
+}
+
+// PaymentIntents lists the params of every payment intent created, leaving
+// retries out.
+func (f *Fake) PaymentIntents() []PaymentIntentParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]PaymentIntentParams(nil), f.calls.intents...)
+}
+
+// Cancellations lists the params of every payment intent canceled, leaving
+// retries out.
+func (f *Fake) Cancellations() []CancelPaymentIntentParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CancelPaymentIntentParams(nil), f.calls.cancellations...)
+}
+
+// Refunds lists the params of every refund made, leaving retries out.
+func (f *Fake) Refunds() []RefundParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RefundParams(nil), f.calls.refunds...)
+}
+
+// Customers lists the params of every customer created, leaving retries out.
+func (f *Fake) Customers() []CustomerParams {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CustomerParams(nil), f.calls.customers...)
+}
+
+// settle charges succeeded intents, once.
+func (f *Fake) settle(pi *PaymentIntent) {
+	if pi.Status == PaymentIntentSucceeded && pi.ChargeID == "" {
+		pi.ChargeID = f.nextID("ch")
+	}
+}
+
+func (f *Fake) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%v_fake_%v", prefix, f.seq)
+}
@@ -0,0 +1,123 @@
+//go:build unit
+// +build unit
+
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Retries get the same result", func(t *testing.T) {
+		f := NewFake()
+
+		pi1, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "a", Amount: 100})
+		require.NoError(t, err)
+		pi2, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "a", Amount: 100})
+		require.NoError(t, err)
+		pi3, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "b", Amount: 200})
+		require.NoError(t, err)
+
+		assert.Equal(t, pi1, pi2)
+		assert.NotEqual(t, pi1, pi3)
+		assert.Equal(t, PaymentIntentSucceeded, pi1.Status)
+		assert.NotEmpty(t, pi1.ChargeID)
+		assert.Equal(t, []PaymentIntentParams{
+			{IdempotencyKey: "a", Amount: 100},
+			{IdempotencyKey: "b", Amount: 200},
+		}, f.PaymentIntents())
+
+		re1, err := f.Refund(ctx, RefundParams{IdempotencyKey: "r", Charge: pi1.ChargeID})
+		require.NoError(t, err)
+		re2, err := f.Refund(ctx, RefundParams{IdempotencyKey: "r", Charge: pi1.ChargeID})
+		require.NoError(t, err)
+
+		assert.Equal(t, re1, re2)
+		assert.Len(t, f.Refunds(), 1)
+
+		cus1, err := f.CreateCustomer(ctx, CustomerParams{IdempotencyKey: "c", Email: "jane@example.com"})
+		require.NoError(t, err)
+		cus2, err := f.CreateCustomer(ctx, CustomerParams{IdempotencyKey: "c", Email: "jane@example.com"})
+		require.NoError(t, err)
+
+		assert.Equal(t, cus1, cus2)
+		assert.Len(t, f.Customers(), 1)
+	})
+
+	t.Run("Intents requiring action", func(t *testing.T) {
+		f := NewFake()
+		f.IntentStatus = PaymentIntentRequiresAction
+
+		pi, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, PaymentIntentRequiresAction, pi.Status)
+		assert.NotEmpty(t, pi.ClientSecret)
+		assert.Empty(t, pi.ChargeID)
+
+		f.SetPaymentIntentStatus(pi.ID, PaymentIntentSucceeded)
+
+		got, err := f.GetPaymentIntent(ctx, pi.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PaymentIntentSucceeded, got.Status)
+		assert.NotEmpty(t, got.ChargeID)
+
+		_, err = f.GetPaymentIntent(ctx, "pi_unknown")
+		assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+	})
+
+	t.Run("Canceled intents", func(t *testing.T) {
+		f := NewFake()
+		f.IntentStatus = PaymentIntentRequiresAction
+
+		pi, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "a"})
+		require.NoError(t, err)
+
+		cp := CancelPaymentIntentParams{IdempotencyKey: "x", ID: pi.ID}
+		pi1, err := f.CancelPaymentIntent(ctx, cp)
+		require.NoError(t, err)
+		pi2, err := f.CancelPaymentIntent(ctx, cp)
+		require.NoError(t, err)
+
+		assert.Equal(t, PaymentIntentCanceled, pi1.Status)
+		assert.Equal(t, pi1, pi2)
+		assert.Len(t, f.Cancellations(), 1)
+
+		got, err := f.GetPaymentIntent(ctx, pi.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PaymentIntentCanceled, got.Status)
+
+		// succeeded intents can't be canceled anymore
+		f.IntentStatus = ""
+		pi, err = f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "b"})
+		require.NoError(t, err)
+
+		_, err = f.CancelPaymentIntent(ctx, CancelPaymentIntentParams{IdempotencyKey: "y", ID: pi.ID})
+		assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+		assert.Len(t, f.Cancellations(), 1)
+	})
+
+	t.Run("Failing calls", func(t *testing.T) {
+		f := NewFake()
+		f.Err = NewCardError("card_declined", "card declined")
+
+		_, err := f.CreatePaymentIntent(ctx, PaymentIntentParams{IdempotencyKey: "a"})
+		assert.True(t, IsCardError(err))
+
+		_, err = f.Refund(ctx, RefundParams{IdempotencyKey: "r"})
+		assert.Equal(t, f.Err, err)
+
+		_, err = f.CreateCustomer(ctx, CustomerParams{IdempotencyKey: "c"})
+		assert.Equal(t, f.Err, err)
+
+		assert.Empty(t, f.PaymentIntents())
+		assert.Empty(t, f.Refunds())
+		assert.Empty(t, f.Customers())
+	})
+}
@@ -0,0 +1,119 @@
+// Package payment talks to the payment processor on behalf of the use cases,
+// so that none of them depends on any processor's SDK.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+)
+
+// Provider is a payment processor. Every call carries an idempotency key that
+// is passed through to the processor, so that retrying a call never charges,
+// refunds or creates anything twice.
+//
+// Calls turned down by the processor fail with an *Error, which is definitive.
+// Any other error (e.g. network ones) leaves the outcome unknown, so the call
+// must be retried with the same idempotency key.
+type Provider interface {
+	CreatePaymentIntent(context.Context, PaymentIntentParams) (PaymentIntent, error)
+	GetPaymentIntent(ctx context.Context, id string) (PaymentIntent, error)
+	CancelPaymentIntent(context.Context, CancelPaymentIntentParams) (PaymentIntent, error)
+	Refund(context.Context, RefundParams) (Refund, error)
+	CreateCustomer(context.Context, CustomerParams) (Customer, error)
+}
+
+// PaymentIntentStatus tells where a payment intent stands, as reported by the
+// processor.
+type PaymentIntentStatus string
+
+const (
+	PaymentIntentCanceled              PaymentIntentStatus = "canceled"
+	PaymentIntentProcessing            PaymentIntentStatus = "processing"
+	PaymentIntentRequiresAction        PaymentIntentStatus = "requires_action"
+	PaymentIntentRequiresPaymentMethod PaymentIntentStatus = "requires_payment_method"
+	PaymentIntentSucceeded             PaymentIntentStatus = "succeeded"
+)
+
+// PaymentIntentParams describe a payment, which is confirmed right away.
+type PaymentIntentParams struct {
+	IdempotencyKey string
+	Amount         int64
+	Currency       string
+	Customer       string
+	Description    string
+}
+
+type PaymentIntent struct {
+	ID     string
+	Status PaymentIntentStatus
+	// ClientSecret lets the customer take the action the intent requires,
+	// such as authenticating through 3-D Secure.
+	ClientSecret string
+	// ChargeID is set once the intent succeeds.
+	ChargeID string
+}
+
+// CancelPaymentIntentParams tell which payment intent to cancel, as abandoned
+// by the customer. Only intents yet to succeed can be canceled.
+type CancelPaymentIntentParams struct {
+	IdempotencyKey string
+	ID             string
+}
+
+type RefundParams struct {
+	IdempotencyKey string
+	Charge         string
+}
+
+type Refund struct {
+	ID string
+}
+
+type CustomerParams struct {
+	IdempotencyKey string
+	Email          string
+}
+
+type Customer struct {
+	ID string
+}
+
+// Error is returned when the processor turns a call down. Its Kind is either
+// entity.ErrPaymentProvider, for card errors, or entity.ErrPaymentProviderGeneric
+// for everything else, so that both can be told apart with errors.Is.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+// NewCardError returns an error for a card declined by the processor.
+func NewCardError(code, msg string) *Error {
+	return &Error{Kind: entity.ErrPaymentProvider, Code: code, Message: msg}
+}
+
+// NewGenericError returns an error for a call turned down by the processor
+// for any reason other than the card.
+func NewGenericError(code, msg string) *Error {
+	return &Error{Kind: entity.ErrPaymentProviderGeneric, Code: code, Message: msg}
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%v: %v", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%v: %v (%v)", e.Kind, e.Message, e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// IsCardError tells whether err is a card error from the processor.
+func IsCardError(err error) bool {
+	var perr *Error
+	return errors.As(err, &perr) && errors.Is(perr.Kind, entity.ErrPaymentProvider)
+}
@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/customer"
+	"github.com/stripe/stripe-go/v72/paymentintent"
+	"github.com/stripe/stripe-go/v72/refund"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+type stripeProvider struct {
+	key string
+}
+
+// NewStripe returns a Provider backed by Stripe. Rather than the global
+// stripe.Key, the key given in the config is sent along with every call.
+func NewStripe(cfg config.Config) Provider {
+	return &stripeProvider{key: cfg.StripeKey}
+}
+
+func (p *stripeProvider) CreatePaymentIntent(ctx context.Context, pp PaymentIntentParams) (PaymentIntent, error) {
+	params := &stripe.PaymentIntentParams{
+		Params:      p.params(ctx, pp.IdempotencyKey),
+		Amount:      stripe.Int64(pp.Amount),
+		Currency:    stripe.String(pp.Currency),
+		Description: stripe.String(pp.Description),
+		Confirm:     stripe.Bool(true),
+	}
+	if pp.Customer != "" {
+		params.Customer = stripe.String(pp.Customer)
+	}
+
+	pi, err := paymentintent.Client{B: p.backend(), Key: p.key}.New(params)
+	if err != nil {
+		return PaymentIntent{}, stripeError(err)
+	}
+	return paymentIntent(pi), nil
+}
+
+func (p *stripeProvider) GetPaymentIntent(ctx context.Context, id string) (PaymentIntent, error) {
+	params := &stripe.PaymentIntentParams{
+		Params: stripe.Params{Context: ctx},
+	}
+
+	pi, err := paymentintent.Client{B: p.backend(), Key: p.key}.Get(id, params)
+	if err != nil {
+		return PaymentIntent{}, stripeError(err)
+	}
+	return paymentIntent(pi), nil
+}
+
+func (p *stripeProvider) CancelPaymentIntent(ctx context.Context, cp CancelPaymentIntentParams) (PaymentIntent, error) {
+	params := &stripe.PaymentIntentCancelParams{
+		Params:             p.params(ctx, cp.IdempotencyKey),
+		CancellationReason: stripe.String(string(stripe.PaymentIntentCancellationReasonAbandoned)),
+	}
+
+	pi, err := paymentintent.Client{B: p.backend(), Key: p.key}.Cancel(cp.ID, params)
+	if err != nil {
+		return PaymentIntent{}, stripeError(err)
+	}
+	return paymentIntent(pi), nil
+}
+
+func (p *stripeProvider) Refund(ctx context.Context, rp RefundParams) (Refund, error) {
+	params := &stripe.RefundParams{
+		Params: p.params(ctx, rp.IdempotencyKey),
+		Charge: stripe.String(rp.Charge),
+	}
+
+	re, err := refund.Client{B: p.backend(), Key: p.key}.New(params)
+	if err != nil {
+		return Refund{}, stripeError(err)
+	}
+	return Refund{ID: re.ID}, nil
+}
+
+func (p *stripeProvider) CreateCustomer(ctx context.Context, cp CustomerParams) (Customer, error) {
+	params := &stripe.CustomerParams{
+		Params: p.params(ctx, cp.IdempotencyKey),
+		Email:  stripe.String(cp.Email),
+	}
+
+	c, err := customer.Client{B: p.backend(), Key: p.key}.New(params)
+	if err != nil {
+		return Customer{}, stripeError(err)
+	}
+	return Customer{ID: c.ID}, nil
+}
+
+func (p *stripeProvider) params(ctx context.Context, idemKey string) stripe.Params {
+	return stripe.Params{
+		Context:        ctx,
+		IdempotencyKey: stripe.String(idemKey),
+	}
+}
+
+// backend is looked up on every call rather than once and for all, so that
+// it can still be replaced (e.g. by stripe-mock's) after the provider is
+// created.
+func (p *stripeProvider) backend() stripe.Backend {
+	return stripe.GetBackend(stripe.APIBackend)
+}
+
+func paymentIntent(pi *stripe.PaymentIntent) PaymentIntent {
+	res := PaymentIntent{
+		ID:           pi.ID,
+		Status:       PaymentIntentStatus(pi.Status),
+		ClientSecret: pi.ClientSecret,
+	}
+
+	// the last charge is the one that succeeded, if any
+	if pi.Status == stripe.PaymentIntentStatusSucceeded && pi.Charges != nil && len(pi.Charges.Data) > 0 {
+		res.ChargeID = pi.Charges.Data[len(pi.Charges.Data)-1].ID
+	}
+	return res
+}
+
+// stripeError maps errors coming from Stripe's API, which are definitive, to
+// an *Error. Anything else, such as network errors, is returned as is.
+func stripeError(err error) error {
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) {
+		return err
+	}
+
+	if _, ok := stripeErr.Err.(*stripe.CardError); ok {
+		return NewCardError(string(stripeErr.Code), stripeErr.Msg)
+	}
+	return NewGenericError(string(stripeErr.Code), stripeErr.Msg)
+}
@@ -0,0 +1,310 @@
+//go:build unit
+// +build unit
+
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stripe/stripe-go/v72"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	stripeURL = "http://stripeapi"
+)
+
+func init() {
+	maxRetries := int64(0)
+	stripeMockBackend := stripe.GetBackendWithConfig(
+		stripe.APIBackend,
+		&stripe.BackendConfig{
+			URL:               stripe.String(stripeURL),
+			LeveledLogger:     stripe.DefaultLeveledLogger,
+			MaxNetworkRetries: &maxRetries,
+		},
+	)
+	stripe.SetBackend(stripe.APIBackend, stripeMockBackend)
+	stripe.SetBackend(stripe.UploadsBackend, stripeMockBackend)
+}
+
+func TestStripeCreatePaymentIntent(t *testing.T) {
+	defer gock.Off()
+	ctx := context.Background()
+
+	p := NewStripe(config.Config{StripeKey: "sk_test_123"})
+	params := PaymentIntentParams{
+		IdempotencyKey: "go-rocket-ride-1",
+		Amount:         4321,
+		Currency:       "usd",
+		Customer:       "cus_123",
+		Description:    "Charge for ride 1",
+	}
+
+	t.Run("Card error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents").
+			Reply(402).
+			BodyString(`{
+				"error": {
+					"type":"card_error",
+					"code": "balance_insufficient",
+					"message":"card is suspicious"
+				}
+			}`)
+
+		_, err := p.CreatePaymentIntent(ctx, params)
+
+		var perr *Error
+		if assert.ErrorAs(t, err, &perr) {
+			assert.ErrorIs(t, err, entity.ErrPaymentProvider)
+			assert.True(t, IsCardError(err))
+			assert.Equal(t, "balance_insufficient", perr.Code)
+			assert.Equal(t, "card is suspicious", perr.Message)
+		}
+	})
+
+	t.Run("Generic error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents").
+			Reply(503).
+			BodyString(`{
+				"error": {
+					"type":"api_error",
+					"message":"system is down"
+				}
+			}`)
+
+		_, err := p.CreatePaymentIntent(ctx, params)
+
+		var perr *Error
+		if assert.ErrorAs(t, err, &perr) {
+			assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+			assert.False(t, IsCardError(err))
+			assert.Equal(t, "system is down", perr.Message)
+		}
+	})
+
+	t.Run("Unknown error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents").
+			ReplyError(errors.New("unknown error"))
+
+		_, err := p.CreatePaymentIntent(ctx, params)
+
+		var perr *Error
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &perr))
+	})
+
+	t.Run("Requires action", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents").
+			Reply(200).
+			JSON(map[string]interface{}{
+				"id":            "pi_123",
+				"status":        "requires_action",
+				"client_secret": "pi_123_secret_456",
+			})
+
+		pi, err := p.CreatePaymentIntent(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentIntent{
+			ID:           "pi_123",
+			Status:       PaymentIntentRequiresAction,
+			ClientSecret: "pi_123_secret_456",
+		}, pi)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents").
+			MatchHeader("Idempotency-Key", "go-rocket-ride-1").
+			MatchHeader("Authorization", "Bearer sk_test_123").
+			BodyString("amount=4321").
+			Reply(200).
+			JSON(map[string]interface{}{
+				"id":            "pi_123",
+				"status":        "succeeded",
+				"client_secret": "pi_123_secret_456",
+				"charges": map[string]interface{}{
+					"data": []map[string]string{{"id": "ch_123"}},
+				},
+			})
+
+		pi, err := p.CreatePaymentIntent(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PaymentIntentSucceeded, pi.Status)
+		assert.Equal(t, "ch_123", pi.ChargeID)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestStripeGetPaymentIntent(t *testing.T) {
+	defer gock.Off()
+	ctx := context.Background()
+
+	p := NewStripe(config.Config{StripeKey: "sk_test_123"})
+
+	t.Run("Generic error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Get("/v1/payment_intents/pi_404").
+			Reply(404).
+			BodyString(`{
+				"error": {
+					"type":"invalid_request_error",
+					"code": "resource_missing",
+					"message":"no such payment_intent"
+				}
+			}`)
+
+		_, err := p.GetPaymentIntent(ctx, "pi_404")
+
+		assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		gock.New(stripeURL).
+			Get("/v1/payment_intents/pi_123").
+			Reply(200).
+			JSON(map[string]interface{}{
+				"id":     "pi_123",
+				"status": "succeeded",
+				"charges": map[string]interface{}{
+					"data": []map[string]string{{"id": "ch_1"}, {"id": "ch_2"}},
+				},
+			})
+
+		pi, err := p.GetPaymentIntent(ctx, "pi_123")
+
+		require.NoError(t, err)
+		// the last charge is the one that succeeded
+		assert.Equal(t, PaymentIntent{ID: "pi_123", Status: PaymentIntentSucceeded, ChargeID: "ch_2"}, pi)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestStripeCancelPaymentIntent(t *testing.T) {
+	defer gock.Off()
+	ctx := context.Background()
+
+	p := NewStripe(config.Config{StripeKey: "sk_test_123"})
+	params := CancelPaymentIntentParams{IdempotencyKey: "go-rocket-ride-expire-1", ID: "pi_123"}
+
+	t.Run("Generic error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents/pi_123/cancel").
+			Reply(400).
+			BodyString(`{
+				"error": {
+					"type":"invalid_request_error",
+					"code": "payment_intent_unexpected_state",
+					"message":"payment intent has already succeeded"
+				}
+			}`)
+
+		_, err := p.CancelPaymentIntent(ctx, params)
+
+		assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/payment_intents/pi_123/cancel").
+			MatchHeader("Idempotency-Key", params.IdempotencyKey).
+			BodyString("cancellation_reason=abandoned").
+			Reply(200).
+			JSON(map[string]string{"id": "pi_123", "status": "canceled"})
+
+		pi, err := p.CancelPaymentIntent(ctx, params)
+
+		require.NoError(t, err)
+		assert.Equal(t, PaymentIntent{ID: "pi_123", Status: PaymentIntentCanceled}, pi)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestStripeRefund(t *testing.T) {
+	defer gock.Off()
+	ctx := context.Background()
+
+	p := NewStripe(config.Config{StripeKey: "sk_test_123"})
+	params := RefundParams{IdempotencyKey: "go-rocket-ride-refund-1", Charge: "ch_123"}
+
+	t.Run("Generic error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/refunds").
+			Reply(400).
+			BodyString(`{
+				"error": {
+					"type":"invalid_request_error",
+					"code": "charge_already_refunded",
+					"message":"charge has already been refunded"
+				}
+			}`)
+
+		_, err := p.Refund(ctx, params)
+
+		var perr *Error
+		if assert.ErrorAs(t, err, &perr) {
+			assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+			assert.Equal(t, "charge_already_refunded", perr.Code)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/refunds").
+			MatchHeader("Idempotency-Key", "go-rocket-ride-refund-1").
+			BodyString("charge=ch_123").
+			Reply(200).
+			JSON(map[string]string{"id": "re_123"})
+
+		re, err := p.Refund(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Refund{ID: "re_123"}, re)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestStripeCreateCustomer(t *testing.T) {
+	defer gock.Off()
+	ctx := context.Background()
+
+	p := NewStripe(config.Config{StripeKey: "sk_test_123"})
+	params := CustomerParams{IdempotencyKey: "go-rocket-ride-customer-1", Email: "jane@example.com"}
+
+	t.Run("Generic error", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/customers").
+			Reply(500).
+			JSON(map[string]interface{}{"error": map[string]string{"type": "api_error"}})
+
+		_, err := p.CreateCustomer(ctx, params)
+
+		assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		gock.New(stripeURL).
+			Post("/v1/customers").
+			MatchHeader("Idempotency-Key", "go-rocket-ride-customer-1").
+			Reply(200).
+			JSON(map[string]string{"id": "cus_123"})
+
+		c, err := p.CreateCustomer(ctx, params)
+
+		require.NoError(t, err)
+		assert.Equal(t, Customer{ID: "cus_123"}, c)
+		assert.True(t, gock.IsDone())
+	})
+}
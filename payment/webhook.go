@@ -0,0 +1,112 @@
+package payment
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+// Types of the events sent through webhooks that are acted upon.
+const (
+	EventChargeRefunded       = "charge.refunded"
+	EventChargeDisputeCreated = "charge.dispute.created"
+)
+
+// ErrInvalidSignature is returned for webhook payloads that weren't signed by
+// the processor with the configured secret.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is sent by the processor through webhooks, telling about something
+// that happened to one of its objects, such as a charge being refunded.
+type Event struct {
+	ID   string
+	Type string
+	// Object holds the JSON of the object the event is about.
+	Object json.RawMessage
+	// Payload is the whole event, exactly as it was received.
+	Payload json.RawMessage
+}
+
+// Charge is the object of charge.* events.
+type Charge struct {
+	ID string
+	// Refunded tells whether the whole amount was refunded, rather than just
+	// part of it.
+	Refunded bool
+	// RefundID is the most recent refund of the charge, if any.
+	RefundID string
+}
+
+// Dispute is the object of charge.dispute.* events.
+type Dispute struct {
+	ID       string
+	ChargeID string
+}
+
+// ParseWebhook verifies that the payload was signed with the given secret, as
+// told by its Stripe-Signature header, and decodes the event it holds. Stale
+// signatures are rejected so that payloads can't be replayed, and so are all
+// of them when no secret is configured.
+func ParseWebhook(payload []byte, header, secret string) (Event, error) {
+	if secret == "" {
+		return Event{}, ErrInvalidSignature
+	}
+
+	evt, err := webhook.ConstructEvent(payload, header, secret)
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	if evt.ID == "" || evt.Data == nil {
+		return Event{}, fmt.Errorf("%w: missing event id or data", ErrInvalidSignature)
+	}
+
+	return Event{
+		ID:      evt.ID,
+		Type:    evt.Type,
+		Object:  evt.Data.Raw,
+		Payload: payload,
+	}, nil
+}
+
+// SignWebhook returns the Stripe-Signature header of a payload signed with the
+// given secret at time t, the same way the processor does. It allows webhooks
+// to be sent locally, such as in tests.
+func SignWebhook(payload []byte, secret string, t time.Time) string {
+	sig := webhook.ComputeSignature(t, payload, secret)
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), hex.EncodeToString(sig))
+}
+
+// Charge decodes the event's object as a charge.
+func (e Event) Charge() (Charge, error) {
+	var ch stripe.Charge
+	if err := json.Unmarshal(e.Object, &ch); err != nil {
+		return Charge{}, fmt.Errorf("decode charge: %w", err)
+	}
+
+	c := Charge{ID: ch.ID, Refunded: ch.Refunded}
+	if ch.Refunds != nil && len(ch.Refunds.Data) > 0 {
+		// refunds are listed newest first
+		c.RefundID = ch.Refunds.Data[0].ID
+	}
+	return c, nil
+}
+
+// Dispute decodes the event's object as a dispute.
+func (e Event) Dispute() (Dispute, error) {
+	var dp stripe.Dispute
+	if err := json.Unmarshal(e.Object, &dp); err != nil {
+		return Dispute{}, fmt.Errorf("decode dispute: %w", err)
+	}
+
+	d := Dispute{ID: dp.ID}
+	if dp.Charge != nil {
+		d.ChargeID = dp.Charge.ID
+	}
+	return d, nil
+}
@@ -0,0 +1,98 @@
+//go:build unit
+// +build unit
+
+package payment
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebhook(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"id": "evt_1", "type": "charge.refunded", "data": {"object": {"id": "ch_1"}}}`)
+
+	t.Run("Valid signature", func(t *testing.T) {
+		evt, err := ParseWebhook(payload, SignWebhook(payload, secret, time.Now()), secret)
+		require.NoError(t, err)
+
+		assert.Equal(t, "evt_1", evt.ID)
+		assert.Equal(t, EventChargeRefunded, evt.Type)
+		assert.JSONEq(t, `{"id": "ch_1"}`, string(evt.Object))
+		assert.Equal(t, json.RawMessage(payload), evt.Payload)
+	})
+
+	t.Run("Invalid signatures", func(t *testing.T) {
+		tests := []struct {
+			desc   string
+			header string
+			secret string
+		}{
+			{desc: "missing header", header: "", secret: secret},
+			{desc: "malformed header", header: "foo", secret: secret},
+			{desc: "other secret", header: SignWebhook(payload, "whsec_other", time.Now()), secret: secret},
+			{desc: "stale signature", header: SignWebhook(payload, secret, time.Now().Add(-time.Hour)), secret: secret},
+			{desc: "no secret configured", header: SignWebhook(payload, "", time.Now()), secret: ""},
+		}
+
+		for _, tc := range tests {
+			_, err := ParseWebhook(payload, tc.header, tc.secret)
+			assert.ErrorIs(t, err, ErrInvalidSignature, tc.desc)
+		}
+	})
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		header := SignWebhook(payload, secret, time.Now())
+		tampered := []byte(`{"id": "evt_2", "type": "charge.refunded", "data": {"object": {"id": "ch_1"}}}`)
+
+		_, err := ParseWebhook(tampered, header, secret)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Missing event data", func(t *testing.T) {
+		p := []byte(`{"id": "evt_1", "type": "charge.refunded"}`)
+
+		_, err := ParseWebhook(p, SignWebhook(p, secret, time.Now()), secret)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+}
+
+func TestEventObjects(t *testing.T) {
+	t.Run("Charge", func(t *testing.T) {
+		evt := Event{Object: json.RawMessage(`{
+			"id": "ch_1",
+			"refunded": true,
+			"refunds": {"object": "list", "data": [{"id": "re_2"}, {"id": "re_1"}]}
+		}`)}
+
+		ch, err := evt.Charge()
+		require.NoError(t, err)
+		assert.Equal(t, Charge{ID: "ch_1", Refunded: true, RefundID: "re_2"}, ch)
+	})
+
+	t.Run("Charge without refunds", func(t *testing.T) {
+		ch, err := Event{Object: json.RawMessage(`{"id": "ch_1"}`)}.Charge()
+		require.NoError(t, err)
+		assert.Equal(t, Charge{ID: "ch_1"}, ch)
+	})
+
+	t.Run("Dispute", func(t *testing.T) {
+		dp, err := Event{Object: json.RawMessage(`{"id": "dp_1", "charge": "ch_1"}`)}.Dispute()
+		require.NoError(t, err)
+		assert.Equal(t, Dispute{ID: "dp_1", ChargeID: "ch_1"}, dp)
+	})
+
+	t.Run("Invalid objects", func(t *testing.T) {
+		evt := Event{Object: json.RawMessage(`[]`)}
+
+		_, err := evt.Charge()
+		assert.Error(t, err)
+
+		_, err = evt.Dispute()
+		assert.Error(t, err)
+	})
+}
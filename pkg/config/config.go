@@ -17,10 +17,29 @@ const (
 // Config stores all configuration of the application.
 // The values are read by viper from a config file or environment variable.
 type Config struct {
-	IdemKeyTimeout int    `mapstructure:"IDEM_KEY_TIMEOUT" validate:"required"`
-	DBSource       string `mapstructure:"DB_SOURCE"  validate:"required"`
-	ServerAddress  string `mapstructure:"SERVER_ADDRESS"  validate:"required"`
-	StripeKey      string `mapstructure:"STRIPE_KEY"  validate:"required"`
+	AuditChainKey         string `mapstructure:"AUDIT_CHAIN_KEY" validate:"required,min=32"`
+	IdemKeyTimeout        int    `mapstructure:"IDEM_KEY_TIMEOUT" validate:"required"`
+	IdemKeyRetention      int    `mapstructure:"IDEM_KEY_RETENTION" validate:"required"`
+	IdemKeyActionInterval int    `mapstructure:"IDEM_KEY_ACTION_INTERVAL" validate:"required"`
+	IdemKeyActionTimeout  int    `mapstructure:"IDEM_KEY_ACTION_TIMEOUT" validate:"required"`
+	DBSource              string `mapstructure:"DB_SOURCE"  validate:"required"`
+	MailDir               string `mapstructure:"MAIL_DIR" validate:"required_if=MailTransport file"`
+	MailFrom              string `mapstructure:"MAIL_FROM" validate:"required"`
+	MailTransport         string `mapstructure:"MAIL_TRANSPORT" validate:"oneof=smtp file memory"`
+	RideBaseFare          int64  `mapstructure:"RIDE_BASE_FARE" validate:"min=0"`
+	RideCurrency          string `mapstructure:"RIDE_CURRENCY" validate:"required,len=3"`
+	RideMinFare           int64  `mapstructure:"RIDE_MIN_FARE" validate:"min=0"`
+	RidePerKmFare         int64  `mapstructure:"RIDE_PER_KM_FARE" validate:"min=0"`
+	ServerAddress         string `mapstructure:"SERVER_ADDRESS"  validate:"required"`
+	SMTPAddr              string `mapstructure:"SMTP_ADDR" validate:"required_if=MailTransport smtp"`
+	SMTPPassword          string `mapstructure:"SMTP_PASSWORD"`
+	SMTPTimeout           int    `mapstructure:"SMTP_TIMEOUT" validate:"required"`
+	SMTPUsername          string `mapstructure:"SMTP_USERNAME"`
+	StripeKey             string `mapstructure:"STRIPE_KEY"  validate:"required"`
+	StripeWebhookSecret   string `mapstructure:"STRIPE_WEBHOOK_SECRET"`
+	WorkerBatch           int    `mapstructure:"WORKER_BATCH" validate:"required"`
+	WorkerInterval        int    `mapstructure:"WORKER_INTERVAL" validate:"required"`
+	WorkerMaxAttempts     int    `mapstructure:"WORKER_MAX_ATTEMPTS" validate:"required"`
 }
 
 // Load reads configuration from file or environment variables.
@@ -36,13 +55,46 @@ func LoadFromPath(cfgPath string) (config Config, err error) {
 	viper.SetConfigType(cfgFileType)
 
 	// bind env vars, to be used in case the config file is not found
+	_ = viper.BindEnv("AUDIT_CHAIN_KEY")
 	_ = viper.BindEnv("IDEM_KEY_TIMEOUT")
+	_ = viper.BindEnv("IDEM_KEY_RETENTION")
+	_ = viper.BindEnv("IDEM_KEY_ACTION_INTERVAL")
+	_ = viper.BindEnv("IDEM_KEY_ACTION_TIMEOUT")
 	_ = viper.BindEnv("DB_SOURCE")
+	_ = viper.BindEnv("MAIL_DIR")
+	_ = viper.BindEnv("MAIL_FROM")
+	_ = viper.BindEnv("MAIL_TRANSPORT")
+	_ = viper.BindEnv("RIDE_BASE_FARE")
+	_ = viper.BindEnv("RIDE_CURRENCY")
+	_ = viper.BindEnv("RIDE_MIN_FARE")
+	_ = viper.BindEnv("RIDE_PER_KM_FARE")
 	_ = viper.BindEnv("SERVER_ADDRESS")
+	_ = viper.BindEnv("SMTP_ADDR")
+	_ = viper.BindEnv("SMTP_PASSWORD")
+	_ = viper.BindEnv("SMTP_TIMEOUT")
+	_ = viper.BindEnv("SMTP_USERNAME")
 	_ = viper.BindEnv("STRIPE_KEY")
+	_ = viper.BindEnv("STRIPE_WEBHOOK_SECRET")
+	_ = viper.BindEnv("WORKER_BATCH")
+	_ = viper.BindEnv("WORKER_INTERVAL")
+	_ = viper.BindEnv("WORKER_MAX_ATTEMPTS")
 
 	// default config values
 	viper.SetDefault("IDEM_KEY_TIMEOUT", 5)
+	viper.SetDefault("IDEM_KEY_RETENTION", 72)
+	viper.SetDefault("IDEM_KEY_ACTION_INTERVAL", 300)
+	viper.SetDefault("IDEM_KEY_ACTION_TIMEOUT", 24)
+	viper.SetDefault("MAIL_DIR", "mail")
+	viper.SetDefault("MAIL_FROM", "Rocket Rides <receipts@rocketrides.io>")
+	viper.SetDefault("MAIL_TRANSPORT", "file")
+	viper.SetDefault("RIDE_BASE_FARE", 500)
+	viper.SetDefault("RIDE_CURRENCY", "usd")
+	viper.SetDefault("RIDE_MIN_FARE", 2000)
+	viper.SetDefault("RIDE_PER_KM_FARE", 150)
+	viper.SetDefault("SMTP_TIMEOUT", 30)
+	viper.SetDefault("WORKER_BATCH", 1000)
+	viper.SetDefault("WORKER_INTERVAL", 5)
+	viper.SetDefault("WORKER_MAX_ATTEMPTS", 5)
 
 	// enable loading of env vars values
 	// it'll override the values in the config file
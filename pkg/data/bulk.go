@@ -0,0 +1,60 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/uptrace/bun/dialect/sqltype"
+	"github.com/uptrace/bun/schema"
+)
+
+var jsonRawMessageType = reflect.TypeOf((*json.RawMessage)(nil)).Elem()
+
+// bulkValues appends the fields of a slice of models as the rows of a VALUES
+// list, which bulk updates join their table with. It stands in for the one bun
+// builds on Bulk, which casts json.RawMessage values to BYTEA: Postgres can't
+// assign those to JSONB columns, so they're cast to JSONB here instead.
+type bulkValues struct {
+	fields []*schema.Field
+	models reflect.Value
+}
+
+func (v bulkValues) AppendColumns(fmter schema.Formatter, b []byte) ([]byte, error) {
+	for i, f := range v.fields {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, f.SQLName...)
+	}
+	return b, nil
+}
+
+func (v bulkValues) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	b = append(b, "VALUES "...)
+	for i := 0; i < v.models.Len(); i++ {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+
+		b = append(b, '(')
+		for j, f := range v.fields {
+			if j > 0 {
+				b = append(b, ", "...)
+			}
+			b = f.AppendValue(fmter, b, v.models.Index(i))
+			b = append(b, "::"...)
+			b = append(b, bulkType(f)...)
+		}
+		b = append(b, ')')
+	}
+	return b, nil
+}
+
+// bulkType returns the type the field's values are cast to, which is JSONB for
+// raw JSON unless the field's tag tells otherwise.
+func bulkType(f *schema.Field) string {
+	if _, ok := f.Tag.Option("type"); !ok && f.IndirectType == jsonRawMessageType {
+		return sqltype.JSONB
+	}
+	return f.UserSQLType
+}
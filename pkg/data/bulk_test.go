@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/schema"
+)
+
+type event struct {
+	ID   int64
+	Data json.RawMessage
+	Raw  json.RawMessage `bun:"type:json"`
+}
+
+func TestBulkValues(t *testing.T) {
+	db := testDB()
+	table := db.Dialect().Tables().Get(reflect.TypeOf(event{}))
+
+	events := []event{
+		{ID: 1, Data: json.RawMessage(`{"a": "\"b\""}`), Raw: json.RawMessage(`[]`)},
+		{ID: 2},
+	}
+	v := bulkValues{
+		fields: []*schema.Field{table.FieldMap["id"], table.FieldMap["data"], table.FieldMap["raw"]},
+		models: reflect.ValueOf(events),
+	}
+
+	b, err := v.AppendColumns(db.Formatter(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, `"id", "data", "raw"`, string(b))
+
+	// raw JSON is cast to JSONB rather than BYTEA, unless told otherwise
+	b, err = v.AppendQuery(db.Formatter(), nil)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`VALUES (1::BIGINT, '{"a": "\"b\""}'::JSONB, '[]'::json), (2::BIGINT, NULL::JSONB, 'null'::json)`,
+		string(b),
+	)
+}
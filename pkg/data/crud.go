@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"reflect"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
 )
 
 var ErrRecordNotFound = errors.New("record not found")
@@ -15,9 +17,16 @@ type SelectCriteria func(*bun.SelectQuery) *bun.SelectQuery
 type ICRUDStore[T any] interface {
 	FindAll(context.Context, ...SelectCriteria) ([]T, error)
 	FindOne(context.Context, ...SelectCriteria) (T, error)
+	FindPage(context.Context, PageRequest, ...SelectCriteria) (Page[T], error)
+	Count(context.Context, ...SelectCriteria) (int, error)
 	Delete(context.Context, *T) error
+	DeleteWhere(context.Context, ...SelectCriteria) (int, error)
 	Save(context.Context, *T) error
+	SaveMany(context.Context, []T) (int, error)
+	SaveOrGet(context.Context, *T, ...SelectCriteria) (bool, error)
 	Update(context.Context, *T) error
+	UpdateMany(context.Context, []T, ...string) (int, error)
+	Upsert(ctx context.Context, model *T, conflictCols, updateCols []string) error
 }
 
 type CRUDStore[T any] struct {
@@ -37,7 +46,7 @@ func (c CRUDStore[T]) FindAll(ctx context.Context, sc ...SelectCriteria) ([]T, e
 	}
 
 	err := q.Scan(ctx)
-	return rows, err
+	return rows, lockError(err)
 }
 
 func (c CRUDStore[T]) FindOne(ctx context.Context, sc ...SelectCriteria) (T, error) {
@@ -52,7 +61,63 @@ func (c CRUDStore[T]) FindOne(ctx context.Context, sc ...SelectCriteria) (T, err
 	if errors.Is(err, sql.ErrNoRows) {
 		return row, ErrRecordNotFound
 	}
-	return row, err
+	return row, lockError(err)
+}
+
+// FindPage returns a page of the rows matching the criteria, which are paged
+// through by keyset pagination, as sorted by the requested order. Criteria
+// must neither sort nor limit rows, that's up to the request.
+func (c CRUDStore[T]) FindPage(ctx context.Context, pr PageRequest, sc ...SelectCriteria) (Page[T], error) {
+	var rows []T
+
+	ks, err := c.keyset(pr)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	limit := pr.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+
+	q := c.DB.NewSelect().Model(&rows)
+	for i := range sc {
+		q.Apply(sc[i])
+	}
+
+	if pr.Cursor != "" {
+		key, err := ks.decode(pr.Cursor)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		q.Apply(ks.after(key))
+	}
+
+	// an extra row tells whether there's a next page
+	if err := q.Apply(OrderBy(ks.orders...)).Limit(limit + 1).Scan(ctx); err != nil {
+		return Page[T]{}, lockError(err)
+	}
+
+	page := Page[T]{Rows: rows}
+	if len(rows) > limit {
+		page.Rows = rows[:limit]
+		page.NextCursor, err = ks.encode(reflect.ValueOf(&page.Rows[limit-1]).Elem())
+	}
+	return page, err
+}
+
+// Count returns how many rows match the criteria.
+func (c CRUDStore[T]) Count(ctx context.Context, sc ...SelectCriteria) (int, error) {
+	q := c.DB.NewSelect().Model((*T)(nil))
+	for i := range sc {
+		q.Apply(sc[i])
+	}
+
+	return q.Count(ctx)
+}
+
+func (c CRUDStore[T]) keyset(pr PageRequest) (keyset, error) {
+	return newKeyset(c.table(), pr.OrderBy)
 }
 
 func (c CRUDStore[T]) Save(ctx context.Context, model *T) error {
@@ -60,12 +125,254 @@ func (c CRUDStore[T]) Save(ctx context.Context, model *T) error {
 	return err
 }
 
+// SaveMany saves all the models at once, returning how many were saved. Just
+// like Save, the models are loaded with the rows as they were saved.
+func (c CRUDStore[T]) SaveMany(ctx context.Context, models []T) (int, error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	res, err := c.DB.NewInsert().Model(&models).Returning("*").Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return affected(res)
+}
+
+// SaveOrGet saves the model unless it conflicts with a row already there, as
+// told by any of the table's unique constraints, in which case the row matching
+// the criteria is loaded into the model instead. Criteria may as well lock that
+// row, e.g. with ForUpdate. It reports whether the model was saved.
+//
+// Note that under READ COMMITTED a conflicting row committed by a concurrent
+// transaction is visible to the select that follows the insert, though under
+// REPEATABLE READ and SERIALIZABLE it's a serialization failure instead.
+func (c CRUDStore[T]) SaveOrGet(ctx context.Context, model *T, sc ...SelectCriteria) (bool, error) {
+	res, err := c.DB.NewInsert().Model(model).On("CONFLICT DO NOTHING").Returning("*").Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+
+	q := c.DB.NewSelect().Model(model)
+	for i := range sc {
+		q.Apply(sc[i])
+	}
+
+	err = q.Limit(1).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrRecordNotFound
+	}
+	return false, lockError(err)
+}
+
+// Upsert saves the model or, if it conflicts with a row already there on the
+// conflict columns, updates that row's update columns instead, or all of its
+// columns when none is given. Either way, the model is loaded with the row as
+// it's left. Versioned rows have their version bumped when updated, though
+// unlike Update it isn't checked.
+func (c CRUDStore[T]) Upsert(ctx context.Context, model *T, conflictCols, updateCols []string) error {
+	q := c.DB.NewInsert().Model(model).On("CONFLICT (?) DO UPDATE", idents(conflictCols))
+
+	// versioned rows get their version bumped, no matter the model's
+	if versionField(c.table()) != nil {
+		updateCols = c.unversioned(updateCols)
+		q.Set("? = ?TableAlias.? + 1", bun.Ident(versionColumn), bun.Ident(versionColumn))
+	}
+	for _, col := range updateCols {
+		q.Set("? = EXCLUDED.?", bun.Ident(col), bun.Ident(col))
+	}
+
+	_, err := q.Returning("*").Exec(ctx)
+	return err
+}
+
+func idents(cols []string) bun.InValues {
+	ids := make([]bun.Ident, len(cols))
+	for i, col := range cols {
+		ids[i] = bun.Ident(col)
+	}
+	return bun.In(ids)
+}
+
 func (c CRUDStore[T]) Delete(ctx context.Context, model *T) error {
 	_, err := c.DB.NewDelete().Model(model).WherePK().Exec(ctx)
 	return err
 }
 
+// DeleteWhere deletes the rows matching the criteria, returning how many were
+// deleted. Criteria may limit, and lock, the rows to be deleted, though they
+// must match rows of the table's own.
+func (c CRUDStore[T]) DeleteWhere(ctx context.Context, sc ...SelectCriteria) (int, error) {
+	if err := c.checkPKs(); err != nil {
+		return 0, err
+	}
+
+	sq := c.DB.NewSelect().Model((*T)(nil)).ColumnExpr("?TablePKs")
+	for i := range sc {
+		sq.Apply(sc[i])
+	}
+
+	res, err := c.DB.NewDelete().Model((*T)(nil)).Where("(?TablePKs) IN (?)", sq).Exec(ctx)
+	if err != nil {
+		return 0, lockError(err)
+	}
+	return affected(res)
+}
+
+// Update updates the model's row by its primary key, loading the model with
+// the row as it was updated. Versioned models are only updated if their row's
+// version is still the one they were read with, ErrStaleRecord is returned
+// otherwise, and have their version bumped.
 func (c CRUDStore[T]) Update(ctx context.Context, model *T) error {
-	_, err := c.DB.NewUpdate().Model(model).WherePK().Returning("*").Exec(ctx)
+	q := c.DB.NewUpdate().Model(model).WherePK()
+
+	vf := versionField(c.table())
+	if vf == nil {
+		_, err := q.Returning("*").Exec(ctx)
+		return err
+	}
+
+	v := vf.Value(reflect.ValueOf(model).Elem())
+	version := v.Int()
+	v.SetInt(version + 1)
+
+	res, err := q.Where("?TableAlias.? = ?", bun.Ident(versionColumn), version).Returning("*").Exec(ctx)
+	if err == nil {
+		var n int
+		if n, err = affected(res); err == nil && n == 0 {
+			err = ErrStaleRecord
+		}
+	}
+	if err != nil {
+		v.SetInt(version)
+	}
 	return err
 }
+
+// UpdateMany updates the rows of all the models at once by their primary keys,
+// returning how many were updated. Only the given columns are updated, or all
+// of them when none is given. Unlike Update, models aren't loaded with the
+// rows as they were updated.
+//
+// Versioned models are updated just like Update does, though ErrStaleRecord is
+// returned if any of them is stale, in which case the others are updated all
+// the same: it's up to the caller to roll the transaction back.
+func (c CRUDStore[T]) UpdateMany(ctx context.Context, models []T, columns ...string) (int, error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	table := c.table()
+	vf := versionField(table)
+
+	fields, err := c.dataFields(columns, vf)
+	if err != nil {
+		return 0, err
+	}
+
+	values := append(append([]*schema.Field{}, table.PKs...), fields...)
+	if vf != nil {
+		values = append(values, vf)
+	}
+
+	q := c.DB.NewUpdate().
+		With("_data", bulkValues{fields: values, models: reflect.ValueOf(models)}).
+		Model(&models).
+		TableExpr("_data")
+	for _, f := range fields {
+		q.Set("? = _data.?", f.SQLName, f.SQLName)
+	}
+	for _, pk := range table.PKs {
+		q.Where("?TableAlias.? = _data.?", pk.SQLName, pk.SQLName)
+	}
+
+	if vf == nil {
+		res, err := q.Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return affected(res)
+	}
+
+	res, err := q.
+		Set("? = _data.? + 1", vf.SQLName, vf.SQLName).
+		Where("?TableAlias.? = _data.?", vf.SQLName, vf.SQLName).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := affected(res)
+	if err != nil {
+		return n, err
+	}
+	if n < len(models) {
+		return n, ErrStaleRecord
+	}
+
+	for i := range models {
+		v := vf.Value(reflect.ValueOf(&models[i]).Elem())
+		v.SetInt(v.Int() + 1)
+	}
+	return n, nil
+}
+
+// dataFields returns the fields of the given columns, or all of the data fields
+// when none is given, but the version field, which is bumped rather than
+// updated.
+func (c CRUDStore[T]) dataFields(columns []string, vf *schema.Field) ([]*schema.Field, error) {
+	table := c.table()
+	if len(columns) == 0 {
+		columns = make([]string, len(table.DataFields))
+		for i, f := range table.DataFields {
+			columns[i] = f.Name
+		}
+	}
+
+	var fields []*schema.Field
+	for _, col := range columns {
+		f, err := table.Field(col)
+		if err != nil {
+			return nil, err
+		}
+		if f != vf {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// unversioned returns the given columns, or all of them when none is given,
+// but the version column, which is bumped rather than updated.
+func (c CRUDStore[T]) unversioned(columns []string) []string {
+	if len(columns) == 0 {
+		for _, f := range c.table().DataFields {
+			columns = append(columns, f.Name)
+		}
+	}
+
+	var cols []string
+	for _, col := range columns {
+		if col != versionColumn {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func (c CRUDStore[T]) table() *schema.Table {
+	return c.DB.Dialect().Tables().Get(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func (c CRUDStore[T]) checkPKs() error {
+	return c.table().CheckPKs()
+}
+
+func affected(res sql.Result) (int, error) {
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -21,6 +21,20 @@ type book struct {
 	Author string
 }
 
+// note is versioned, so it's updated under optimistic locking.
+type note struct {
+	ID      int64
+	Title   string `bun:",unique"`
+	Body    string
+	Version int
+}
+
+type member struct {
+	ID    int64
+	Email string `bun:",unique"`
+	Name  string
+}
+
 func TestCRUDRepository(t *testing.T) {
 	ctx := context.Background()
 
@@ -34,6 +48,12 @@ func TestCRUDRepository(t *testing.T) {
 	_, err = db.NewCreateTable().Model(&book{}).Exec(ctx)
 	require.NoError(t, err)
 
+	_, err = db.NewCreateTable().Model(&member{}).Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewCreateTable().Model(&note{}).Exec(ctx)
+	require.NoError(t, err)
+
 	data := New[book](db)
 	books := []book{
 		{Title: "foo1", Author: "bar1"},
@@ -104,4 +124,282 @@ func TestCRUDRepository(t *testing.T) {
 		assert.Equal(t, 1, len(bks))
 		assert.Equal(t, books[1], bks[0])
 	})
+
+	t.Run("count", func(t *testing.T) {
+		more := []book{
+			{Title: "foo4", Author: "bar1"},
+			{Title: "foo5", Author: "bar2"},
+			{Title: "foo6", Author: "bar1"},
+			{Title: "foo7", Author: "bar2"},
+		}
+		for i := range more {
+			err = data.Save(ctx, &more[i])
+			require.NoError(t, err)
+		}
+		books = append(books[1:], more...)
+
+		n, err := data.Count(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, len(books), n)
+
+		c := func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("author = ?", "bar1")
+		}
+
+		// ordering and limits are ignored while counting
+		n, err = data.Count(ctx, c, OrderBy(Desc("id")), Limit(1))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("find all with order and limit", func(t *testing.T) {
+		bks, err := data.FindAll(ctx, OrderBy(Desc("id")), Limit(2))
+		assert.NoError(t, err)
+		assert.Equal(t, []book{books[4], books[3]}, bks)
+	})
+
+	t.Run("find page", func(t *testing.T) {
+		var (
+			pr    = PageRequest{Limit: 2}
+			pages [][]book
+		)
+		for {
+			page, err := data.FindPage(ctx, pr)
+			require.NoError(t, err)
+
+			pages = append(pages, page.Rows)
+			if page.NextCursor == "" {
+				break
+			}
+			pr.Cursor = page.NextCursor
+		}
+
+		assert.Equal(t, [][]book{books[:2], books[2:4], books[4:]}, pages)
+	})
+
+	t.Run("find page with order and criteria", func(t *testing.T) {
+		c := func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("title <> ?", books[0].Title)
+		}
+		pr := PageRequest{Limit: 3, OrderBy: []Order{Desc("author"), Asc("id")}}
+
+		page, err := data.FindPage(ctx, pr, c)
+		require.NoError(t, err)
+		assert.Equal(t, []book{books[2], books[4], books[1]}, page.Rows)
+		require.NotEmpty(t, page.NextCursor)
+
+		pr.Cursor = page.NextCursor
+		page, err = data.FindPage(ctx, pr, c)
+		require.NoError(t, err)
+		assert.Equal(t, []book{books[3]}, page.Rows)
+		assert.Empty(t, page.NextCursor)
+
+		// a cursor only works with the order it was handed out for
+		pr.OrderBy = []Order{Asc("id")}
+		_, err = data.FindPage(ctx, pr, c)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("find page with exact limit", func(t *testing.T) {
+		page, err := data.FindPage(ctx, PageRequest{Limit: len(books)})
+		require.NoError(t, err)
+		assert.Equal(t, books, page.Rows)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	members := New[member](db)
+	withEmail := func(email string) SelectCriteria {
+		return func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("email = ?", email)
+		}
+	}
+
+	t.Run("save or get", func(t *testing.T) {
+		m1 := member{Email: "jane@example.com", Name: "Jane"}
+		saved, err := members.SaveOrGet(ctx, &m1, withEmail(m1.Email))
+		require.NoError(t, err)
+		assert.True(t, saved)
+		assert.NotZero(t, m1.ID)
+
+		// the member already there is loaded in place of the conflicting one
+		m2 := member{Email: m1.Email, Name: "Jane Doe"}
+		saved, err = members.SaveOrGet(ctx, &m2, withEmail(m2.Email), ForUpdate())
+		require.NoError(t, err)
+		assert.False(t, saved)
+		assert.Equal(t, m1, m2)
+
+		m3 := member{Email: m1.Email, Name: "Jane Doe"}
+		_, err = members.SaveOrGet(ctx, &m3, withEmail("john@example.com"))
+		assert.ErrorIs(t, err, ErrRecordNotFound)
+
+		n, err := members.Count(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		m1 := member{Email: "john@example.com", Name: "John"}
+		err := members.Upsert(ctx, &m1, []string{"email"}, []string{"name"})
+		require.NoError(t, err)
+		assert.NotZero(t, m1.ID)
+
+		m2 := member{Email: m1.Email, Name: "John Doe"}
+		err = members.Upsert(ctx, &m2, []string{"email"}, []string{"name"})
+		require.NoError(t, err)
+		assert.Equal(t, member{ID: m1.ID, Email: m1.Email, Name: "John Doe"}, m2)
+
+		// every column is updated when none is given
+		m3 := member{Email: m1.Email, Name: "Johnny"}
+		err = members.Upsert(ctx, &m3, []string{"email"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, member{ID: m1.ID, Email: m1.Email, Name: "Johnny"}, m3)
+
+		m, err := members.FindOne(ctx, withEmail(m1.Email))
+		assert.NoError(t, err)
+		assert.Equal(t, m3, m)
+	})
+
+	t.Run("locking", func(t *testing.T) {
+		all, err := members.FindAll(ctx, OrderBy(Asc("id")))
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+
+		tx1, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer func() { _ = tx1.Rollback() }()
+
+		locked, err := New[member](tx1).FindOne(ctx, withEmail(all[0].Email), ForUpdate())
+		require.NoError(t, err)
+		assert.Equal(t, all[0], locked)
+
+		tx2, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer func() { _ = tx2.Rollback() }()
+
+		// rows locked by someone else are skipped
+		mbs, err := New[member](tx2).FindAll(ctx, SkipLocked())
+		assert.NoError(t, err)
+		assert.Equal(t, all[1:], mbs)
+
+		// or fail right away
+		_, err = New[member](tx2).FindOne(ctx, withEmail(all[0].Email), NoWait())
+		assert.ErrorIs(t, err, ErrLockNotAvailable)
+	})
+
+	more := []member{
+		{Email: "alice@example.com", Name: "Alice"},
+		{Email: "bob@example.com", Name: "Bob"},
+		{Email: "carol@example.com", Name: "Carol"},
+	}
+
+	t.Run("save many", func(t *testing.T) {
+		n, err := members.SaveMany(ctx, more)
+		require.NoError(t, err)
+		assert.Equal(t, len(more), n)
+		for _, m := range more {
+			assert.NotZero(t, m.ID)
+		}
+
+		n, err = members.SaveMany(ctx, nil)
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+
+		mbs, err := members.FindAll(ctx, OrderBy(Asc("id")))
+		assert.NoError(t, err)
+		assert.Equal(t, more, mbs[2:])
+	})
+
+	t.Run("update many", func(t *testing.T) {
+		updated := make([]member, len(more))
+		for i, m := range more {
+			updated[i] = member{ID: m.ID, Email: "nobody@example.com", Name: m.Name + " Doe"}
+		}
+
+		// only the given columns are updated
+		n, err := members.UpdateMany(ctx, updated, "name")
+		require.NoError(t, err)
+		assert.Equal(t, len(more), n)
+
+		for i := range more {
+			more[i].Name += " Doe"
+		}
+
+		mbs, err := members.FindAll(ctx, OrderBy(Asc("id")))
+		assert.NoError(t, err)
+		assert.Equal(t, more, mbs[2:])
+
+		_, err = members.UpdateMany(ctx, updated, "nickname")
+		assert.Error(t, err)
+	})
+
+	t.Run("delete where", func(t *testing.T) {
+		n, err := members.DeleteWhere(ctx, withEmail("nobody@example.com"))
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+
+		n, err = members.DeleteWhere(ctx, OrderBy(Desc("id")), Limit(2))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		mbs, err := members.FindAll(ctx, OrderBy(Asc("id")))
+		assert.NoError(t, err)
+		assert.Len(t, mbs, 3)
+		assert.Equal(t, more[0], mbs[2])
+	})
+
+	notes := New[note](db)
+
+	t.Run("update versioned model", func(t *testing.T) {
+		n := note{Title: "foo", Body: "bar"}
+		require.NoError(t, notes.Save(ctx, &n))
+		assert.Zero(t, n.Version)
+
+		stale := n
+
+		n.Body = "baz"
+		require.NoError(t, notes.Update(ctx, &n))
+		assert.Equal(t, 1, n.Version)
+
+		// the row was updated since the stale copy was read
+		stale.Body = "qux"
+		err := notes.Update(ctx, &stale)
+		assert.ErrorIs(t, err, ErrStaleRecord)
+		assert.Zero(t, stale.Version)
+
+		nt, err := notes.FindOne(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, n, nt)
+	})
+
+	t.Run("update many versioned models", func(t *testing.T) {
+		nts := []note{{Title: "foo1"}, {Title: "foo2"}}
+		_, err := notes.SaveMany(ctx, nts)
+		require.NoError(t, err)
+
+		for i := range nts {
+			nts[i].Body = "bar"
+		}
+		n, err := notes.UpdateMany(ctx, nts, "body")
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 1, nts[0].Version)
+		assert.Equal(t, 1, nts[1].Version)
+
+		// one of them is stale
+		stale := []note{nts[0], nts[1]}
+		stale[1].Version = 0
+		n, err = notes.UpdateMany(ctx, stale)
+		assert.ErrorIs(t, err, ErrStaleRecord)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("upsert versioned model", func(t *testing.T) {
+		n := note{Title: "foo", Body: "upserted"}
+		err := notes.Upsert(ctx, &n, []string{"title"}, nil)
+		require.NoError(t, err)
+
+		// the version is bumped rather than taken from the model
+		assert.Equal(t, 2, n.Version)
+		assert.Equal(t, "upserted", n.Body)
+	})
 }
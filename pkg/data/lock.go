@@ -0,0 +1,46 @@
+package data
+
+import (
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrLockNotAvailable is returned when rows selected with NoWait are already
+// locked by someone else.
+var ErrLockNotAvailable = errors.New("lock not available")
+
+// ForUpdate locks the selected rows until the end of the current transaction,
+// waiting for the ones already locked by someone else to be released.
+func ForUpdate() SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.For("UPDATE")
+	}
+}
+
+// SkipLocked locks the selected rows just like ForUpdate does, though rows
+// already locked by someone else are skipped rather than waited for. That's
+// what queue-style consumers need to never work on the same rows.
+func SkipLocked() SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.For("UPDATE SKIP LOCKED")
+	}
+}
+
+// NoWait locks the selected rows just like ForUpdate does, though the query
+// fails right away with ErrLockNotAvailable if any of them is already locked
+// by someone else. Just like any other error, it aborts the transaction.
+func NoWait() SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.For("UPDATE NOWAIT")
+	}
+}
+
+// lockError replaces the error Postgres returns when a lock isn't available
+// with ErrLockNotAvailable.
+func lockError(err error) error {
+	if SQLState(err) == "55P03" { // lock_not_available
+		return ErrLockNotAvailable
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+//go:build unit
+// +build unit
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pgError map[byte]string
+
+func (e pgError) Field(k byte) string {
+	return e[k]
+}
+
+func (e pgError) Error() string {
+	return e['M']
+}
+
+func TestLockCriteria(t *testing.T) {
+	for lock, sc := range map[string]SelectCriteria{
+		"UPDATE":             ForUpdate(),
+		"UPDATE SKIP LOCKED": SkipLocked(),
+		"UPDATE NOWAIT":      NoWait(),
+	} {
+		q := testDB().NewSelect().Model((*article)(nil)).Where("id = ?", 3).Apply(sc)
+
+		assert.Equal(
+			t,
+			`SELECT "article"."id", "article"."title", "article"."published_at" FROM "articles" AS "article" `+
+				`WHERE (id = 3) FOR `+lock,
+			queryString(t, q),
+		)
+	}
+}
+
+func TestLockError(t *testing.T) {
+	err := pgError{'C': "55P03", 'M': "could not obtain lock on row"}
+	assert.Equal(t, ErrLockNotAvailable, lockError(err))
+	assert.Equal(t, ErrLockNotAvailable, lockError(fmt.Errorf("select: %w", err)))
+
+	other := pgError{'C': "40001", 'M': "could not serialize access"}
+	assert.Equal(t, error(other), lockError(other))
+
+	assert.Nil(t, lockError(nil))
+	assert.EqualError(t, lockError(errors.New("foo")), "foo")
+}
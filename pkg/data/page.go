@@ -0,0 +1,198 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// DefaultPageLimit is how many rows a page holds when no limit is requested.
+const DefaultPageLimit = 50
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Order is a column rows are sorted by.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+func Asc(column string) Order {
+	return Order{Column: column}
+}
+
+func Desc(column string) Order {
+	return Order{Column: column, Desc: true}
+}
+
+func (o Order) String() string {
+	if o.Desc {
+		return "-" + o.Column
+	}
+	return o.Column
+}
+
+// OrderBy sorts rows by the given columns, in the given order.
+func OrderBy(orders ...Order) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, o := range orders {
+			if o.Desc {
+				q = q.OrderExpr("?TableAlias.? DESC", bun.Ident(o.Column))
+			} else {
+				q = q.OrderExpr("?TableAlias.? ASC", bun.Ident(o.Column))
+			}
+		}
+		return q
+	}
+}
+
+// Limit returns at most n rows.
+func Limit(n int) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Limit(n)
+	}
+}
+
+// PageRequest tells which page FindPage should return.
+type PageRequest struct {
+	// Cursor is the NextCursor of the page before, it's empty for the first
+	// page.
+	Cursor string
+	// Limit is the most rows the page holds, DefaultPageLimit if not set.
+	Limit int
+	// OrderBy are the columns rows are sorted, and paged through, by. They
+	// must be not null and, taken together, unique, which is why the primary
+	// key usually comes last. It defaults to the primary key, ascending.
+	OrderBy []Order
+}
+
+// Page is a page of rows, along with the cursor pointing right after them.
+type Page[T any] struct {
+	Rows []T
+	// NextCursor is an opaque value to be handed back to FindPage for the next
+	// page, it's empty if this is the last one.
+	NextCursor string
+}
+
+// cursor is what the opaque cursors handed out by FindPage are made of. The
+// order is kept along with the key, so that a cursor isn't mistakenly used
+// with a different order.
+type cursor struct {
+	Order []string          `json:"o"`
+	Key   []json.RawMessage `json:"k"`
+}
+
+// keyset is the keyset pagination of a table by a given order.
+type keyset struct {
+	orders []Order
+	fields []*schema.Field
+}
+
+func newKeyset(table *schema.Table, orders []Order) (keyset, error) {
+	if len(orders) == 0 {
+		for _, pk := range table.PKs {
+			orders = append(orders, Asc(pk.Name))
+		}
+	}
+	if len(orders) == 0 {
+		return keyset{}, fmt.Errorf("no order to page %v by", table.Name)
+	}
+
+	ks := keyset{orders: orders, fields: make([]*schema.Field, len(orders))}
+	for i, o := range orders {
+		f, ok := table.FieldMap[o.Column]
+		if !ok {
+			return keyset{}, fmt.Errorf("unknown column %q to page %v by", o.Column, table.Name)
+		}
+		ks.fields[i] = f
+	}
+	return ks, nil
+}
+
+// encode returns the cursor pointing right after the given row.
+func (ks keyset) encode(row reflect.Value) (string, error) {
+	c := cursor{Order: ks.order(), Key: make([]json.RawMessage, len(ks.fields))}
+	for i, f := range ks.fields {
+		b, err := json.Marshal(f.Value(row).Interface())
+		if err != nil {
+			return "", err
+		}
+		c.Key[i] = b
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decode returns the key of the row the cursor points right after.
+func (ks keyset) decode(s string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if !reflect.DeepEqual(c.Order, ks.order()) || len(c.Key) != len(ks.fields) {
+		return nil, fmt.Errorf("%w: it belongs to another order", ErrInvalidCursor)
+	}
+
+	key := make([]interface{}, len(ks.fields))
+	for i, f := range ks.fields {
+		v := reflect.New(f.StructField.Type)
+		if err := json.Unmarshal(c.Key[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		key[i] = v.Elem().Interface()
+	}
+	return key, nil
+}
+
+func (ks keyset) order() []string {
+	o := make([]string, len(ks.orders))
+	for i := range ks.orders {
+		o[i] = ks.orders[i].String()
+	}
+	return o
+}
+
+// after selects the rows coming after the given key, in the keyset's order.
+// Given columns (a, b), that's 'a > ? OR (a = ? AND b > ?)', with '<' taking
+// the place of '>' for the columns sorted in descending order.
+func (ks keyset) after(key []interface{}) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for i, o := range ks.orders {
+				var (
+					expr string
+					args []interface{}
+				)
+				for j := 0; j < i; j++ {
+					expr += "?TableAlias.? = ? AND "
+					args = append(args, bun.Ident(ks.orders[j].Column), key[j])
+				}
+
+				if o.Desc {
+					expr += "?TableAlias.? < ?"
+				} else {
+					expr += "?TableAlias.? > ?"
+				}
+				args = append(args, bun.Ident(o.Column), key[i])
+
+				q = q.WhereOr(expr, args...)
+			}
+			return q
+		})
+	}
+}
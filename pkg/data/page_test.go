@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package data
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+type article struct {
+	ID          int64
+	Title       string
+	PublishedAt time.Time
+}
+
+// tag has no primary key to page by.
+type tag struct {
+	Name string
+}
+
+// testDB returns a db good enough for building queries, it never connects to
+// any database.
+func testDB() *bun.DB {
+	return bun.NewDB(sql.OpenDB(pgdriver.NewConnector()), pgdialect.New())
+}
+
+func queryString(t *testing.T, q *bun.SelectQuery) string {
+	b, err := q.AppendQuery(testDB().Formatter(), nil)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func testKeyset(t *testing.T, model interface{}, orders ...Order) keyset {
+	table := testDB().Dialect().Tables().Get(reflect.TypeOf(model).Elem())
+
+	ks, err := newKeyset(table, orders)
+	require.NoError(t, err)
+	return ks
+}
+
+func TestNewKeyset(t *testing.T) {
+	tables := testDB().Dialect().Tables()
+
+	ks, err := newKeyset(tables.Get(reflect.TypeOf(article{})), nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Order{Asc("id")}, ks.orders)
+	}
+
+	_, err = newKeyset(tables.Get(reflect.TypeOf(article{})), []Order{Desc("author")})
+	assert.Error(t, err)
+
+	_, err = newKeyset(tables.Get(reflect.TypeOf(tag{})), nil)
+	assert.Error(t, err)
+}
+
+func TestCursor(t *testing.T) {
+	ks := testKeyset(t, (*article)(nil), Desc("published_at"), Asc("title"), Asc("id"))
+	a := article{ID: 3, Title: "foo", PublishedAt: time.Date(2022, 1, 2, 3, 4, 5, 6000, time.UTC)}
+
+	c, err := ks.encode(reflect.ValueOf(a))
+	require.NoError(t, err)
+
+	key, err := ks.decode(c)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{a.PublishedAt, a.Title, a.ID}, key)
+	}
+
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	for desc, c := range map[string]string{
+		"not base64":    "!!!",
+		"not json":      encode("foo"),
+		"another order": encode(`{"o": ["published_at", "title", "id"], "k": ["2022-01-02T03:04:05Z", "foo", 3]}`),
+		"missing key":   encode(`{"o": ["-published_at", "title", "id"], "k": ["2022-01-02T03:04:05Z", "foo"]}`),
+		"wrong type":    encode(`{"o": ["-published_at", "title", "id"], "k": ["2022-01-02T03:04:05Z", "foo", "3"]}`),
+	} {
+		_, err := ks.decode(c)
+		assert.ErrorIs(t, err, ErrInvalidCursor, desc)
+	}
+}
+
+func TestKeysetAfter(t *testing.T) {
+	ks := testKeyset(t, (*article)(nil), Desc("title"), Asc("id"))
+
+	q := testDB().NewSelect().
+		Model((*article)(nil)).
+		Where("title <> ?", "bar").
+		Apply(ks.after([]interface{}{"foo", int64(3)}))
+
+	assert.Equal(
+		t,
+		`SELECT "article"."id", "article"."title", "article"."published_at" FROM "articles" AS "article" `+
+			`WHERE (title <> 'bar') AND (("article"."title" < 'foo') OR ("article"."title" = 'foo' AND "article"."id" > 3))`,
+		queryString(t, q),
+	)
+}
+
+func TestOrderByAndLimit(t *testing.T) {
+	q := testDB().NewSelect().
+		Model((*article)(nil)).
+		Apply(OrderBy(Desc("published_at"), Asc("id"))).
+		Apply(Limit(10))
+
+	assert.Equal(
+		t,
+		`SELECT "article"."id", "article"."title", "article"."published_at" FROM "articles" AS "article" `+
+			`ORDER BY "article"."published_at" DESC, "article"."id" ASC LIMIT 10`,
+		queryString(t, q),
+	)
+}
@@ -0,0 +1,19 @@
+package data
+
+import "errors"
+
+// sqlStateError is implemented by the errors returned by Postgres drivers,
+// which hold the SQLSTATE code in the 'C' field.
+type sqlStateError interface {
+	Field(byte) string
+}
+
+// SQLState returns the SQLSTATE code of the Postgres error wrapped by err, or
+// an empty string if there's none.
+func SQLState(err error) string {
+	var se sqlStateError
+	if errors.As(err, &se) {
+		return se.Field('C')
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+//go:build unit
+// +build unit
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLState(t *testing.T) {
+	err := pgError{'C': "40001", 'M': "could not serialize access"}
+	assert.Equal(t, "40001", SQLState(err))
+	assert.Equal(t, "40001", SQLState(fmt.Errorf("commit: %w", err)))
+
+	assert.Empty(t, SQLState(nil))
+	assert.Empty(t, SQLState(errors.New("foo")))
+}
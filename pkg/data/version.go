@@ -0,0 +1,34 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrStaleRecord is returned when updating a versioned model whose row was
+// updated, or deleted, by someone else since the model was read.
+var ErrStaleRecord = errors.New("stale record")
+
+// versionColumn is the column versioned models hold their version in. Models
+// opt in to optimistic locking by having an integer Version field: updates
+// then only match the row if its version is still the one the model was read
+// with, and bump it.
+const versionColumn = "version"
+
+// versionField returns the field the table's models hold their version in, or
+// nil if they aren't versioned.
+func versionField(table *schema.Table) *schema.Field {
+	f, ok := table.FieldMap[versionColumn]
+	if !ok {
+		return nil
+	}
+
+	switch f.IndirectType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unit
+// +build unit
+
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionField(t *testing.T) {
+	tables := testDB().Dialect().Tables()
+
+	versioned := struct {
+		ID      int64
+		Version int
+	}{}
+	f := versionField(tables.Get(reflect.TypeOf(versioned)))
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "version", f.Name)
+	}
+
+	// the version must be an integer
+	unversioned := struct {
+		ID      int64
+		Version string
+	}{}
+	assert.Nil(t, versionField(tables.Get(reflect.TypeOf(unversioned))))
+
+	assert.Nil(t, versionField(tables.Get(reflect.TypeOf(article{}))))
+}
@@ -95,7 +95,7 @@ func Init() {
 func InitForError() {
 	stripeURL := "http://stripeapi"
 	gock.New(stripeURL).
-		Post("/v1/charges").
+		Post("/v1/payment_intents").
 		Reply(402).
 		BodyString(`{
 				"error": {
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"go.uber.org/fx"
+)
+
+// Func is the unit of work executed by a Worker on every tick.
+type Func func(context.Context) error
+
+// Worker runs its Func periodically, waiting for Interval between runs.
+type Worker struct {
+	Name     string
+	Interval time.Duration
+	Run      Func
+}
+
+func New(name string, interval time.Duration, fn Func) Worker {
+	return Worker{
+		Name:     name,
+		Interval: interval,
+		Run:      fn,
+	}
+}
+
+func Invoke(lc fx.Lifecycle, w Worker) {
+	// The context passed in to 'Run' is only canceled if the worker doesn't
+	// manage to stop in time, the 'stop' channel is what signals the loop to
+	// exit once the current run is over.
+	runCtx, cancel := context.WithCancel(context.Background())
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(done)
+				w.loop(runCtx, stop)
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			ctx, timeout := context.WithTimeout(ctx, 10*time.Second)
+			defer timeout()
+			defer cancel()
+
+			close(stop)
+			select {
+			case <-done:
+				log.Infof("%s shutdown gracefully", w.Name)
+			case <-ctx.Done():
+				log.Errorf("error shutting down %s: %v", w.Name, ctx.Err())
+			}
+			return nil
+		},
+	})
+}
+
+func (w Worker) loop(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Run(ctx); err != nil {
+			log.Errorf("%s error: %v", w.Name, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build unit
+// +build unit
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestWorker(t *testing.T) {
+	t.Run("Runs periodically until stopped", func(t *testing.T) {
+		var runs int32
+
+		lc := fxtest.NewLifecycle(t)
+		Invoke(lc, New("test", 10*time.Millisecond, func(context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		}))
+
+		lc.RequireStart()
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, time.Second, 5*time.Millisecond)
+		lc.RequireStop()
+
+		stopped := atomic.LoadInt32(&runs)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, stopped, atomic.LoadInt32(&runs))
+	})
+
+	t.Run("Keeps running on errors", func(t *testing.T) {
+		var runs int32
+
+		lc := fxtest.NewLifecycle(t)
+		Invoke(lc, New("test", 10*time.Millisecond, func(context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return errors.New("it failed")
+		}))
+
+		lc.RequireStart()
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, time.Second, 5*time.Millisecond)
+		lc.RequireStop()
+	})
+
+	t.Run("Waits for the current run on stop", func(t *testing.T) {
+		var finished int32
+		started := make(chan struct{})
+
+		lc := fxtest.NewLifecycle(t)
+		Invoke(lc, New("test", time.Hour, func(ctx context.Context) error {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+			return ctx.Err()
+		}))
+
+		lc.RequireStart()
+		<-started
+		lc.RequireStop()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+	})
+}
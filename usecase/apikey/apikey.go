@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+// Prefix is prepended to every key, so that they're easy to tell apart from
+// other secrets (e.g., by secret scanners).
+const Prefix = "rr_"
+
+// keyBytes is the number of random bytes in a key.
+const keyBytes = 32
+
+// Generate returns a brand new random key.
+func Generate() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of a key, which is how keys are
+// stored and looked up. Keys are random and long enough that there's no need
+// for a slow, salted hash.
+func Hash(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+type manager struct {
+	keys datastore.APIKey
+}
+
+// Manager hands out and revokes users' API keys.
+type Manager interface {
+	Create(ctx context.Context, userID int64) (string, entity.APIKey, error)
+	Revoke(ctx context.Context, id int64) error
+}
+
+func New(keys datastore.APIKey) Manager {
+	return &manager{keys: keys}
+}
+
+// Create generates a new key for the user, returning it along with its stored
+// record. The key itself can't be recovered later on.
+func (m *manager) Create(ctx context.Context, userID int64) (string, entity.APIKey, error) {
+	key, err := Generate()
+	if err != nil {
+		return "", entity.APIKey{}, err
+	}
+
+	ak := entity.APIKey{
+		KeyHash: Hash(key),
+		UserID:  userID,
+	}
+	if err := m.keys.Save(ctx, &ak); err != nil {
+		return "", entity.APIKey{}, err
+	}
+
+	return key, ak, nil
+}
+
+// Revoke stops the key from being used from now on. Revoking an already
+// revoked key is a no-op.
+func (m *manager) Revoke(ctx context.Context, id int64) error {
+	ak, err := m.keys.FindOne(ctx, datastore.APIKeyWithID(id))
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return entity.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if ak.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	ak.RevokedAt = &now
+	return m.keys.Update(ctx, &ak)
+}
@@ -0,0 +1,149 @@
+//go:build unit
+// +build unit
+
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	k1, err := Generate()
+	require.NoError(t, err)
+
+	k2, err := Generate()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(k1, Prefix))
+	// 32 random bytes take 43 base64 chars
+	assert.Len(t, k1, len(Prefix)+43)
+	assert.NotEqual(t, k1, k2)
+}
+
+func TestHash(t *testing.T) {
+	// echo -n "rr_local_development_key" | sha256sum
+	exp := "364605805a1e9fbe7141d466406bc87a1ae5e2759136bbade4a2066ca85518cc"
+	assert.Equal(t, exp, Hash("rr_local_development_key"))
+	assert.NotEqual(t, exp, Hash("rr_local_development_key "))
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Error on Save", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+		retErr := errors.New("err Save")
+
+		keys.On("Save", ctx, mock.AnythingOfType("*entity.APIKey")).
+			Once().
+			Return(retErr)
+
+		key, _, err := m.Create(ctx, userID)
+
+		assert.Equal(t, retErr, err)
+		assert.Empty(t, key)
+	})
+
+	t.Run("Success on Create", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+		keyID := int64(gofakeit.Number(1, 1000))
+
+		keys.On("Save", ctx, mock.AnythingOfType("*entity.APIKey")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*entity.APIKey).ID = keyID
+			})
+
+		key, ak, err := m.Create(ctx, userID)
+
+		require.NoError(t, err)
+		assert.Equal(t, keyID, ak.ID)
+		assert.Equal(t, userID, ak.UserID)
+		// only the hash is stored
+		assert.Equal(t, Hash(key), ak.KeyHash)
+		assert.Nil(t, ak.RevokedAt)
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	keyID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Key not found", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+
+		keys.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.APIKey{}, data.ErrRecordNotFound)
+
+		err := m.Revoke(ctx, keyID)
+
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+	})
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+		retErr := errors.New("err FindOne")
+
+		keys.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.APIKey{}, retErr)
+
+		err := m.Revoke(ctx, keyID)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("No-op on revoked key", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+		revokedAt := time.Now().UTC()
+
+		keys.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.APIKey{ID: keyID, RevokedAt: &revokedAt}, nil)
+
+		err := m.Revoke(ctx, keyID)
+
+		assert.NoError(t, err)
+		keys.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success on Revoke", func(t *testing.T) {
+		keys := &mocks.APIKey{}
+		m := New(keys)
+
+		keys.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.APIKey{ID: keyID}, nil)
+
+		keys.On("Update", ctx, mock.MatchedBy(func(ak *entity.APIKey) bool {
+			return ak.ID == keyID && ak.RevokedAt != nil
+		})).
+			Once().
+			Return(nil)
+
+		err := m.Revoke(ctx, keyID)
+
+		assert.NoError(t, err)
+		keys.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+// auditExportBatch is how many records are fetched at a time while exporting.
+const auditExportBatch = 500
+
+// auditCriteria turns the filter into the criteria selecting its records.
+func auditCriteria(f audit.Filter) []data.SelectCriteria {
+	var sc []data.SelectCriteria
+	if f.UserID != 0 {
+		sc = append(sc, datastore.AuditRecordWithUserID(f.UserID))
+	}
+	if f.Action != "" {
+		sc = append(sc, datastore.AuditRecordWithAction(f.Action))
+	}
+	if f.ResourceType != "" {
+		sc = append(sc, datastore.AuditRecordWithResourceType(f.ResourceType))
+	}
+	if f.ResourceID != 0 {
+		sc = append(sc, datastore.AuditRecordWithResourceID(f.ResourceID))
+	}
+	if f.Network != "" {
+		sc = append(sc, datastore.AuditRecordWithinNetwork(f.Network))
+	}
+	if !f.CreatedFrom.IsZero() {
+		sc = append(sc, datastore.AuditRecordCreatedFrom(f.CreatedFrom))
+	}
+	if !f.CreatedBefore.IsZero() {
+		sc = append(sc, datastore.AuditRecordCreatedBefore(f.CreatedBefore))
+	}
+	return sc
+}
+
+type auditLog struct {
+	records datastore.AuditRecord
+}
+
+type Audit interface {
+	List(ctx context.Context, f audit.Filter, cursor string, limit int) (data.Page[entity.AuditRecord], error)
+	Export(ctx context.Context, f audit.Filter, cursor string, fn func(entity.AuditRecord) error) error
+}
+
+func NewAudit(records datastore.AuditRecord) Audit {
+	return &auditLog{records: records}
+}
+
+// List returns a page of up to limit audit records matching the filter, newest
+// first, starting right after the cursor (or from the newest one if it's
+// empty).
+func (a *auditLog) List(
+	ctx context.Context,
+	f audit.Filter,
+	cursor string,
+	limit int,
+) (data.Page[entity.AuditRecord], error) {
+	pr := data.PageRequest{Cursor: cursor, Limit: limit, OrderBy: []data.Order{data.Desc("id")}}
+	return a.records.FindPage(ctx, pr, auditCriteria(f)...)
+}
+
+// Export calls fn with every audit record matching the filter, newest first,
+// starting right after the cursor just like List does. Records are fetched in
+// batches, so that they don't need to fit in memory all at once. It stops at
+// the first error returned by fn.
+func (a *auditLog) Export(
+	ctx context.Context,
+	f audit.Filter,
+	cursor string,
+	fn func(entity.AuditRecord) error,
+) error {
+	for {
+		page, err := a.List(ctx, f, cursor, auditExportBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, ar := range page.Rows {
+			if err := fn(ar); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,152 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditFilterCriteria(t *testing.T) {
+	assert.Empty(t, auditCriteria(audit.Filter{}))
+
+	f := audit.Filter{
+		UserID:        1,
+		Action:        audit.ActionCreateRide,
+		ResourceType:  audit.ResourceTypeRide,
+		ResourceID:    2,
+		Network:       "10.0.0.0/8",
+		CreatedFrom:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedBefore: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	assert.Len(t, auditCriteria(f), 7)
+
+	assert.Len(t, auditCriteria(audit.Filter{UserID: 1, Network: "10.0.0.1"}), 2)
+}
+
+func TestAuditList(t *testing.T) {
+	ctx := context.Background()
+	filter := audit.Filter{UserID: 1}
+
+	t.Run("Error on FindPage", func(t *testing.T) {
+		store := &mocks.AuditRecord{}
+		uc := NewAudit(store)
+		expErr := errors.New("error FindPage")
+
+		store.On("FindPage", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(data.Page[entity.AuditRecord]{}, expErr)
+
+		_, err := uc.List(ctx, filter, "", 2)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Success on List", func(t *testing.T) {
+		store := &mocks.AuditRecord{}
+		uc := NewAudit(store)
+		page := data.Page[entity.AuditRecord]{Rows: []entity.AuditRecord{{ID: 3}, {ID: 2}}, NextCursor: "next"}
+
+		// records are paged through newest first, with the user criteria
+		// taken from the filter
+		pr := data.PageRequest{Cursor: "cursor", Limit: 2, OrderBy: []data.Order{data.Desc("id")}}
+		store.On("FindPage", ctx, pr, mock.Anything).
+			Once().
+			Return(page, nil)
+
+		res, err := uc.List(ctx, filter, "cursor", 2)
+		if assert.NoError(t, err) {
+			assert.Equal(t, page, res)
+		}
+		store.AssertExpectations(t)
+	})
+}
+
+func TestAuditExport(t *testing.T) {
+	ctx := context.Background()
+
+	batch := func(from int64, n int) []entity.AuditRecord {
+		res := make([]entity.AuditRecord, n)
+		for i := range res {
+			res[i] = entity.AuditRecord{ID: from - int64(i)}
+		}
+		return res
+	}
+
+	// request returns the request for the page after the cursor.
+	request := func(cursor string) data.PageRequest {
+		return data.PageRequest{Cursor: cursor, Limit: auditExportBatch, OrderBy: []data.Order{data.Desc("id")}}
+	}
+
+	t.Run("Many batches", func(t *testing.T) {
+		store := &mocks.AuditRecord{}
+		uc := NewAudit(store)
+
+		first := batch(2000, auditExportBatch)
+		second := batch(2000-auditExportBatch, 10)
+
+		// each batch starts right after the one before
+		store.On("FindPage", ctx, request("")).
+			Once().
+			Return(data.Page[entity.AuditRecord]{Rows: first, NextCursor: "next"}, nil)
+		store.On("FindPage", ctx, request("next")).
+			Once().
+			Return(data.Page[entity.AuditRecord]{Rows: second}, nil)
+
+		var ids []int64
+		err := uc.Export(ctx, audit.Filter{}, "", func(ar entity.AuditRecord) error {
+			ids = append(ids, ar.ID)
+			return nil
+		})
+
+		if assert.NoError(t, err) {
+			assert.Len(t, ids, auditExportBatch+10)
+			assert.Equal(t, int64(2000), ids[0])
+			assert.Equal(t, second[9].ID, ids[len(ids)-1])
+		}
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Error on FindPage", func(t *testing.T) {
+		store := &mocks.AuditRecord{}
+		uc := NewAudit(store)
+		expErr := errors.New("error FindPage")
+
+		store.On("FindPage", ctx, request("")).
+			Once().
+			Return(data.Page[entity.AuditRecord]{}, expErr)
+
+		err := uc.Export(ctx, audit.Filter{}, "", func(entity.AuditRecord) error {
+			t.Error("no records expected")
+			return nil
+		})
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Error on callback", func(t *testing.T) {
+		store := &mocks.AuditRecord{}
+		uc := NewAudit(store)
+		expErr := errors.New("error writing")
+
+		store.On("FindPage", ctx, request("")).
+			Once().
+			Return(data.Page[entity.AuditRecord]{Rows: batch(10, 5), NextCursor: "next"}, nil)
+
+		var calls int
+		err := uc.Export(ctx, audit.Filter{}, "", func(entity.AuditRecord) error {
+			calls++
+			return expErr
+		})
+		assert.Equal(t, expErr, err)
+		assert.Equal(t, 1, calls)
+	})
+}
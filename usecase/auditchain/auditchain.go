@@ -0,0 +1,145 @@
+// Package auditchain links audit records into a hash chain, once they're
+// saved, and verifies it.
+package auditchain
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+// batchSize is how many records are fetched at a time while walking the chain.
+const batchSize = 1000
+
+// Reasons why a link of the chain is broken.
+const (
+	ReasonMissingHash  = "record has no hash, though it's chained"
+	ReasonPrevMismatch = "prev_hash doesn't match the hash of the record before it"
+	ReasonHashMismatch = "hash doesn't match the record's content"
+	ReasonHeadMismatch = "chain head doesn't match the newest record, which may have been deleted"
+)
+
+// BrokenLink tells which record the chain breaks at and why. RecordID is zero
+// if the chain head points at records when there's none.
+type BrokenLink struct {
+	RecordID int64
+	Reason   string
+}
+
+// Report sums up the verification of the chain.
+type Report struct {
+	// Checked is how many records were found properly linked.
+	Checked int
+	// Unchained is how many records weren't linked to the chain yet, which
+	// can't be verified.
+	Unchained int
+	// Broken is the first broken link found, it's nil if the chain is intact.
+	Broken *BrokenLink
+}
+
+type verifier struct {
+	cfg config.Config
+	uow uow.UnitOfWork
+}
+
+// Verifier walks the chain of audit records looking for broken links.
+type Verifier interface {
+	Verify(context.Context) (Report, error)
+}
+
+func New(cfg config.Config, uow uow.UnitOfWork) Verifier {
+	return &verifier{
+		cfg: cfg,
+		uow: uow,
+	}
+}
+
+// Verify walks the chain in order, checking every record's hash against its
+// content and the hash of the record before it, stopping at the first broken
+// link. The newest record must be the one the chain head points at, otherwise
+// records were deleted from the end of the chain.
+//
+// The whole walk sees a single snapshot of the records, so that records
+// chained in the meantime don't get in the way.
+func (v *verifier) Verify(ctx context.Context) (Report, error) {
+	var rep Report
+
+	err := v.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		rep = Report{}
+
+		var (
+			err    error
+			prev   string
+			lastID int64
+		)
+
+		rep.Unchained, err = uows.AuditRecords().Count(ctx, datastore.AuditRecordUnchained())
+		if err != nil {
+			return err
+		}
+
+		pr := data.PageRequest{Limit: batchSize, OrderBy: []data.Order{data.Asc("chain_seq")}}
+		for {
+			var page data.Page[entity.AuditRecord]
+			page, err = uows.AuditRecords().FindPage(ctx, pr, datastore.AuditRecordChained())
+			if err != nil {
+				return err
+			}
+
+			for _, ar := range page.Rows {
+				if reason := check(ar, prev, []byte(v.cfg.AuditChainKey)); reason != "" {
+					rep.Broken = &BrokenLink{RecordID: ar.ID, Reason: reason}
+					return nil
+				}
+
+				prev = ar.Hash
+				lastID = ar.ID
+				rep.Checked++
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+			pr.Cursor = page.NextCursor
+		}
+
+		head, err := uows.AuditRecords().ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		if head.Hash != prev {
+			rep.Broken = &BrokenLink{RecordID: lastID, Reason: ReasonHeadMismatch}
+		}
+		return nil
+	}, uow.ReadOnly(), uow.WithIsolation(sql.LevelRepeatableRead))
+	if err != nil {
+		return Report{}, err
+	}
+
+	return rep, nil
+}
+
+// check tells why the record isn't properly linked to the one before it, whose
+// hash is given, if that's the case. Records are hashed with the given key.
+func check(ar entity.AuditRecord, prev string, key []byte) string {
+	if ar.Hash == "" {
+		return ReasonMissingHash
+	}
+
+	if ar.PrevHash != prev {
+		return ReasonPrevMismatch
+	}
+
+	// content that can't even be hashed must have been tampered with as well
+	h, err := ar.ChainHash(key)
+	if err != nil || h != ar.Hash {
+		return ReasonHashMismatch
+	}
+
+	return ""
+}
@@ -0,0 +1,350 @@
+//go:build unit
+// +build unit
+
+package auditchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "test_audit_chain_key_0123456789abcdef"
+
+var mockCfg = config.Config{AuditChainKey: testKey}
+
+type testMocks struct {
+	uow   *mocks.UnitOfWork
+	uows  *mocks.UnitOfWorkStore
+	audit *mocks.AuditRecord
+}
+
+func getMocks() testMocks {
+	m := testMocks{
+		uow:   &mocks.UnitOfWork{},
+		uows:  &mocks.UnitOfWorkStore{},
+		audit: &mocks.AuditRecord{},
+	}
+
+	m.uows.On("AuditRecords").Return(m.audit)
+
+	// the chain is walked inside a read-only, repeatable read transaction,
+	// while records are chained inside plain ones
+	for _, args := range [][]interface{}{
+		{mock.Anything, mock.Anything},
+		{mock.Anything, mock.Anything, mock.Anything, mock.Anything},
+	} {
+		var mockUOW *mock.Call
+		mockUOW = m.uow.On("Do", args...).
+			Run(func(args mock.Arguments) {
+				fn, ok := args.Get(1).(uow.UnitOfWorkBlock)
+				if !ok {
+					panic("argument mismatch")
+				}
+				mockUOW.Return(fn(m.uows))
+			})
+	}
+
+	return m
+}
+
+// chain returns n properly linked records, oldest first.
+func chain(t *testing.T, n int) []entity.AuditRecord {
+	records := make([]entity.AuditRecord, n)
+
+	var prev string
+	for i := range records {
+		ar := entity.AuditRecord{
+			ID:           int64(i + 1),
+			Action:       audit.ActionCreateRide,
+			CreatedAt:    time.Date(2022, 1, 1, 0, 0, i, 0, time.UTC),
+			Data:         json.RawMessage(`{"b": 1, "a": "x"}`),
+			OriginIP:     gofakeit.IPv4Address(),
+			ResourceID:   int64(gofakeit.Number(1, 1000)),
+			ResourceType: audit.ResourceTypeRide,
+			UserID:       int64(gofakeit.Number(1, 1000)),
+			PrevHash:     prev,
+			ChainSeq:     int64(i + 1),
+		}
+
+		var err error
+		ar.Hash, err = ar.ChainHash([]byte(testKey))
+		require.NoError(t, err)
+
+		records[i] = ar
+		prev = ar.Hash
+	}
+	return records
+}
+
+func TestChainHash(t *testing.T) {
+	ar := chain(t, 1)[0]
+
+	// the way records are read back from the database doesn't change the hash
+	stored := ar
+	stored.Data = json.RawMessage(`{"a":"x","b":1}`)
+	stored.OriginIP = ar.OriginIP + "/32"
+	stored.CreatedAt = ar.CreatedAt.In(time.FixedZone("UTC-3", -3*60*60))
+	stored.ID = 1000
+
+	h, err := stored.ChainHash([]byte(testKey))
+	if assert.NoError(t, err) {
+		assert.Equal(t, ar.Hash, h)
+	}
+
+	// while any change to its content does
+	for desc, change := range map[string]func(*entity.AuditRecord){
+		"prev hash":  func(r *entity.AuditRecord) { r.PrevHash = "00" },
+		"action":     func(r *entity.AuditRecord) { r.Action = audit.ActionCancelRide },
+		"created at": func(r *entity.AuditRecord) { r.CreatedAt = r.CreatedAt.Add(time.Microsecond) },
+		"data":       func(r *entity.AuditRecord) { r.Data = json.RawMessage(`{"a": "y", "b": 1}`) },
+		"origin ip":  func(r *entity.AuditRecord) { r.OriginIP = "10.0.0.0/8" },
+		"resource":   func(r *entity.AuditRecord) { r.ResourceID++ },
+		"user":       func(r *entity.AuditRecord) { r.UserID++ },
+	} {
+		changed := ar
+		change(&changed)
+
+		h, err := changed.ChainHash([]byte(testKey))
+		if assert.NoError(t, err, desc) {
+			assert.NotEqual(t, ar.Hash, h, desc)
+		}
+	}
+
+	// and so does hashing it with any other key
+	h, err = ar.ChainHash([]byte("other_audit_chain_key_0123456789"))
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, ar.Hash, h)
+	}
+
+	invalid := ar
+	invalid.Data = json.RawMessage(`{`)
+	_, err = invalid.ChainHash([]byte(testKey))
+	assert.Error(t, err)
+
+	invalid = ar
+	invalid.OriginIP = "foo"
+	_, err = invalid.ChainHash([]byte(testKey))
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	// page returns the records as a page of the chain, which is the last one
+	// unless a cursor is given.
+	page := func(records []entity.AuditRecord, cursor string) data.Page[entity.AuditRecord] {
+		return data.Page[entity.AuditRecord]{Rows: records, NextCursor: cursor}
+	}
+
+	t.Run("Error on Count", func(t *testing.T) {
+		expErr := errors.New("error Count")
+
+		m := getMocks()
+		m.audit.On("Count", ctx, mock.Anything).
+			Once().
+			Return(0, expErr)
+
+		_, err := New(mockCfg, m.uow).Verify(ctx)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Error on FindPage", func(t *testing.T) {
+		expErr := errors.New("error FindPage")
+
+		m := getMocks()
+		m.audit.On("Count", ctx, mock.Anything).
+			Once().
+			Return(0, nil)
+		m.audit.On("FindPage", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(data.Page[entity.AuditRecord]{}, expErr)
+
+		_, err := New(mockCfg, m.uow).Verify(ctx)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Error on ChainHead", func(t *testing.T) {
+		expErr := errors.New("error ChainHead")
+
+		m := getMocks()
+		m.audit.On("Count", ctx, mock.Anything).
+			Once().
+			Return(0, nil)
+		m.audit.On("FindPage", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(page(chain(t, 2), ""), nil)
+		m.audit.On("ChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, expErr)
+
+		_, err := New(mockCfg, m.uow).Verify(ctx)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Empty chain", func(t *testing.T) {
+		m := getMocks()
+		m.audit.On("Count", ctx, mock.Anything).
+			Once().
+			Return(0, nil)
+		m.audit.On("FindPage", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(page(nil, ""), nil)
+		m.audit.On("ChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, nil)
+
+		rep, err := New(mockCfg, m.uow).Verify(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Report{}, rep)
+		}
+	})
+
+	t.Run("Intact chain", func(t *testing.T) {
+		records := chain(t, batchSize+2)
+		last := records[len(records)-1]
+
+		m := getMocks()
+		// records saved but not chained yet aren't walked, only counted
+		m.audit.On("Count", ctx, mock.Anything).
+			Once().
+			Return(2, nil)
+		m.audit.On("FindPage", ctx, data.PageRequest{
+			Limit:   batchSize,
+			OrderBy: []data.Order{data.Asc("chain_seq")},
+		}, mock.Anything).
+			Once().
+			Return(page(records[:batchSize], "next"), nil)
+		m.audit.On("FindPage", ctx, data.PageRequest{
+			Cursor:  "next",
+			Limit:   batchSize,
+			OrderBy: []data.Order{data.Asc("chain_seq")},
+		}, mock.Anything).
+			Once().
+			Return(page(records[batchSize:], ""), nil)
+		m.audit.On("ChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{Hash: last.Hash, Seq: last.ChainSeq}, nil)
+
+		rep, err := New(mockCfg, m.uow).Verify(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Report{Checked: len(records), Unchained: 2}, rep)
+		}
+		m.audit.AssertExpectations(t)
+	})
+
+	t.Run("Broken chain", func(t *testing.T) {
+		tests := []struct {
+			desc   string
+			tamper func([]entity.AuditRecord) []entity.AuditRecord
+			id     int64
+			reason string
+		}{
+			{
+				desc: "edited record",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					r[1].UserID++
+					return r
+				},
+				id:     2,
+				reason: ReasonHashMismatch,
+			},
+			{
+				desc: "edited and rehashed record",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					r[1].UserID++
+					r[1].Hash, _ = r[1].ChainHash([]byte(testKey))
+					return r
+				},
+				id:     3,
+				reason: ReasonPrevMismatch,
+			},
+			{
+				desc: "deleted record",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					return append(r[:1], r[2:]...)
+				},
+				id:     3,
+				reason: ReasonPrevMismatch,
+			},
+			{
+				desc: "record rehashed without the key",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					r[3].UserID++
+					r[3].Hash, _ = r[3].ChainHash([]byte("other_audit_chain_key_0123456789"))
+					return r
+				},
+				id:     4,
+				reason: ReasonHashMismatch,
+			},
+			{
+				desc: "unhashed record",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					r[2].Hash = ""
+					return r
+				},
+				id:     3,
+				reason: ReasonMissingHash,
+			},
+			{
+				desc: "invalid record content",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					r[0].OriginIP = "foo"
+					return r
+				},
+				id:     1,
+				reason: ReasonHashMismatch,
+			},
+			{
+				desc: "deleted newest record",
+				tamper: func(r []entity.AuditRecord) []entity.AuditRecord {
+					return r[:3]
+				},
+				id:     3,
+				reason: ReasonHeadMismatch,
+			},
+			{
+				desc: "deleted every record",
+				tamper: func([]entity.AuditRecord) []entity.AuditRecord {
+					return nil
+				},
+				id:     0,
+				reason: ReasonHeadMismatch,
+			},
+		}
+
+		for _, tc := range tests {
+			records := chain(t, 4)
+			head := records[3].Hash
+
+			m := getMocks()
+			m.audit.On("Count", ctx, mock.Anything).
+				Once().
+				Return(0, nil)
+			m.audit.On("FindPage", ctx, mock.Anything, mock.Anything).
+				Once().
+				Return(page(tc.tamper(records), ""), nil)
+			m.audit.On("ChainHead", ctx).
+				Return(entity.AuditChainHead{Hash: head, Seq: 4}, nil)
+
+			rep, err := New(mockCfg, m.uow).Verify(ctx)
+			if assert.NoError(t, err, tc.desc) && assert.NotNil(t, rep.Broken, tc.desc) {
+				assert.Equal(t, tc.id, rep.Broken.RecordID, tc.desc)
+				assert.Equal(t, tc.reason, rep.Broken.Reason, tc.desc)
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package auditchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+type chainer struct {
+	cfg config.Config
+	uow uow.UnitOfWork
+}
+
+// Chainer links the audit records saved in the meantime to the chain.
+type Chainer interface {
+	Chain(context.Context) (int, error)
+}
+
+func NewChainer(cfg config.Config, uow uow.UnitOfWork) Chainer {
+	return &chainer{
+		cfg: cfg,
+		uow: uow,
+	}
+}
+
+// Chain links the records not chained yet to the chain, oldest first, a batch
+// at a time, returning how many of them were linked. Each batch is linked in a
+// short transaction of its own, holding the chain head locked, so that
+// concurrent chainers don't link records to the same head.
+func (c *chainer) Chain(ctx context.Context) (int, error) {
+	var total int
+	for {
+		n, err := c.chainBatch(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		// a partial batch means there's nothing left to be chained
+		if n < c.cfg.WorkerBatch {
+			return total, nil
+		}
+	}
+}
+
+func (c *chainer) chainBatch(ctx context.Context) (int, error) {
+	var n int
+
+	err := c.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		head, err := uows.AuditRecords().LockChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		// read only once the head is locked, so that records linked by another
+		// chainer in the meantime aren't picked again
+		records, err := uows.AuditRecords().FindAll(
+			ctx,
+			datastore.AuditRecordUnchained(),
+			datastore.AuditRecordOldestFirst(c.cfg.WorkerBatch),
+		)
+		if err != nil {
+			return err
+		}
+
+		for i := range records {
+			ar := &records[i]
+			ar.PrevHash = head.Hash
+			ar.ChainSeq = head.Seq + 1
+
+			ar.Hash, err = ar.ChainHash([]byte(c.cfg.AuditChainKey))
+			if err != nil {
+				return fmt.Errorf("audit record %v: %w", ar.ID, err)
+			}
+
+			head = entity.AuditChainHead{Hash: ar.Hash, Seq: ar.ChainSeq}
+		}
+
+		n = len(records)
+		return uows.AuditRecords().Chain(ctx, records)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
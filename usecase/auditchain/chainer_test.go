@@ -0,0 +1,146 @@
+//go:build unit
+// +build unit
+
+package auditchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{AuditChainKey: testKey, WorkerBatch: 2}
+
+	// unchained returns the records as they're saved, before being chained.
+	unchained := func(records []entity.AuditRecord) []entity.AuditRecord {
+		res := make([]entity.AuditRecord, len(records))
+		for i, ar := range records {
+			ar.PrevHash, ar.Hash, ar.ChainSeq = "", "", 0
+			res[i] = ar
+		}
+		return res
+	}
+
+	t.Run("Error on LockChainHead", func(t *testing.T) {
+		expErr := errors.New("error LockChainHead")
+
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, expErr)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.Equal(t, expErr, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("Error on FindAll", func(t *testing.T) {
+		expErr := errors.New("error FindAll")
+
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, expErr)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.Equal(t, expErr, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("Error on ChainHash", func(t *testing.T) {
+		records := unchained(chain(t, 1))
+		records[0].OriginIP = "foo"
+
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(records, nil)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+		m.audit.AssertNotCalled(t, "Chain", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on Chain", func(t *testing.T) {
+		expErr := errors.New("error Chain")
+
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(unchained(chain(t, 1)), nil)
+		m.audit.On("Chain", ctx, mock.Anything).
+			Once().
+			Return(expErr)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.Equal(t, expErr, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("Nothing to chain", func(t *testing.T) {
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, nil)
+		m.audit.On("Chain", ctx, []entity.AuditRecord(nil)).
+			Once().
+			Return(nil)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("Success on Chain in batches", func(t *testing.T) {
+		records := chain(t, 5)
+
+		// records up to the second one were chained already
+		m := getMocks()
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{Hash: records[1].Hash, Seq: 2}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(unchained(records[2:4]), nil)
+		m.audit.On("Chain", ctx, records[2:4]).
+			Once().
+			Return(nil)
+
+		// a partial batch follows
+		m.audit.On("LockChainHead", ctx).
+			Once().
+			Return(entity.AuditChainHead{Hash: records[3].Hash, Seq: 4}, nil)
+		m.audit.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(unchained(records[4:]), nil)
+		m.audit.On("Chain", ctx, records[4:]).
+			Once().
+			Return(nil)
+
+		n, err := NewChainer(mockCfg, m.uow).Chain(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		m.audit.AssertExpectations(t)
+	})
+}
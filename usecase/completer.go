@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+type completer struct {
+	cfg   config.Config
+	iks   datastore.IdempotencyKey
+	users datastore.User
+	ride  Ride
+	user  User
+}
+
+type Completer interface {
+	Complete(context.Context) (int, error)
+}
+
+func NewCompleter(
+	cfg config.Config,
+	iks datastore.IdempotencyKey,
+	users datastore.User,
+	rd Ride,
+	usr User,
+) Completer {
+	return &completer{
+		cfg:   cfg,
+		iks:   iks,
+		users: users,
+		ride:  rd,
+		user:  usr,
+	}
+}
+
+// Complete looks for a batch of requests abandoned halfway by their clients
+// and pushes each one of them through to the end, returning how many of them
+// got finished.
+//
+// Requests awaiting the customer's action are checked on as well, every
+// IdemKeyActionInterval: nothing but the client's retry would move them on
+// otherwise, which may never come. They're pushed through once the customer
+// has taken action, or once they've waited too long (see confirmCharge).
+func (c *completer) Complete(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	timeout := time.Duration(c.cfg.IdemKeyTimeout) * time.Second
+	interval := time.Duration(c.cfg.IdemKeyActionInterval) * time.Second
+
+	var keys []entity.IdempotencyKey
+	for _, sc := range []data.SelectCriteria{
+		datastore.IdemKeyAbandonedBefore(now.Add(-1 * timeout)),
+		datastore.IdemKeyAwaitingActionBefore(now.Add(-1 * interval)),
+	} {
+		res, err := c.iks.FindAll(ctx, sc, datastore.IdemKeyWithLimit(c.cfg.WorkerBatch))
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, res...)
+	}
+
+	var n int
+	for i := range keys {
+		err := c.complete(ctx, &keys[i])
+		if err != nil {
+			// Someone else (most likely the client retrying its request) got
+			// to the key first, so there's nothing else to do about it.
+			if !errors.Is(err, entity.ErrIdemKeyRequestInProgress) {
+				log.Errorf("error completing idem key %v: %v", keys[i].ID, err)
+			}
+			continue
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func (c *completer) complete(ctx context.Context, ik *entity.IdempotencyKey) error {
+	// signups are sent by no user at all
+	if path, _, _ := strings.Cut(ik.RequestPath, "?"); path == SignupPath {
+		return c.user.Complete(ctx, ik)
+	}
+
+	user, err := c.users.FindOne(ctx, datastore.UserWithID(ik.UserID))
+	if err != nil {
+		return err
+	}
+
+	ik.User = &user
+	return c.ride.Complete(ctx, ik)
+}
@@ -0,0 +1,236 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	ucmocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/usecase"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCompleter(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5, IdemKeyActionInterval: 300, WorkerBatch: 10}
+
+	user := entity.User{
+		ID:               int64(gofakeit.Number(1, 1000)),
+		Email:            gofakeit.Email(),
+		StripeCustomerID: gofakeit.UUID(),
+	}
+
+	keys := []entity.IdempotencyKey{
+		{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+			UserID:         user.ID,
+		},
+		{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
+			UserID:         user.ID,
+		},
+	}
+
+	t.Run("Error on FindAll", func(t *testing.T) {
+		retErr := errors.New("err FindAll")
+
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		uc := NewCompleter(mockCfg, iks, users, rd, &ucmocks.User{})
+
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, retErr)
+
+		n, err := uc.Complete(ctx)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, n)
+		iks.AssertNumberOfCalls(t, "FindAll", 1)
+	})
+
+	t.Run("Error on FindOne user", func(t *testing.T) {
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		uc := NewCompleter(mockCfg, iks, users, rd, &ucmocks.User{})
+
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(keys, nil)
+		// none awaiting the customer's action
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, nil)
+
+		users.On("FindOne", ctx, mock.Anything).
+			Times(len(keys)).
+			Return(entity.User{}, errors.New("err FindOne"))
+
+		n, err := uc.Complete(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		users.AssertNumberOfCalls(t, "FindOne", len(keys))
+		rd.AssertNumberOfCalls(t, "Complete", 0)
+	})
+
+	t.Run("Errors on ride Complete", func(t *testing.T) {
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		uc := NewCompleter(mockCfg, iks, users, rd, &ucmocks.User{})
+
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(keys, nil)
+		// none awaiting the customer's action
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, nil)
+
+		users.On("FindOne", ctx, mock.Anything).
+			Times(len(keys)).
+			Return(user, nil)
+
+		rd.On("Complete", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(entity.ErrIdemKeyRequestInProgress)
+
+		rd.On("Complete", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(errors.New("err Complete"))
+
+		n, err := uc.Complete(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		rd.AssertNumberOfCalls(t, "Complete", len(keys))
+	})
+
+	t.Run("Success on Complete", func(t *testing.T) {
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		uc := NewCompleter(mockCfg, iks, users, rd, &ucmocks.User{})
+
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(keys, nil)
+		// none awaiting the customer's action
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, nil)
+
+		users.On("FindOne", ctx, mock.Anything).
+			Times(len(keys)).
+			Return(user, nil)
+
+		rd.On("Complete", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(len(keys)).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				arg, ok := args.Get(1).(*entity.IdempotencyKey)
+				assert.True(t, ok)
+				if assert.NotNil(t, arg.User) {
+					assert.Equal(t, user, *arg.User)
+				}
+			})
+
+		n, err := uc.Complete(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(keys), n)
+		rd.AssertNumberOfCalls(t, "Complete", len(keys))
+	})
+	t.Run("Success on Complete signup", func(t *testing.T) {
+		signup := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			RecoveryPoint:  idempotency.RecoveryPointUserCreated,
+			RequestPath:    SignupPath,
+		}
+
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		usr := &ucmocks.User{}
+		uc := NewCompleter(mockCfg, iks, users, rd, usr)
+
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return([]entity.IdempotencyKey{signup}, nil)
+		// none awaiting the customer's action
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, nil)
+
+		usr.On("Complete", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(nil)
+
+		n, err := uc.Complete(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		// signups are sent by no user, so there's none to look up
+		users.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+		rd.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything)
+		usr.AssertExpectations(t)
+	})
+
+	t.Run("Keys awaiting action checked on", func(t *testing.T) {
+		awaiting := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			RecoveryPoint:  idempotency.RecoveryPointAwaitingAction,
+			UserID:         user.ID,
+		}
+
+		iks := &mocks.IdempotencyKey{}
+		users := &mocks.User{}
+		rd := &ucmocks.Ride{}
+		uc := NewCompleter(mockCfg, iks, users, rd, &ucmocks.User{})
+
+		// abandoned keys come first, then the ones awaiting action
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(keys[:1], nil)
+		iks.On("FindAll", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return([]entity.IdempotencyKey{awaiting}, nil)
+
+		users.On("FindOne", ctx, mock.Anything).
+			Times(2).
+			Return(user, nil)
+
+		var completed []int64
+		rd.On("Complete", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(2).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				completed = append(completed, args.Get(1).(*entity.IdempotencyKey).ID)
+			})
+
+		n, err := uc.Complete(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []int64{keys[0].ID, awaiting.ID}, completed)
+		iks.AssertNumberOfCalls(t, "FindAll", 2)
+	})
+}
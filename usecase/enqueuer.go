@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/labstack/gommon/log"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+)
+
+const (
+	// jobLease is for how long a claimed batch is left to be handed over, it
+	// must outlast the slowest handler (e.g. sending emails within SMTPTimeout).
+	jobLease = 5 * time.Minute
+	// maxJobRetryDelay caps the backoff between the attempts of failing jobs.
+	maxJobRetryDelay = time.Hour
+)
+
+type enqueuer struct {
+	cfg  config.Config
+	uow  uow.UnitOfWork
+	jobs *jobs.Registry
+}
+
+type Enqueuer interface {
+	Enqueue(context.Context) (int, error)
+}
+
+func NewEnqueuer(cfg config.Config, uow uow.UnitOfWork, jobs *jobs.Registry) Enqueuer {
+	return &enqueuer{
+		cfg:  cfg,
+		uow:  uow,
+		jobs: jobs,
+	}
+}
+
+// Enqueue moves a batch of staged jobs to the job queue, returning how many of
+// them were handled. Jobs without a registered handler are left untouched.
+//
+// Jobs failing to be handed over are retried with an exponential backoff, up
+// to WorkerMaxAttempts times: then they're dead and left aside. A failing job
+// never holds back the rest of its batch.
+func (e *enqueuer) Enqueue(ctx context.Context) (int, error) {
+	names := e.jobs.Names()
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	sjs, err := e.claim(ctx, names)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, sj := range sjs {
+		// the rest of the batch is taken again once its lease is over
+		if !time.Now().UTC().Before(sj.RunAt) {
+			break
+		}
+
+		ok, err := e.run(ctx, sj)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// claim locks a batch of the jobs due and pushes their RunAt forward by
+// jobLease in the same transaction, so that nobody else takes them in the
+// meantime.
+//
+// Jobs aren't deleted in the transaction that locked them, as the enqueuer was
+// first meant to work: they're handed over with no transaction open, since it
+// may take a while (e.g. sending emails), and a transaction held open for the
+// whole batch would roll the handled jobs back along with a failing one. It's
+// the lease that keeps them from being handed over twice instead: each job is
+// only given until the lease is over, after which the rest of the batch is
+// left to be taken again. Should the enqueuer die before it's done with the
+// batch, the same goes for all of it.
+func (e *enqueuer) claim(ctx context.Context, names []stagedjob.JobName) ([]entity.StagedJob, error) {
+	var sjs []entity.StagedJob
+
+	err := e.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		now := time.Now().UTC()
+
+		var err error
+		sjs, err = uows.StagedJobs().FindAll(
+			ctx,
+			datastore.StagedJobWithNames(names...),
+			datastore.StagedJobDue(now),
+			datastore.StagedJobLockBatch(e.cfg.WorkerBatch),
+		)
+		if err != nil {
+			return err
+		}
+
+		for i := range sjs {
+			sjs[i].RunAt = now.Add(jobLease)
+		}
+		_, err = uows.StagedJobs().UpdateMany(ctx, sjs, "run_at")
+		return err
+	})
+	return sjs, err
+}
+
+// run hands the claimed job over within its lease, then deletes it or records
+// its failure in a transaction of its own, reporting whether it was handed
+// over. A job that was handed over but couldn't be deleted is handed over
+// again once its lease is over, which makes delivery to the job queue
+// at-least-once.
+func (e *enqueuer) run(ctx context.Context, sj entity.StagedJob) (bool, error) {
+	jctx, cancel := context.WithDeadline(ctx, sj.RunAt)
+	defer cancel()
+
+	runErr := e.jobs.Run(jctx, sj)
+	if runErr != nil {
+		log.Errorf("error handling staged job %v: %v", sj.ID, runErr)
+		sj = e.fail(sj, runErr, time.Now().UTC())
+	}
+
+	err := e.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		if runErr != nil {
+			return uows.StagedJobs().Update(ctx, &sj)
+		}
+		return uows.StagedJobs().Delete(ctx, &sj)
+	})
+	return runErr == nil, err
+}
+
+// fail records the job's failure, which is retried later on unless it's out of
+// attempts or its args can never be handled, in which case it's dead.
+func (e *enqueuer) fail(sj entity.StagedJob, err error, now time.Time) entity.StagedJob {
+	sj.Attempts++
+	sj.LastError = err.Error()
+
+	if sj.Attempts >= e.cfg.WorkerMaxAttempts || errors.Is(err, jobs.ErrInvalidJobArgs) {
+		sj.FailedAt = &now
+		return sj
+	}
+
+	sj.RunAt = now.Add(e.retryDelay(sj.Attempts))
+	return sj
+}
+
+// retryDelay doubles the worker's interval for each failed attempt, up to
+// maxJobRetryDelay.
+func (e *enqueuer) retryDelay(attempts int) time.Duration {
+	d := time.Duration(e.cfg.WorkerInterval) * time.Second << (attempts - 1)
+	if d <= 0 || d > maxJobRetryDelay {
+		d = maxJobRetryDelay
+	}
+	return d
+}
@@ -0,0 +1,346 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{WorkerBatch: 10, WorkerInterval: 5, WorkerMaxAttempts: 3}
+
+	args := []byte(`{"amount":1000,"currency":"usd","user_id":1}`)
+	staged := []entity.StagedJob{
+		{ID: int64(gofakeit.Number(1, 500)), JobName: stagedjob.JobNameSendReceipt, JobArgs: args},
+		{ID: int64(gofakeit.Number(501, 1000)), JobName: stagedjob.JobNameSendReceipt, JobArgs: args},
+	}
+
+	receipts := func(h jobs.Handler[stagedjob.JobArgReceipt]) *jobs.Registry {
+		return NewJobs(JobHandlers{SendReceipt: h})
+	}
+
+	// claims makes the mocked FindAll take the given jobs as a batch,
+	// recording them as they're claimed.
+	claims := func(m testMocks, sjs ...entity.StagedJob) *[]entity.StagedJob {
+		var claimed []entity.StagedJob
+		m.job.On("FindAll", ctx, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(append([]entity.StagedJob{}, sjs...), nil)
+		m.job.On("UpdateMany", ctx, mock.Anything, "run_at").
+			Once().
+			Run(func(args mock.Arguments) { claimed = append(claimed, args.Get(1).([]entity.StagedJob)...) }).
+			Return(len(sjs), nil)
+		return &claimed
+	}
+
+	// updates records the jobs given to the mocked Update, which are failed.
+	updates := func(m testMocks) *[]entity.StagedJob {
+		var sjs []entity.StagedJob
+		m.job.On("Update", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { sjs = append(sjs, *args.Get(1).(*entity.StagedJob)) }).
+			Return(nil)
+		return &sjs
+	}
+
+	// deletes records the ids of the jobs given to the mocked Delete.
+	deletes := func(m testMocks) *[]int64 {
+		var ids []int64
+		m.job.On("Delete", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { ids = append(ids, args.Get(1).(*entity.StagedJob).ID) }).
+			Return(nil)
+		return &ids
+	}
+
+	t.Run("No handlers registered", func(t *testing.T) {
+		m := getMocksWithTimes(0)
+		uc := NewEnqueuer(mockCfg, m.uow, NewJobs(JobHandlers{}))
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		m.uow.AssertNumberOfCalls(t, "Do", 0)
+	})
+
+	t.Run("No jobs due", func(t *testing.T) {
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return nil }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claims(m)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		m.job.AssertNumberOfCalls(t, "Update", 0)
+		m.job.AssertNumberOfCalls(t, "Delete", 0)
+	})
+
+	t.Run("Error on FindAll", func(t *testing.T) {
+		retErr := errors.New("err FindAll")
+
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return nil }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		m.job.On("FindAll", ctx, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(nil, retErr)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, n)
+		m.job.AssertNumberOfCalls(t, "UpdateMany", 0)
+	})
+
+	t.Run("Error on claiming jobs", func(t *testing.T) {
+		retErr := errors.New("err UpdateMany")
+
+		m := getMocksWithTimes(-1)
+		var handled int
+		handler := func(context.Context, stagedjob.JobArgReceipt) error {
+			handled++
+			return nil
+		}
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		m.job.On("FindAll", ctx, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(append([]entity.StagedJob{}, staged...), nil)
+		m.job.On("UpdateMany", ctx, mock.Anything, "run_at").
+			Once().
+			Return(0, retErr)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, n)
+		assert.Zero(t, handled)
+	})
+
+	t.Run("Error on job handler", func(t *testing.T) {
+		retErr := errors.New("err handler")
+
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return retErr }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claimed := claims(m, staged[0])
+		updated := updates(m)
+
+		before := time.Now().UTC()
+		n, err := uc.Enqueue(ctx)
+
+		// failures are recorded rather than returned
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		m.job.AssertNumberOfCalls(t, "Delete", 0)
+
+		if assert.Len(t, *claimed, 1) {
+			assert.Zero(t, (*claimed)[0].Attempts)
+			assert.WithinDuration(t, before.Add(jobLease), (*claimed)[0].RunAt, time.Second)
+		}
+
+		if assert.Len(t, *updated, 1) {
+			failed := (*updated)[0]
+
+			assert.Equal(t, staged[0].ID, failed.ID)
+			assert.Equal(t, 1, failed.Attempts)
+			assert.Equal(t, retErr.Error(), failed.LastError)
+			assert.Nil(t, failed.FailedAt)
+			assert.WithinDuration(t, before.Add(5*time.Second), failed.RunAt, time.Second)
+		}
+	})
+
+	t.Run("Dead job after max attempts", func(t *testing.T) {
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return errors.New("err handler") }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		retried := []entity.StagedJob{staged[0], staged[1]}
+		retried[0].Attempts = 1
+		retried[1].Attempts = mockCfg.WorkerMaxAttempts - 1
+		claims(m, retried...)
+		updated := updates(m)
+
+		before := time.Now().UTC()
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		if assert.Len(t, *updated, 2) {
+			// the delay doubles on each attempt
+			failed := (*updated)[0]
+			assert.Equal(t, 2, failed.Attempts)
+			assert.Nil(t, failed.FailedAt)
+			assert.WithinDuration(t, before.Add(10*time.Second), failed.RunAt, time.Second)
+
+			dead := (*updated)[1]
+			assert.Equal(t, mockCfg.WorkerMaxAttempts, dead.Attempts)
+			assert.NotNil(t, dead.FailedAt)
+		}
+	})
+
+	t.Run("Error on malformed job args", func(t *testing.T) {
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return nil }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claims(m, entity.StagedJob{ID: 1, JobName: stagedjob.JobNameSendReceipt, JobArgs: []byte(`{"user_id":1}`)})
+		updated := updates(m)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		m.job.AssertNumberOfCalls(t, "Delete", 0)
+
+		// there's no point in retrying them, so they're dead right away
+		if assert.Len(t, *updated, 1) {
+			failed := (*updated)[0]
+			assert.Equal(t, 1, failed.Attempts)
+			assert.Contains(t, failed.LastError, jobs.ErrInvalidJobArgs.Error())
+			assert.NotNil(t, failed.FailedAt)
+		}
+	})
+
+	t.Run("Error on Delete", func(t *testing.T) {
+		retErr := errors.New("err Delete")
+
+		m := getMocksWithTimes(-1)
+		handler := func(context.Context, stagedjob.JobArgReceipt) error { return nil }
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claims(m, staged...)
+		updates(m)
+		m.job.On("Delete", ctx, mock.Anything).
+			Once().
+			Return(retErr)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, n)
+		m.job.AssertNumberOfCalls(t, "Delete", 1)
+	})
+
+	t.Run("Failing job doesn't hold back its batch", func(t *testing.T) {
+		var handled []int64
+
+		m := getMocksWithTimes(-1)
+		fail := true
+		handler := func(_ context.Context, args stagedjob.JobArgReceipt) error {
+			handled = append(handled, args.UserID)
+			if args.UserID == 2 && fail {
+				return errors.New("err handler")
+			}
+			return nil
+		}
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		batch := []entity.StagedJob{staged[0], staged[1]}
+		batch[0].JobArgs = []byte(`{"amount":1000,"currency":"usd","user_id":1}`)
+		batch[1].JobArgs = []byte(`{"amount":1000,"currency":"usd","user_id":2}`)
+		claims(m, batch...)
+		updated := updates(m)
+		deleted := deletes(m)
+
+		n, err := uc.Enqueue(ctx)
+
+		// the first job is done with before the second one is run, so it's
+		// deleted no matter how the second one goes
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []int64{1, 2}, handled)
+		assert.Equal(t, []int64{batch[0].ID}, *deleted)
+		if assert.Len(t, *updated, 1) {
+			assert.Equal(t, batch[1].ID, (*updated)[0].ID)
+			assert.Equal(t, 1, (*updated)[0].Attempts)
+		}
+
+		// once due again, the second job is retried on its own: the first
+		// one is neither run nor deleted again
+		fail = false
+		retried := (*updated)[0]
+		claims(m, retried)
+
+		n, err = uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []int64{1, 2, 2}, handled)
+		assert.Equal(t, []int64{batch[0].ID, batch[1].ID}, *deleted)
+	})
+
+	t.Run("Jobs handed over within their lease", func(t *testing.T) {
+		var deadlines []time.Time
+
+		m := getMocksWithTimes(-1)
+		handler := func(ctx context.Context, _ stagedjob.JobArgReceipt) error {
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			deadlines = append(deadlines, deadline)
+			return nil
+		}
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claimed := claims(m, staged...)
+		deletes(m)
+
+		before := time.Now().UTC()
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(staged), n)
+		if assert.Len(t, *claimed, len(staged)) && assert.Len(t, deadlines, len(staged)) {
+			for i, sj := range *claimed {
+				assert.WithinDuration(t, before.Add(jobLease), sj.RunAt, time.Second)
+				assert.Equal(t, sj.RunAt, deadlines[i])
+			}
+		}
+	})
+
+	t.Run("Success on Enqueue", func(t *testing.T) {
+		var handled []stagedjob.JobArgReceipt
+
+		m := getMocksWithTimes(-1)
+		handler := func(_ context.Context, args stagedjob.JobArgReceipt) error {
+			handled = append(handled, args)
+			return nil
+		}
+		uc := NewEnqueuer(mockCfg, m.uow, receipts(handler))
+
+		claims(m, staged...)
+		updates(m)
+		deleted := deletes(m)
+
+		n, err := uc.Enqueue(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(staged), n)
+		exp := stagedjob.JobArgReceipt{Amount: 1000, Currency: "usd", UserID: 1}
+		assert.Equal(t, []stagedjob.JobArgReceipt{exp, exp}, handled)
+		assert.Equal(t, []int64{staged[0].ID, staged[1].ID}, *deleted)
+
+		// the batch is claimed at once, then each job is done with in a
+		// transaction of its own
+		m.uow.AssertNumberOfCalls(t, "Do", len(staged)+1)
+		m.job.AssertNumberOfCalls(t, "FindAll", 1)
+	})
+}
@@ -0,0 +1,462 @@
+package idemkey
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/labstack/gommon/log"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+// Result tells the Runner what to do with the idempotency key once an atomic
+// phase is over.
+type Result func(*entity.IdempotencyKey) error
+
+// RecoveryPoint moves the key on to the given recovery point, so that the phase
+// registered for it is the next one to run.
+func RecoveryPoint(rp idempotency.RecoveryPoint) Result {
+	return func(ik *entity.IdempotencyKey) error {
+		ik.RecoveryPoint = rp
+		return nil
+	}
+}
+
+// Response finishes the request, storing the response to be replayed to every
+// later request made with the same key. The body is stored already encoded as
+// JSON, so that replays are byte-identical to the original response.
+func Response(code idempotency.ResponseCode, body interface{}, headers idempotency.ResponseHeaders) Result {
+	return func(ik *entity.IdempotencyKey) error {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		ik.LockedAt = nil
+		ik.ResponseCode = &code
+		ik.ResponseBody = b
+		ik.ResponseHeaders = headers
+		ik.RecoveryPoint = idempotency.RecoveryPointFinished
+		return nil
+	}
+}
+
+// Suspend stops the request at the given recovery point, storing a response to
+// be sent back meanwhile, e.g. while waiting on the customer. Unlike Response,
+// the request isn't finished: once it's retried with the same key, it picks
+// up from the phase registered for rp.
+func Suspend(
+	rp idempotency.RecoveryPoint,
+	code idempotency.ResponseCode,
+	body interface{},
+	headers idempotency.ResponseHeaders,
+) Result {
+	return func(ik *entity.IdempotencyKey) error {
+		if err := Response(code, body, headers)(ik); err != nil {
+			return err
+		}
+
+		ik.RecoveryPoint = rp
+		return nil
+	}
+}
+
+// Audit saves, along with the changes of the atomic phase being run, the audit
+// record of the request moving on as told by res. Only the record's Action,
+// ResourceType and ResourceID need to be set, its Data is the transition
+// between the key's recovery points plus the given details. It returns res
+// untouched, so that phases can end with it.
+func Audit(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	res Result,
+	ar entity.AuditRecord,
+	details interface{},
+) (Result, error) {
+	// find out where the request is headed without touching the key itself
+	next := *ik
+	if err := res(&next); err != nil {
+		return nil, err
+	}
+
+	if err := saveAuditRecord(ctx, uows, *ik, next.RecoveryPoint, ar, details); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func saveAuditRecord(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik entity.IdempotencyKey,
+	after idempotency.RecoveryPoint,
+	ar entity.AuditRecord,
+	details interface{},
+) error {
+	data, err := json.Marshal(audit.Transition{
+		IdempotencyKeyID:    ik.ID,
+		RecoveryPointBefore: ik.RecoveryPoint,
+		RecoveryPointAfter:  after,
+		Details:             details,
+	})
+	if err != nil {
+		return err
+	}
+
+	ar.CreatedAt = time.Now().UTC()
+	ar.Data = data
+	ar.OriginIP = originip.FromCtx(ctx).IP
+	ar.UserID = ik.UserID
+	return uows.AuditRecords().Save(ctx, &ar)
+}
+
+// auditKey saves an audit record of something that happened to the key itself,
+// which stays at the same recovery point. Keys of signups belong to no user
+// yet, so there's nobody to audit them for.
+func auditKey(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	key entity.IdempotencyKey,
+	action audit.Action,
+	details interface{},
+) error {
+	if key.UserID == 0 {
+		return nil
+	}
+
+	ar := entity.AuditRecord{
+		Action:       action,
+		ResourceID:   key.ID,
+		ResourceType: audit.ResourceTypeIdempotencyKey,
+	}
+	return saveAuditRecord(ctx, uows, key, key.RecoveryPoint, ar, details)
+}
+
+// PhaseFunc does the actual work of an atomic phase. All data changes must go
+// through the given UnitOfWorkStore, so that they're committed along with the
+// key's new state.
+type PhaseFunc func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error)
+
+// Phase is an atomic phase of a request, picking up from its RecoveryPoint.
+type Phase struct {
+	RecoveryPoint idempotency.RecoveryPoint
+	Run           PhaseFunc
+}
+
+// txOptions are used for every transaction run by the Runner, which relies on
+// the SERIALIZABLE isolation level to be safe from races.
+var txOptions = []uow.Option{uow.WithIsolation(sql.LevelSerializable)}
+
+type runner struct {
+	cfg config.Config
+	uow uow.UnitOfWork
+	iks datastore.IdempotencyKey
+}
+
+// Runner takes requests protected by idempotency keys through their atomic
+// phases, one after the other, until they're finished.
+type Runner interface {
+	Run(context.Context, *entity.IdempotencyKey, ...Phase) error
+	Resume(context.Context, *entity.IdempotencyKey, ...Phase) error
+}
+
+func New(cfg config.Config, uow uow.UnitOfWork, iks datastore.IdempotencyKey) Runner {
+	return &runner{
+		cfg: cfg,
+		uow: uow,
+		iks: iks,
+	}
+}
+
+// Run creates or locks the given idempotency key and executes the request's
+// phases. New keys start off at the first phase's recovery point. Once it
+// returns without errors, the key holds the response to be sent back to the
+// client, either a brand new one or a replay.
+func (r *runner) Run(ctx context.Context, ik *entity.IdempotencyKey, phases ...Phase) error {
+	start := idempotency.RecoveryPointStarted
+	if len(phases) > 0 {
+		start = phases[0].RecoveryPoint
+	}
+
+	err := r.setIdempotencyKey(ctx, ik, start)
+	if err != nil {
+		return err
+	}
+
+	return r.run(ctx, ik, phases)
+}
+
+// Resume locks an existing idempotency key and executes the request's phases
+// starting from its last recovery point. The request params aren't checked,
+// since they're taken from the key itself.
+func (r *runner) Resume(ctx context.Context, ik *entity.IdempotencyKey, phases ...Phase) error {
+	var locked entity.IdempotencyKey
+
+	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		key, err := uows.IdempotencyKeys().FindOne(
+			ctx,
+			datastore.IdemKeyWithKey(ik.IdempotencyKey),
+			datastore.IdemKeyWithUserID(ik.UserID),
+		)
+		if err != nil {
+			return err
+		}
+
+		locked, err = r.lockIdempotencyKey(ctx, uows, key)
+		return err
+	}, txOptions...)
+	if err != nil {
+		return err
+	}
+
+	// update the reference with data from persistence layer
+	locked.User = ik.User
+	*ik = locked
+
+	return r.run(ctx, ik, phases)
+}
+
+func (r *runner) run(ctx context.Context, ik *entity.IdempotencyKey, phases []Phase) error {
+	var err error
+
+	defer func() {
+		// If we're leaving under an error condition, try to unlock the idempotency
+		// key right away so that another request can try again.
+		if err != nil && ik != nil {
+			r.unlockIdempotencyKey(ctx, ik)
+		}
+	}()
+
+	for {
+		// suspended requests hold a response without being finished
+		if ik.RecoveryPoint == idempotency.RecoveryPointFinished || ik.ResponseCode != nil {
+			return nil
+		}
+
+		phase, ok := findPhase(phases, ik.RecoveryPoint)
+		if !ok {
+			// set err so that the key gets unlocked
+			err = entity.ErrIdemKeyUnknownRecoveryPoint
+			return err
+		}
+
+		err = r.runPhase(ctx, ik, phase)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runPhase executes a single atomic phase and, in the same transaction, saves
+// the key's new state as told by the phase's result.
+func (r *runner) runPhase(ctx context.Context, ik *entity.IdempotencyKey, phase Phase) error {
+	var key entity.IdempotencyKey
+
+	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		// start over from the original key in case the transaction is retried
+		key = *ik
+
+		res, err := phase.Run(ctx, uows, &key)
+		if err != nil {
+			return err
+		}
+
+		if err := res(&key); err != nil {
+			return err
+		}
+
+		return uows.IdempotencyKeys().Update(ctx, &key)
+	}, txOptions...)
+	if err != nil {
+		return err
+	}
+
+	// only let the changes out once they're committed
+	*ik = key
+	return nil
+}
+
+func (r *runner) setIdempotencyKey(
+	ctx context.Context,
+	ik *entity.IdempotencyKey,
+	start idempotency.RecoveryPoint,
+) error {
+	var (
+		res      entity.IdempotencyKey
+		mismatch error
+	)
+
+	// Our first atomic phase to create or update an idempotency key.
+	//
+	// The key is saved unless there's one already, in which case that one is
+	// locked until the transaction is over. Two requests racing to save the
+	// same key are serialized by its unique index, so that the one that loses
+	// gets hold of the key saved by the other.
+	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		mismatch = nil
+
+		now := time.Now().UTC()
+		res = *ik
+		res.LastRunAt = now
+		res.LockedAt = &now
+		res.RecoveryPoint = start
+
+		saved, err := uows.IdempotencyKeys().SaveOrGet(
+			ctx,
+			&res,
+			datastore.IdemKeyWithKey(ik.IdempotencyKey),
+			datastore.IdemKeyWithUserID(ik.UserID),
+			data.ForUpdate(),
+		)
+		if err != nil || saved {
+			return err
+		}
+		key := res
+
+		// Programs sending multiple requests with different parameters but the
+		// same idempotency key is a bug. The rejection is committed, so that
+		// it's audited, while the key itself is left as it was.
+		if mismatch = CompareRequest(key, *ik); mismatch != nil {
+			return auditKey(ctx, uows, key, audit.ActionIdemKeyMismatch, map[string]string{
+				"error":          mismatch.Error(),
+				"request_method": ik.RequestMethod,
+				"request_path":   ik.RequestPath,
+			})
+		}
+
+		res, err = r.lockIdempotencyKey(ctx, uows, key)
+		return err
+	}, txOptions...)
+	if err != nil {
+		return err
+	}
+	if mismatch != nil {
+		return mismatch
+	}
+
+	// update the reference with data from persistence layer
+	res.User = ik.User
+	*ik = res
+
+	return nil
+}
+
+// lockIdempotencyKey acquires the lock on an existing key, returning it as it
+// was saved.
+func (r *runner) lockIdempotencyKey(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	key entity.IdempotencyKey,
+) (entity.IdempotencyKey, error) {
+	// Only acquire a lock if the key is unlocked or its lock has expired
+	// because it was long enough ago.
+	timeout := time.Duration(r.cfg.IdemKeyTimeout) * time.Second
+	if key.LockedAt != nil && key.LockedAt.After(time.Now().UTC().Add(-1*timeout)) {
+		return key, entity.ErrIdemKeyRequestInProgress
+	}
+
+	// Lock the key and update latest run unless the request is already
+	// finished. Responses stored by suspended requests are dropped, since
+	// they're about to be run again.
+	if key.RecoveryPoint != idempotency.RecoveryPointFinished {
+		// a lock left behind by a request that timed out is being taken over
+		if key.LockedAt != nil {
+			stale := map[string]time.Time{"locked_at": *key.LockedAt}
+			if err := auditKey(ctx, uows, key, audit.ActionIdemKeyLockStolen, stale); err != nil {
+				return key, err
+			}
+		}
+
+		now := time.Now().UTC()
+		key.LastRunAt = now
+		key.LockedAt = &now
+		key.ResponseCode = nil
+		key.ResponseBody = nil
+		key.ResponseHeaders = nil
+		if err := uows.IdempotencyKeys().Update(ctx, &key); err != nil {
+			return key, err
+		}
+	}
+
+	return key, nil
+}
+
+func (r *runner) unlockIdempotencyKey(ctx context.Context, ik *entity.IdempotencyKey) {
+	ik.LockedAt = nil
+	err := r.iks.Update(ctx, ik)
+	if err != nil {
+		log.Errorf("unlock idem key error: %v", err)
+	}
+}
+
+func findPhase(phases []Phase, rp idempotency.RecoveryPoint) (Phase, bool) {
+	for i := range phases {
+		if phases[i].RecoveryPoint == rp {
+			return phases[i], true
+		}
+	}
+	return Phase{}, false
+}
+
+// Fingerprint computes the SHA-256 fingerprint of a request out of its method,
+// path and body. JSON bodies are normalized first, so that neither whitespace
+// nor key order changes the result.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(normalize(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CompareRequest tells whether both keys were sent along with the same
+// request, otherwise it returns an error naming the part that differed.
+func CompareRequest(stored, ik entity.IdempotencyKey) error {
+	switch {
+	case stored.RequestMethod != ik.RequestMethod:
+		return entity.ErrIdemKeyMethodMismatch
+	case stored.RequestPath != ik.RequestPath:
+		return entity.ErrIdemKeyPathMismatch
+	case fingerprint(stored) != fingerprint(ik):
+		return entity.ErrIdemKeyBodyMismatch
+	}
+	return nil
+}
+
+// fingerprint returns the key's request fingerprint, computing it for keys
+// stored before fingerprints were.
+func fingerprint(ik entity.IdempotencyKey) string {
+	if ik.RequestFingerprint != "" {
+		return ik.RequestFingerprint
+	}
+	return Fingerprint(ik.RequestMethod, ik.RequestPath, ik.RequestParams)
+}
+
+// normalize re-encodes JSON bodies in their canonical form, with object keys
+// sorted and no whitespace. Anything else is left as is.
+func normalize(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return b
+}
@@ -0,0 +1,1365 @@
+//go:build unit
+// +build unit
+
+package idemkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type testMocks struct {
+	uow     *mocks.UnitOfWork
+	uows    *mocks.UnitOfWorkStore
+	idemKey *mocks.IdempotencyKey
+	audit   *mocks.AuditRecord
+}
+
+func getMocks() testMocks {
+	return getMocksWithTimes(1)
+}
+
+func getMocksWithTimes(n int) testMocks {
+	m := testMocks{
+		uow:     &mocks.UnitOfWork{},
+		uows:    &mocks.UnitOfWorkStore{},
+		idemKey: &mocks.IdempotencyKey{},
+		audit:   &mocks.AuditRecord{},
+	}
+
+	m.uows.On("AuditRecords").Return(m.audit)
+	m.uows.On("IdempotencyKeys").Return(m.idemKey)
+
+	var mockUOW *mock.Call
+	// every transaction is run with the Runner's options
+	mockUOW = m.uow.On("Do", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fn, ok := args.Get(1).(uow.UnitOfWorkBlock)
+			if !ok {
+				panic("argument mismatch")
+			}
+
+			// Call the actual func argument 'fn' passed in to
+			// 'DO(context.Context, datastore.UnitOfWorkStore) error'
+			// as expected from its second parameter and, while doing so, inject the
+			// mocked UnitOfWork instance 'mockUOW' so we're able to test the other calls
+			// made to it inside the 'UnitOfWorkBlock'.
+			mockUOW.Return(fn(m.uows))
+		})
+
+	if n >= 0 {
+		mockUOW.Times(n)
+	}
+
+	return m
+}
+
+// foundKey makes a mocked SaveOrGet load the given key in place of the one
+// that would have been saved, as if it was already there.
+func foundKey(key entity.IdempotencyKey) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		*args.Get(1).(*entity.IdempotencyKey) = key
+	}
+}
+
+// phaseTo returns a phase that simply moves the key on to the next recovery
+// point, counting how many times it was executed.
+func phaseTo(from, to idempotency.RecoveryPoint, calls *int) Phase {
+	return Phase{
+		RecoveryPoint: from,
+		Run: func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error) {
+			*calls++
+			return RecoveryPoint(to), nil
+		},
+	}
+}
+
+// phaseFinish returns a phase that finishes the request with an OK response.
+func phaseFinish(from idempotency.RecoveryPoint, calls *int) Phase {
+	return Phase{
+		RecoveryPoint: from,
+		Run: func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error) {
+			*calls++
+			return Response(idempotency.ResponseCodeOK, idempotency.Message{Message: "OK"}, nil), nil
+		},
+	}
+}
+
+func TestSetIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5}
+
+	gofakeit.Seed(time.Now().UnixNano())
+	jsonRide, err := json.Marshal(entity.Ride{
+		OriginLat: gofakeit.Float64(),
+		OriginLon: gofakeit.Float64(),
+		TargetLat: gofakeit.Float64(),
+		TargetLon: gofakeit.Float64(),
+	})
+	require.NoError(t, err)
+
+	t.Run("Error on CreateIdempotencyKey", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+		}
+
+		retErr := errors.New("err CreateIdempotencyKey")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(false, retErr)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, retErr, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+	})
+
+	t.Run("Success on CreateIdempotencyKey", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointStarted, ik.RecoveryPoint)
+		assert.GreaterOrEqual(t, time.Now().UTC(), ik.LastRunAt)
+		if assert.NotNil(t, ik.LockedAt) {
+			assert.GreaterOrEqual(t, time.Now().UTC(), *ik.LockedAt)
+		}
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+	})
+
+	t.Run("Request parameters mismatch", func(t *testing.T) {
+		gofakeit.Seed(time.Now().UnixNano())
+		jsonRide2, err := json.Marshal(entity.Ride{
+			OriginLat: gofakeit.Float64(),
+			OriginLon: gofakeit.Float64(),
+			TargetLat: gofakeit.Float64(),
+			TargetLon: gofakeit.Float64(),
+		})
+		require.NoError(t, err)
+
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+		}
+
+		retIK := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide2,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		var ar *entity.AuditRecord
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				ar = args.Get(1).(*entity.AuditRecord)
+			})
+
+		err = uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, entity.ErrIdemKeyBodyMismatch, err)
+		assert.ErrorIs(t, err, entity.ErrIdemKeyParamsMismatch)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+		// the rejection is audited, though the key is left as it was
+		if assert.NotNil(t, ar) {
+			assert.Equal(t, audit.ActionIdemKeyMismatch, ar.Action)
+			assert.Equal(t, audit.ResourceTypeIdempotencyKey, ar.ResourceType)
+			assert.Equal(t, userID, ar.UserID)
+
+			var tr audit.Transition
+			require.NoError(t, json.Unmarshal(ar.Data, &tr))
+			assert.Equal(t, retIK.RecoveryPoint, tr.RecoveryPointBefore)
+			assert.Equal(t, retIK.RecoveryPoint, tr.RecoveryPointAfter)
+			assert.Equal(t, map[string]interface{}{
+				"error":          entity.ErrIdemKeyBodyMismatch.Error(),
+				"request_method": "",
+				"request_path":   "",
+			}, tr.Details)
+		}
+	})
+
+	t.Run("Error on audit of request parameters mismatch", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestMethod:  "POST",
+		}
+
+		retErr := errors.New("err CreateAuditRecord")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(entity.IdempotencyKey{IdempotencyKey: key, UserID: userID, RequestMethod: "PUT"})).
+			Return(false, nil)
+
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(retErr)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Request parameters mismatch on signup", func(t *testing.T) {
+		key := gofakeit.UUID()
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			RequestPath:    "/users",
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(entity.IdempotencyKey{IdempotencyKey: key, RequestPath: "/rides"})).
+			Return(false, nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		// there's no user to audit it for
+		assert.Equal(t, entity.ErrIdemKeyPathMismatch, err)
+		m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Request in progress", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+		}
+
+		now := time.Now().UTC()
+		retIK := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			LockedAt:       &now,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, entity.ErrIdemKeyRequestInProgress, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+	})
+
+	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
+		// list non-terminal Recovery Point (i.e., all but 'FINISHED')
+		rps := []idempotency.RecoveryPoint{
+			idempotency.RecoveryPointStarted,
+			idempotency.RecoveryPointCreated,
+			idempotency.RecoveryPointCharged,
+		}
+		// randomly pick a Recovery Point
+		ix := gofakeit.Number(0, len(rps)-1)
+
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  rps[ix],
+		}
+
+		retErr := errors.New("err UpdateIdempotencyKey")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
+
+		m.idemKey.On("Update", ctx, mock.Anything).
+			Once().
+			Return(retErr)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, retErr, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+
+	t.Run("Success on UpdateIdempotencyKey", func(t *testing.T) {
+		// list non-terminal Recovery Point (i.e., all but 'FINISHED')
+		rps := []idempotency.RecoveryPoint{
+			idempotency.RecoveryPointStarted,
+			idempotency.RecoveryPointCreated,
+			idempotency.RecoveryPointCharged,
+		}
+		// randomly pick a Recovery Point
+		ix := gofakeit.Number(0, len(rps)-1)
+
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  rps[ix],
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
+
+		m.idemKey.On("Update", ctx, mock.Anything).
+			Once().
+			Return(nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.NoError(t, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+
+	t.Run("Lock updates key reference", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		user := &entity.User{ID: userID}
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			User:           user,
+			RequestParams:  jsonRide,
+		}
+
+		retIK := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		m.idemKey.On("Update", ctx, mock.Anything).
+			Once().
+			Return(nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, retIK.ID, ik.ID)
+		assert.Equal(t, idempotency.RecoveryPointCreated, ik.RecoveryPoint)
+		assert.Equal(t, user, ik.User)
+		assert.NotNil(t, ik.LockedAt)
+	})
+
+	t.Run("Lock stolen after timeout", func(t *testing.T) {
+		oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
+		ctx := originip.NewContext(ctx, oip)
+
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+		}
+
+		stale := time.Now().UTC().Add(-1 * time.Hour).Truncate(time.Second)
+		retIK := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+			LockedAt:       &stale,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		m.idemKey.On("Update", ctx, mock.Anything).
+			Once().
+			Return(nil)
+
+		var ar *entity.AuditRecord
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				ar = args.Get(1).(*entity.AuditRecord)
+			})
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		require.NoError(t, err)
+		assert.True(t, ik.LockedAt.After(stale))
+		if assert.NotNil(t, ar) {
+			assert.Equal(t, audit.ActionIdemKeyLockStolen, ar.Action)
+			assert.Equal(t, audit.ResourceTypeIdempotencyKey, ar.ResourceType)
+			assert.Equal(t, retIK.ID, ar.ResourceID)
+			assert.Equal(t, userID, ar.UserID)
+			assert.Equal(t, oip.IP, ar.OriginIP)
+
+			var tr struct {
+				audit.Transition
+				Details struct {
+					LockedAt time.Time `json:"locked_at"`
+				} `json:"details"`
+			}
+			require.NoError(t, json.Unmarshal(ar.Data, &tr))
+			assert.Equal(t, retIK.ID, tr.IdempotencyKeyID)
+			assert.Equal(t, idempotency.RecoveryPointCreated, tr.RecoveryPointBefore)
+			assert.Equal(t, idempotency.RecoveryPointCreated, tr.RecoveryPointAfter)
+			assert.True(t, stale.Equal(tr.Details.LockedAt))
+		}
+	})
+
+	t.Run("Error on audit of lock stolen", func(t *testing.T) {
+		stale := time.Now().UTC().Add(-1 * time.Hour)
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+		}
+		retIK := ik
+		retIK.LockedAt = &stale
+
+		retErr := errors.New("err CreateAuditRecord")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(retErr)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.Equal(t, retErr, err)
+		m.idemKey.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("No-op on RecoveryPointFinished", func(t *testing.T) {
+		key := gofakeit.UUID()
+		userID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointFinished,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
+
+		err := uc.setIdempotencyKey(ctx, &ik, idempotency.RecoveryPointStarted)
+
+		assert.NoError(t, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+	})
+}
+
+func TestUnlockIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5}
+
+	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
+		ik := entity.IdempotencyKey{}
+
+		retErr := errors.New("err UpdateIdempotencyKey")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("Update", ctx, &ik).
+			Once().
+			Return(retErr)
+
+		uc.unlockIdempotencyKey(ctx, &ik)
+
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+
+	t.Run("Success on UpdateIdempotencyKey", func(t *testing.T) {
+		ik := entity.IdempotencyKey{}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("Update", ctx, &ik).
+			Once().
+			Return(nil)
+
+		uc.unlockIdempotencyKey(ctx, &ik)
+
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+}
+
+func TestRunPhase(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5}
+
+	t.Run("Error on phase", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:            int64(gofakeit.Number(1, 1000)),
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+		}
+
+		retErr := errors.New("err Phase")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		phase := Phase{
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+			Run: func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error) {
+				return nil, retErr
+			},
+		}
+
+		err := uc.runPhase(ctx, &ik, phase)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, idempotency.RecoveryPointStarted, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
+	})
+
+	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:            int64(gofakeit.Number(1, 1000)),
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+		}
+
+		retErr := errors.New("err UpdateIdempotencyKey")
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(retErr)
+
+		var calls int
+		err := uc.runPhase(ctx, &ik, phaseTo(idempotency.RecoveryPointStarted, idempotency.RecoveryPointCreated, &calls))
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 1, calls)
+		// changes must not leak out of a failed transaction
+		assert.Equal(t, idempotency.RecoveryPointStarted, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+
+	t.Run("Success on phase moving to recovery point", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:            int64(gofakeit.Number(1, 1000)),
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(nil)
+
+		var calls int
+		err := uc.runPhase(ctx, &ik, phaseTo(idempotency.RecoveryPointStarted, idempotency.RecoveryPointCreated, &calls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, idempotency.RecoveryPointCreated, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+
+	t.Run("Success on phase finishing with response", func(t *testing.T) {
+		now := time.Now().UTC()
+		ik := entity.IdempotencyKey{
+			ID:            int64(gofakeit.Number(1, 1000)),
+			LockedAt:      &now,
+			RecoveryPoint: idempotency.RecoveryPointCharged,
+		}
+
+		m := getMocks()
+		uc := runner{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(nil)
+
+		var calls int
+		err := uc.runPhase(ctx, &ik, phaseFinish(idempotency.RecoveryPointCharged, &calls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.Equal(t, `{"message":"OK"}`, string(ik.ResponseBody))
+		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+	})
+}
+
+func TestResponse(t *testing.T) {
+	t.Run("Error on invalid body", func(t *testing.T) {
+		ik := entity.IdempotencyKey{RecoveryPoint: idempotency.RecoveryPointCharged}
+
+		err := Response(idempotency.ResponseCodeOK, make(chan int), nil)(&ik)
+
+		assert.Error(t, err)
+		assert.Equal(t, idempotency.RecoveryPointCharged, ik.RecoveryPoint)
+	})
+
+	t.Run("Success on Response", func(t *testing.T) {
+		now := time.Now().UTC()
+		ik := entity.IdempotencyKey{
+			LockedAt:      &now,
+			RecoveryPoint: idempotency.RecoveryPointCharged,
+		}
+
+		headers := idempotency.ResponseHeaders{"Location": "/rides/1"}
+		body := entity.Ride{ID: 1, UserID: 2}
+
+		err := Response(idempotency.ResponseCodeOK, body, headers)(&ik)
+
+		assert.NoError(t, err)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.Equal(t, headers, ik.ResponseHeaders)
+
+		var rd entity.Ride
+		require.NoError(t, json.Unmarshal(ik.ResponseBody, &rd))
+		assert.Equal(t, body, rd)
+	})
+}
+
+func TestSuspend(t *testing.T) {
+	now := time.Now().UTC()
+	ik := entity.IdempotencyKey{
+		LockedAt:      &now,
+		RecoveryPoint: idempotency.RecoveryPointCreated,
+	}
+
+	body := idempotency.Message{Message: "waiting"}
+
+	err := Suspend(idempotency.RecoveryPointAwaitingAction, idempotency.ResponseCodeErrPayment, body, nil)(&ik)
+
+	assert.NoError(t, err)
+	assert.Nil(t, ik.LockedAt)
+	// the request isn't finished, even though it has a response
+	assert.Equal(t, idempotency.RecoveryPointAwaitingAction, ik.RecoveryPoint)
+	assert.Equal(t, idempotency.ResponseCodeErrPayment, *ik.ResponseCode)
+	assert.JSONEq(t, `{"message": "waiting"}`, string(ik.ResponseBody))
+}
+
+func TestAudit(t *testing.T) {
+	oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
+	ctx := originip.NewContext(context.Background(), oip)
+
+	ik := entity.IdempotencyKey{
+		ID:            int64(gofakeit.Number(1, 1000)),
+		RecoveryPoint: idempotency.RecoveryPointCharged,
+		UserID:        int64(gofakeit.Number(1, 1000)),
+	}
+	ar := entity.AuditRecord{
+		Action:       audit.ActionStageReceipt,
+		ResourceID:   int64(gofakeit.Number(1, 1000)),
+		ResourceType: audit.ResourceTypeRide,
+	}
+	res := Response(idempotency.ResponseCodeOK, idempotency.Message{Message: "OK"}, nil)
+
+	t.Run("Error on CreateAuditRecord", func(t *testing.T) {
+		retErr := errors.New("err CreateAuditRecord")
+
+		m := getMocks()
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(retErr)
+
+		_, err := Audit(ctx, m.uows, &ik, res, ar, nil)
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Error on result", func(t *testing.T) {
+		m := getMocks()
+
+		_, err := Audit(ctx, m.uows, &ik, Response(idempotency.ResponseCodeOK, make(chan int), nil), ar, nil)
+		assert.Error(t, err)
+		m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success on Audit", func(t *testing.T) {
+		m := getMocks()
+
+		var saved *entity.AuditRecord
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				saved = args.Get(1).(*entity.AuditRecord)
+			})
+
+		got, err := Audit(ctx, m.uows, &ik, res, ar, map[string]int64{"staged_job_id": 7})
+		require.NoError(t, err)
+
+		// the key is only moved on once the result is applied by the Runner
+		assert.Equal(t, idempotency.RecoveryPointCharged, ik.RecoveryPoint)
+		key := ik
+		require.NoError(t, got(&key))
+		assert.Equal(t, idempotency.RecoveryPointFinished, key.RecoveryPoint)
+
+		if assert.NotNil(t, saved) {
+			assert.Equal(t, ar.Action, saved.Action)
+			assert.Equal(t, ar.ResourceID, saved.ResourceID)
+			assert.Equal(t, ar.ResourceType, saved.ResourceType)
+			assert.Equal(t, ik.UserID, saved.UserID)
+			assert.Equal(t, oip.IP, saved.OriginIP)
+			assert.False(t, saved.CreatedAt.IsZero())
+
+			expected := fmt.Sprintf(
+				`{"idempotency_key_id": %v, "recovery_point_before": "CHARGED", "recovery_point_after": "FINISHED",
+				"details": {"staged_job_id": 7}}`,
+				ik.ID,
+			)
+			assert.JSONEq(t, expected, string(saved.Data))
+		}
+	})
+}
+
+func TestRunPhaseRetry(t *testing.T) {
+	ctx := context.Background()
+
+	ik := entity.IdempotencyKey{
+		ID:            int64(gofakeit.Number(1, 1000)),
+		RecoveryPoint: idempotency.RecoveryPointStarted,
+	}
+
+	idemKey := &mocks.IdempotencyKey{}
+	uows := &mocks.UnitOfWorkStore{}
+	uows.On("IdempotencyKeys").Return(idemKey)
+
+	idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+		Twice().
+		Return(nil)
+
+	// run the block twice, as if the first transaction had been aborted
+	mockUOW := &mocks.UnitOfWork{}
+	mockUOW.On("Do", mock.Anything, mock.Anything, mock.Anything).
+		Once().
+		Return(func(_ context.Context, fn uow.UnitOfWorkBlock, _ ...uow.Option) error {
+			_ = fn(uows)
+			return fn(uows)
+		})
+
+	uc := runner{cfg: config.Config{IdemKeyTimeout: 5}, uow: mockUOW, iks: idemKey}
+
+	var seen []idempotency.RecoveryPoint
+	phase := Phase{
+		RecoveryPoint: idempotency.RecoveryPointStarted,
+		Run: func(_ context.Context, _ uow.UnitOfWorkStore, k *entity.IdempotencyKey) (Result, error) {
+			seen = append(seen, k.RecoveryPoint)
+			return RecoveryPoint(idempotency.RecoveryPointCreated), nil
+		},
+	}
+
+	err := uc.runPhase(ctx, &ik, phase)
+
+	assert.NoError(t, err)
+	// each run starts over from the original key
+	assert.Equal(t, []idempotency.RecoveryPoint{
+		idempotency.RecoveryPointStarted,
+		idempotency.RecoveryPointStarted,
+	}, seen)
+	assert.Equal(t, idempotency.RecoveryPointCreated, ik.RecoveryPoint)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5}
+
+	jsonRide, err := json.Marshal(entity.Ride{
+		OriginLat: gofakeit.Float64(),
+		OriginLon: gofakeit.Float64(),
+		TargetLat: gofakeit.Float64(),
+		TargetLon: gofakeit.Float64(),
+	})
+	require.NoError(t, err)
+
+	t.Run("Error on setIdempotencyKey", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+		}
+
+		retErr := errors.New("err setIdempotencyKey")
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(false, retErr)
+
+		var calls int
+		err := uc.Run(ctx, &ik, phaseFinish(idempotency.RecoveryPointStarted, &calls))
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("Error on phase", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointStarted,
+		}
+
+		retErr := errors.New("err Phase")
+
+		m := getMocksWithTimes(2)
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
+
+		// Lock and then unlock the key
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		phase := Phase{
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+			Run: func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error) {
+				return nil, retErr
+			},
+		}
+
+		err := uc.Run(ctx, &ik, phase)
+
+		assert.Equal(t, retErr, err)
+		assert.Nil(t, ik.LockedAt)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+	})
+
+	t.Run("No-op on finished recovery point", func(t *testing.T) {
+		rCode := idempotency.ResponseCodeOK
+		rBody := json.RawMessage(`{"id": 1}`)
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+		}
+
+		retIK := ik
+		retIK.RecoveryPoint = idempotency.RecoveryPointFinished
+		retIK.ResponseCode = &rCode
+		retIK.ResponseBody = rBody
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(retIK)).
+			Return(false, nil)
+
+		var calls int
+		err := uc.Run(ctx, &ik, phaseFinish(idempotency.RecoveryPointStarted, &calls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, rCode, *ik.ResponseCode)
+		assert.Equal(t, rBody, ik.ResponseBody)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
+	})
+
+	t.Run("Error on unknown recovery point", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+			RecoveryPoint:  "unknown",
+		}
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
+
+		// locked and then unlocked right away
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(2).
+			Return(nil)
+
+		var calls int
+		err := uc.Run(ctx, &ik, phaseFinish(idempotency.RecoveryPointStarted, &calls))
+
+		assert.Equal(t, entity.ErrIdemKeyUnknownRecoveryPoint, err)
+		assert.Equal(t, 0, calls)
+		assert.Nil(t, ik.LockedAt)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+	})
+
+	t.Run("Success on Run", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+		}
+
+		m := getMocksWithTimes(4)
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		// Create Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil)
+
+		// one update for each phase
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(3).
+			Return(nil)
+
+		var started, created, charged int
+		err := uc.Run(
+			ctx,
+			&ik,
+			phaseTo(idempotency.RecoveryPointStarted, idempotency.RecoveryPointCreated, &started),
+			phaseFinish(idempotency.RecoveryPointCharged, &charged),
+			phaseTo(idempotency.RecoveryPointCreated, idempotency.RecoveryPointCharged, &created),
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, started)
+		assert.Equal(t, 1, created)
+		assert.Equal(t, 1, charged)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 3)
+	})
+
+	t.Run("Suspended request", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+			RequestParams:  jsonRide,
+		}
+
+		m := getMocksWithTimes(-1)
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		var awaiting, charged int
+		suspended := true
+		phases := []Phase{
+			phaseTo(idempotency.RecoveryPointStarted, idempotency.RecoveryPointAwaitingAction, new(int)),
+			{
+				RecoveryPoint: idempotency.RecoveryPointAwaitingAction,
+				Run: func(context.Context, uow.UnitOfWorkStore, *entity.IdempotencyKey) (Result, error) {
+					awaiting++
+					if suspended {
+						return Suspend(
+							idempotency.RecoveryPointAwaitingAction,
+							idempotency.ResponseCodeErrPayment,
+							idempotency.Message{Message: "waiting"},
+							nil,
+						), nil
+					}
+					return RecoveryPoint(idempotency.RecoveryPointCharged), nil
+				},
+			},
+			phaseFinish(idempotency.RecoveryPointCharged, &charged),
+		}
+
+		var stored entity.IdempotencyKey
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil)
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				stored = *args.Get(1).(*entity.IdempotencyKey)
+			})
+
+		// the first run stops, waiting on the phase
+		err := uc.Run(ctx, &ik, phases...)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, awaiting)
+		assert.Equal(t, 0, charged)
+		assert.Equal(t, idempotency.RecoveryPointAwaitingAction, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeErrPayment, *ik.ResponseCode)
+		assert.Nil(t, ik.LockedAt)
+
+		// the retry picks up from the suspended phase, dropping its response
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(stored)).
+			Return(false, nil)
+
+		suspended = false
+		retry := entity.IdempotencyKey{
+			IdempotencyKey: ik.IdempotencyKey,
+			UserID:         ik.UserID,
+			RequestParams:  jsonRide,
+		}
+		err = uc.Run(ctx, &retry, phases...)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, awaiting)
+		assert.Equal(t, 1, charged)
+		assert.Equal(t, idempotency.RecoveryPointFinished, retry.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *retry.ResponseCode)
+		assert.JSONEq(t, `{"message": "OK"}`, string(retry.ResponseBody))
+	})
+
+	t.Run("New key starts at the first phase", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         int64(gofakeit.Number(1, 1000)),
+		}
+
+		m := getMocksWithTimes(2)
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		var saved idempotency.RecoveryPoint
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil).
+			Run(func(args mock.Arguments) {
+				saved = args.Get(1).(*entity.IdempotencyKey).RecoveryPoint
+			})
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Once().
+			Return(nil)
+
+		var started, other int
+		err := uc.Run(
+			ctx,
+			&ik,
+			phaseFinish(idempotency.RecoveryPointCancelStarted, &started),
+			phaseFinish(idempotency.RecoveryPointStarted, &other),
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointCancelStarted, saved)
+		assert.Equal(t, 1, started)
+		assert.Equal(t, 0, other)
+	})
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyTimeout: 5}
+
+	user := &entity.User{
+		ID:               int64(gofakeit.Number(1, 1000)),
+		Email:            gofakeit.Email(),
+		StripeCustomerID: gofakeit.UUID(),
+	}
+
+	t.Run("Error on GetIdempotencyKey", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+		}
+
+		retErr := errors.New("err GetIdempotencyKey")
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.IdempotencyKey{}, retErr)
+
+		var calls int
+		err := uc.Resume(ctx, &ik, phaseFinish(idempotency.RecoveryPointCreated, &calls))
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("Request in progress", func(t *testing.T) {
+		now := time.Now().UTC()
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
+		}
+
+		retIK := ik
+		retIK.LockedAt = &now
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(retIK, nil)
+
+		var calls int
+		err := uc.Resume(ctx, &ik, phaseFinish(idempotency.RecoveryPointCreated, &calls))
+
+		assert.Equal(t, entity.ErrIdemKeyRequestInProgress, err)
+		assert.Equal(t, 0, calls)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
+	})
+
+	t.Run("No-op on finished recovery point", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
+		}
+
+		// the request got finished in the meantime
+		retIK := ik
+		retIK.RecoveryPoint = idempotency.RecoveryPointFinished
+
+		m := getMocks()
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(retIK, nil)
+
+		var calls int
+		err := uc.Resume(ctx, &ik, phaseFinish(idempotency.RecoveryPointCharged, &calls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
+	})
+
+	t.Run("Success on Resume", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RequestParams:  []byte("{}"),
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
+		}
+
+		// the stored params differ, but they're not checked when resuming
+		retIK := ik
+		retIK.RequestParams = []byte("{\"foo\": \"bar\"}")
+
+		m := getMocksWithTimes(2)
+		uc := New(mockCfg, m.uow, m.idemKey)
+
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(retIK, nil)
+
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		var calls int
+		err := uc.Resume(ctx, &ik, phaseFinish(idempotency.RecoveryPointCharged, &calls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, user, ik.User)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	method := "POST"
+	path := "/"
+	fp := Fingerprint(method, path, []byte(`{"origin_lat": 1.0, "origin_lon": 2.0}`))
+
+	assert.Len(t, fp, 64)
+
+	tests := []struct {
+		desc   string
+		same   bool
+		method string
+		path   string
+		body   string
+	}{
+		{desc: "identical request", same: true, method: method, path: path, body: `{"origin_lat": 1.0, "origin_lon": 2.0}`},
+		{desc: "differently formatted body", same: true, method: method, path: path, body: `{ "origin_lon":2, "origin_lat":1 }`},
+		{desc: "different body", same: false, method: method, path: path, body: `{"origin_lat": 1.0, "origin_lon": 3.0}`},
+		{desc: "extra body fields", same: false, method: method, path: path, body: `{"origin_lat": 1.0, "origin_lon": 2.0, "x": 0}`},
+		{desc: "invalid body", same: false, method: method, path: path, body: `{`},
+		{desc: "different method", same: false, method: "PUT", path: path, body: `{"origin_lat": 1.0, "origin_lon": 2.0}`},
+		{desc: "different path", same: false, method: method, path: "/rides", body: `{"origin_lat": 1.0, "origin_lon": 2.0}`},
+	}
+
+	for _, tc := range tests {
+		other := Fingerprint(tc.method, tc.path, []byte(tc.body))
+		assert.Equal(t, tc.same, fp == other, tc.desc)
+	}
+}
+
+func TestCompareRequest(t *testing.T) {
+	body := []byte(`{"origin_lat": 1.0, "origin_lon": 2.0}`)
+	ik := entity.IdempotencyKey{
+		RequestFingerprint: Fingerprint("POST", "/", body),
+		RequestMethod:      "POST",
+		RequestPath:        "/",
+		RequestParams:      body,
+	}
+
+	tests := []struct {
+		desc string
+		err  error
+		edit func(*entity.IdempotencyKey)
+	}{
+		{desc: "identical request", err: nil, edit: func(*entity.IdempotencyKey) {}},
+		{
+			desc: "stored without fingerprint",
+			err:  nil,
+			edit: func(k *entity.IdempotencyKey) {
+				k.RequestFingerprint = ""
+				k.RequestParams = []byte(`{ "origin_lon":2, "origin_lat":1 }`)
+			},
+		},
+		{
+			desc: "different body",
+			err:  entity.ErrIdemKeyBodyMismatch,
+			edit: func(k *entity.IdempotencyKey) {
+				k.RequestParams = []byte(`{"origin_lat": 1.0, "origin_lon": 3.0}`)
+				k.RequestFingerprint = Fingerprint(k.RequestMethod, k.RequestPath, k.RequestParams)
+			},
+		},
+		{
+			desc: "different method",
+			err:  entity.ErrIdemKeyMethodMismatch,
+			edit: func(k *entity.IdempotencyKey) { k.RequestMethod = "PUT" },
+		},
+		{
+			desc: "different path",
+			err:  entity.ErrIdemKeyPathMismatch,
+			edit: func(k *entity.IdempotencyKey) { k.RequestPath = "/rides" },
+		},
+	}
+
+	for _, tc := range tests {
+		stored := ik
+		tc.edit(&stored)
+		assert.Equal(t, tc.err, CompareRequest(stored, ik), tc.desc)
+	}
+}
@@ -0,0 +1,23 @@
+package usecase
+
+import (
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+)
+
+// JobHandlers work the jobs staged by the use cases. Processes that only stage
+// jobs leave them unset, the enqueuer being the one working them.
+type JobHandlers struct {
+	SendReceipt            jobs.Handler[stagedjob.JobArgReceipt]
+	SendCancellationNotice jobs.Handler[stagedjob.JobArgCancellationNotice]
+	SendWelcome            jobs.Handler[stagedjob.JobArgWelcome]
+}
+
+// NewJobs registers every job staged by the use cases, along with its handler.
+func NewJobs(h JobHandlers) *jobs.Registry {
+	r := jobs.New()
+	jobs.Register(r, stagedjob.JobNameSendReceipt, h.SendReceipt)
+	jobs.Register(r, stagedjob.JobNameSendCancellationNotice, h.SendCancellationNotice)
+	jobs.Register(r, stagedjob.JobNameSendWelcome, h.SendWelcome)
+	return r
+}
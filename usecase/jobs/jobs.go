@@ -0,0 +1,158 @@
+// Package jobs ties the names of staged jobs to the type of their args, so
+// that args are checked as soon as jobs are staged and decoded back into that
+// same type once jobs are worked.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+)
+
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrInvalidJobArgs = errors.New("invalid job args")
+	ErrNoJobHandler   = errors.New("no handler for job")
+)
+
+// Handler works a job given its args.
+type Handler[T any] func(context.Context, T) error
+
+// Validator is implemented by job args having more to check than what decoding
+// them already does.
+type Validator interface {
+	Validate() error
+}
+
+type job struct {
+	// check tells whether the encoded args are valid for the job
+	check func(json.RawMessage) error
+	// run decodes the args and works the job, it's nil for jobs that are only
+	// staged by the process
+	run func(context.Context, json.RawMessage) error
+}
+
+// Registry holds every known job. It's meant to be filled up through Register
+// before being used.
+type Registry struct {
+	jobs map[stagedjob.JobName]job
+}
+
+func New() *Registry {
+	return &Registry{jobs: map[stagedjob.JobName]job{}}
+}
+
+// Register ties the job name to the type of its args and to the handler working
+// it. Processes that stage jobs without working them register a nil handler.
+// It panics if the name is registered twice.
+func Register[T any](r *Registry, name stagedjob.JobName, h Handler[T]) {
+	if _, ok := r.jobs[name]; ok {
+		panic(fmt.Sprintf("jobs: job %v registered twice", name))
+	}
+
+	j := job{
+		check: func(raw json.RawMessage) error {
+			_, err := decode[T](raw)
+			return err
+		},
+	}
+	if h != nil {
+		j.run = func(ctx context.Context, raw json.RawMessage) error {
+			args, err := decode[T](raw)
+			if err != nil {
+				return err
+			}
+			return h(ctx, args)
+		}
+	}
+
+	r.jobs[name] = j
+}
+
+// Names lists the jobs having a handler, the ones the process works.
+func (r *Registry) Names() []stagedjob.JobName {
+	names := make([]stagedjob.JobName, 0, len(r.jobs))
+	for name, j := range r.jobs {
+		if j.run != nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Stage checks the args against the type registered for the job, then saves it
+// through store, which must belong to the transaction the job is staged along
+// with. Unknown jobs and invalid args make it fail, and the transaction with
+// it, so that they never make it to the enqueuer.
+func (r *Registry) Stage(
+	ctx context.Context,
+	store datastore.StagedJob,
+	name stagedjob.JobName,
+	args interface{},
+) (entity.StagedJob, error) {
+	j, ok := r.jobs[name]
+	if !ok {
+		return entity.StagedJob{}, fmt.Errorf("%w: %v", ErrUnknownJob, name)
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return entity.StagedJob{}, fmt.Errorf("%w: %v", ErrInvalidJobArgs, err)
+	}
+
+	if err := j.check(raw); err != nil {
+		return entity.StagedJob{}, fmt.Errorf("job %v: %w", name, err)
+	}
+
+	sj := entity.StagedJob{
+		JobName: name,
+		JobArgs: raw,
+	}
+	if err := store.Save(ctx, &sj); err != nil {
+		return entity.StagedJob{}, err
+	}
+	return sj, nil
+}
+
+// Run decodes the job's args into the type registered for it and works the job
+// with its handler.
+func (r *Registry) Run(ctx context.Context, sj entity.StagedJob) error {
+	j, ok := r.jobs[sj.JobName]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownJob, sj.JobName)
+	}
+	if j.run == nil {
+		return fmt.Errorf("%w: %v", ErrNoJobHandler, sj.JobName)
+	}
+
+	return j.run(ctx, sj.JobArgs)
+}
+
+// decode strictly decodes the args, which must hold nothing but T's fields,
+// validating them as well if T is a Validator.
+func decode[T any](raw json.RawMessage) (T, error) {
+	var args T
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&args); err != nil {
+		return args, fmt.Errorf("%w: %v", ErrInvalidJobArgs, err)
+	}
+
+	if v, ok := interface{}(&args).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return args, fmt.Errorf("%w: %v", ErrInvalidJobArgs, err)
+		}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,191 @@
+//go:build unit
+// +build unit
+
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	jobGreet  stagedjob.JobName = "greet"
+	jobRemind stagedjob.JobName = "remind"
+)
+
+type greetArgs struct {
+	Name string `json:"name"`
+}
+
+func (a greetArgs) Validate() error {
+	if a.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type remindArgs struct {
+	At int64 `json:"at"`
+}
+
+func TestRegister(t *testing.T) {
+	r := New()
+	Register[greetArgs](r, jobGreet, nil)
+
+	assert.Panics(t, func() {
+		Register[remindArgs](r, jobGreet, nil)
+	})
+}
+
+func TestNames(t *testing.T) {
+	r := New()
+	Register(r, jobRemind, func(context.Context, remindArgs) error { return nil })
+	Register[greetArgs](r, "staged_only", nil)
+	Register(r, jobGreet, func(context.Context, greetArgs) error { return nil })
+
+	assert.Equal(t, []stagedjob.JobName{jobGreet, jobRemind}, r.Names())
+	assert.Empty(t, New().Names())
+}
+
+func TestStage(t *testing.T) {
+	ctx := context.Background()
+
+	r := New()
+	Register[greetArgs](r, jobGreet, nil)
+
+	t.Run("Error on unknown job", func(t *testing.T) {
+		store := &mocks.StagedJob{}
+
+		_, err := r.Stage(ctx, store, jobRemind, remindArgs{At: 1})
+
+		assert.ErrorIs(t, err, ErrUnknownJob)
+		store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on invalid args", func(t *testing.T) {
+		for desc, args := range map[string]interface{}{
+			"not encodable":  func() {},
+			"other type":     remindArgs{At: 1},
+			"failing check":  greetArgs{},
+			"not an object":  "jane",
+			"wrong type":     map[string]interface{}{"name": 1},
+			"unknown fields": map[string]interface{}{"name": "jane", "age": 30},
+		} {
+			store := &mocks.StagedJob{}
+
+			_, err := r.Stage(ctx, store, jobGreet, args)
+
+			assert.ErrorIs(t, err, ErrInvalidJobArgs, desc)
+			store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("Error on Save", func(t *testing.T) {
+		retErr := errors.New("err Save")
+
+		store := &mocks.StagedJob{}
+		store.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(retErr)
+
+		_, err := r.Stage(ctx, store, jobGreet, greetArgs{Name: "jane"})
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Success on Stage", func(t *testing.T) {
+		store := &mocks.StagedJob{}
+		store.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*entity.StagedJob).ID = 7
+			})
+
+		sj, err := r.Stage(ctx, store, jobGreet, greetArgs{Name: "jane"})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), sj.ID)
+		assert.Equal(t, jobGreet, sj.JobName)
+		assert.JSONEq(t, `{"name": "jane"}`, string(sj.JobArgs))
+		store.AssertExpectations(t)
+	})
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	var greeted []greetArgs
+	retErr := errors.New("err handler")
+
+	r := New()
+	Register(r, jobGreet, func(_ context.Context, args greetArgs) error {
+		greeted = append(greeted, args)
+		if args.Name == "fail" {
+			return retErr
+		}
+		return nil
+	})
+	Register[remindArgs](r, jobRemind, nil)
+
+	tests := []struct {
+		desc   string
+		sj     entity.StagedJob
+		err    error
+		called bool
+	}{
+		{
+			desc: "unknown job",
+			sj:   entity.StagedJob{JobName: "unknown", JobArgs: json.RawMessage(`{}`)},
+			err:  ErrUnknownJob,
+		},
+		{
+			desc: "no handler",
+			sj:   entity.StagedJob{JobName: jobRemind, JobArgs: json.RawMessage(`{"at": 1}`)},
+			err:  ErrNoJobHandler,
+		},
+		{
+			desc: "malformed args",
+			sj:   entity.StagedJob{JobName: jobGreet, JobArgs: json.RawMessage(`{"name": "jane", "at": 1}`)},
+			err:  ErrInvalidJobArgs,
+		},
+		{
+			desc: "invalid args",
+			sj:   entity.StagedJob{JobName: jobGreet, JobArgs: json.RawMessage(`{"name": ""}`)},
+			err:  ErrInvalidJobArgs,
+		},
+		{
+			desc:   "handler error",
+			sj:     entity.StagedJob{JobName: jobGreet, JobArgs: json.RawMessage(`{"name": "fail"}`)},
+			err:    retErr,
+			called: true,
+		},
+		{
+			desc:   "success",
+			sj:     entity.StagedJob{JobName: jobGreet, JobArgs: json.RawMessage(`{"name": "jane"}`)},
+			called: true,
+		},
+	}
+
+	for _, tc := range tests {
+		greeted = nil
+
+		err := r.Run(ctx, tc.sj)
+
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.desc)
+		} else {
+			assert.NoError(t, err, tc.desc)
+		}
+		assert.Equal(t, tc.called, len(greeted) == 1, tc.desc)
+	}
+}
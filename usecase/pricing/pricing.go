@@ -0,0 +1,68 @@
+package pricing
+
+import (
+	"math"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+// earthRadius is the Earth's mean radius in km.
+const earthRadius = 6371.0
+
+// Fare is the price of a ride, the amount is given in the currency's smallest
+// unit (e.g., cents for USD).
+type Fare struct {
+	Amount   int64
+	Currency string
+}
+
+type pricer struct {
+	baseFare int64
+	perKm    int64
+	minFare  int64
+	currency string
+}
+
+// Pricer computes how much a ride costs.
+type Pricer interface {
+	Price(entity.Ride) Fare
+}
+
+func New(cfg config.Config) Pricer {
+	return &pricer{
+		baseFare: cfg.RideBaseFare,
+		perKm:    cfg.RidePerKmFare,
+		minFare:  cfg.RideMinFare,
+		currency: cfg.RideCurrency,
+	}
+}
+
+// Price charges a base fare plus a rate for each km flown from the ride's
+// origin to its target, but never less than the minimum fare.
+func (p *pricer) Price(rd entity.Ride) Fare {
+	km := Distance(rd.OriginLat, rd.OriginLon, rd.TargetLat, rd.TargetLon)
+
+	amount := p.baseFare + int64(math.Round(km*float64(p.perKm)))
+	if amount < p.minFare {
+		amount = p.minFare
+	}
+
+	return Fare{Amount: amount, Currency: p.currency}
+}
+
+// Distance returns the great-circle distance in km between two points given
+// by their latitudes and longitudes, as calculated by the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,58 @@
+//go:build unit
+// +build unit
+
+package pricing
+
+import (
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	t.Run("Same point", func(t *testing.T) {
+		assert.Zero(t, Distance(37.7749, -122.4194, 37.7749, -122.4194))
+	})
+
+	t.Run("One degree along the equator", func(t *testing.T) {
+		assert.InDelta(t, 111.195, Distance(0, 0, 0, 1), 0.001)
+	})
+
+	t.Run("San Francisco to Los Angeles", func(t *testing.T) {
+		d := Distance(37.7749, -122.4194, 34.0522, -118.2437)
+		assert.InDelta(t, 559.1, d, 0.1)
+		// it doesn't matter which way the ride goes
+		assert.InDelta(t, d, Distance(34.0522, -118.2437, 37.7749, -122.4194), 1e-9)
+	})
+}
+
+func TestPrice(t *testing.T) {
+	p := New(config.Config{
+		RideBaseFare:  500,
+		RideCurrency:  "usd",
+		RideMinFare:   2000,
+		RidePerKmFare: 150,
+	})
+
+	t.Run("Minimum fare", func(t *testing.T) {
+		f := p.Price(entity.Ride{})
+		assert.Equal(t, Fare{Amount: 2000, Currency: "usd"}, f)
+	})
+
+	t.Run("Per km fare", func(t *testing.T) {
+		// 500 + round(111.195 * 150)
+		f := p.Price(entity.Ride{TargetLon: 1.0})
+		assert.Equal(t, Fare{Amount: 17179, Currency: "usd"}, f)
+	})
+
+	t.Run("Rounded to the nearest unit", func(t *testing.T) {
+		p := New(config.Config{RidePerKmFare: 1, RideCurrency: "eur"})
+
+		// ~111.195 km rounds down, while ~166.793 km rounds up
+		assert.Equal(t, int64(111), p.Price(entity.Ride{TargetLon: 1.0}).Amount)
+		assert.Equal(t, int64(167), p.Price(entity.Ride{TargetLon: 1.5}).Amount)
+		assert.Equal(t, "eur", p.Price(entity.Ride{}).Currency)
+	})
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+)
+
+type reaper struct {
+	cfg config.Config
+	uow uow.UnitOfWork
+}
+
+type Reaper interface {
+	Reap(context.Context) (int, error)
+}
+
+func NewReaper(cfg config.Config, uow uow.UnitOfWork) Reaper {
+	return &reaper{
+		cfg: cfg,
+		uow: uow,
+	}
+}
+
+// Reap deletes finished idempotency keys older than the configured retention
+// window, returning how many of them were removed. Keys that aren't finished
+// yet are never touched, no matter how old they are.
+func (r *reaper) Reap(ctx context.Context) (int, error) {
+	retention := time.Duration(r.cfg.IdemKeyRetention) * time.Hour
+	before := time.Now().UTC().Add(-1 * retention)
+
+	var total int
+	for {
+		n, err := r.reapBatch(ctx, before)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		// a partial batch means there's nothing left to be reaped
+		if n < r.cfg.WorkerBatch {
+			return total, nil
+		}
+	}
+}
+
+// reapBatch deletes a single batch of keys inside its own transaction, so that
+// row locks are only held for a short while.
+func (r *reaper) reapBatch(ctx context.Context, before time.Time) (int, error) {
+	var n int
+
+	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		var err error
+		n, err = uows.IdempotencyKeys().DeleteWhere(
+			ctx,
+			datastore.IdemKeyFinishedBefore(before),
+			datastore.IdemKeyWithLimit(r.cfg.WorkerBatch),
+		)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
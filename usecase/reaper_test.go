@@ -0,0 +1,71 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReap(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := config.Config{IdemKeyRetention: 72, WorkerBatch: 2}
+
+	t.Run("Error on DeleteWhere", func(t *testing.T) {
+		retErr := errors.New("err DeleteWhere")
+
+		m := getMocks()
+		uc := NewReaper(mockCfg, m.uow)
+
+		m.idemKey.On("DeleteWhere", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(0, retErr)
+
+		n, err := uc.Reap(ctx)
+
+		assert.Equal(t, retErr, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("Nothing to reap", func(t *testing.T) {
+		m := getMocks()
+		uc := NewReaper(mockCfg, m.uow)
+
+		m.idemKey.On("DeleteWhere", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(0, nil)
+
+		n, err := uc.Reap(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		m.idemKey.AssertNumberOfCalls(t, "DeleteWhere", 1)
+	})
+
+	t.Run("Success on Reap in batches", func(t *testing.T) {
+		m := getMocksWithTimes(3)
+		uc := NewReaper(mockCfg, m.uow)
+
+		// two full batches followed by a partial one
+		m.idemKey.On("DeleteWhere", ctx, mock.Anything, mock.Anything).
+			Twice().
+			Return(2, nil)
+
+		m.idemKey.On("DeleteWhere", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(1, nil)
+
+		n, err := uc.Reap(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		m.idemKey.AssertNumberOfCalls(t, "DeleteWhere", 3)
+	})
+}
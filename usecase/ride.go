@@ -5,315 +5,628 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/labstack/gommon/log"
-	"github.com/stripe/stripe-go/v72"
-	"github.com/stripe/stripe-go/v72/charge"
 
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
-	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/pricing"
 )
 
 type ride struct {
-	cfg config.Config
-	uow uow.UnitOfWork
-	iks datastore.IdempotencyKey
+	cfg      config.Config
+	idem     idemkey.Runner
+	pricer   pricing.Pricer
+	rides    datastore.Ride
+	payments payment.Provider
+	jobs     *jobs.Registry
+}
+
+// actionRequired is the response body for charges waiting on the customer to
+// take action, carrying the payment intent's client secret.
+type actionRequired struct {
+	Message      string `json:"message"`
+	ClientSecret string `json:"client_secret"`
 }
 
 type Ride interface {
 	Create(context.Context, *entity.IdempotencyKey, *entity.Ride) error
+	Complete(context.Context, *entity.IdempotencyKey) error
+	Cancel(ctx context.Context, ik *entity.IdempotencyKey, rideID int64) error
+	Get(ctx context.Context, userID, rideID int64) (entity.Ride, error)
+	List(ctx context.Context, userID int64, cursor string, limit int) (data.Page[entity.Ride], error)
 }
 
-func NewRide(cfg config.Config, uow uow.UnitOfWork, iks datastore.IdempotencyKey) Ride {
-	// setup Stripe's key
-	stripe.Key = cfg.StripeKey
-
+func NewRide(
+	cfg config.Config,
+	idem idemkey.Runner,
+	pricer pricing.Pricer,
+	rides datastore.Ride,
+	payments payment.Provider,
+	jobs *jobs.Registry,
+) Ride {
 	return &ride{
-		cfg: cfg,
-		uow: uow,
-		iks: iks,
+		cfg:      cfg,
+		idem:     idem,
+		pricer:   pricer,
+		rides:    rides,
+		payments: payments,
+		jobs:     jobs,
 	}
 }
 
 func (r *ride) Create(ctx context.Context, ik *entity.IdempotencyKey, rd *entity.Ride) error {
-	err := r.setIdempotencyKey(ctx, ik)
-	if err != nil {
+	return r.idem.Run(ctx, ik, r.phases(rd)...)
+}
+
+// Cancel cancels one of the user's rides, getting its charge refunded.
+func (r *ride) Cancel(ctx context.Context, ik *entity.IdempotencyKey, rideID int64) error {
+	return r.idem.Run(ctx, ik, r.cancelPhases(rideID)...)
+}
+
+// Complete pushes an abandoned request through to the end, picking up from
+// its last recovery point and relying on the request params stored along with
+// the idempotency key. The key's User must be set by the caller.
+func (r *ride) Complete(ctx context.Context, ik *entity.IdempotencyKey) error {
+	// cancellations carry the ride's id in their path rather than in the body
+	if rideID, ok := canceledRideID(ik.RequestPath); ok {
+		return r.idem.Resume(ctx, ik, r.cancelPhases(rideID)...)
+	}
+
+	var params rideParams
+	if err := json.Unmarshal(ik.RequestParams, &params); err != nil {
 		return err
 	}
 
-	defer func() {
-		// If we're leaving under an error condition, try to unlock the idempotency
-		// key right away so that another request can try again.
-		if err != nil && ik != nil {
-			r.unlockIdempotencyKey(ctx, ik)
-		}
-	}()
-
-	for {
-		switch ik.RecoveryPoint {
-		case idempotency.RecoveryPointStarted:
-			err = r.createRide(ctx, ik, rd)
-			if err != nil {
-				return err
-			}
-
-		case idempotency.RecoveryPointCreated:
-			err = r.createCharge(ctx, ik, rd)
-			if err != nil {
-				return err
-			}
-
-		case idempotency.RecoveryPointCharged:
-			err = r.sendReceipt(ctx, ik)
-			if err != nil {
-				return err
-			}
-
-		case idempotency.RecoveryPointFinished:
-			return nil
-
-		default:
-			return entity.ErrIdemKeyUnknownRecoveryPoint
-		}
+	rd, err := params.ride()
+	if err != nil {
+		return err
 	}
+
+	return r.idem.Resume(ctx, ik, r.phases(rd)...)
 }
 
-func (r *ride) setIdempotencyKey(ctx context.Context, ik *entity.IdempotencyKey) error {
-	var err error
-	var key entity.IdempotencyKey
-
-	// Our first atomic phase to create or update an idempotency key.
-	//
-	// A key concept here is that if two requests try to insert or update within
-	// close proximity, one of the two will be aborted by Postgres because we're
-	// using a transaction with SERIALIZABLE isolation level. It may not look
-	// it, but this code is safe from races.
-	err = r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
-		key, err = uows.IdempotencyKeys().FindOne(
-			ctx,
-			datastore.IdemKeyWithKey(ik.IdempotencyKey),
-			datastore.IdemKeyWithUserID(ik.UserID),
-		)
-		if err != nil {
-			if errors.Is(err, data.ErrRecordNotFound) {
-				now := time.Now().UTC()
-				ik.LastRunAt = now
-				ik.LockedAt = &now
-				ik.RecoveryPoint = idempotency.RecoveryPointStarted
-				err = uows.IdempotencyKeys().Save(ctx, ik)
-			}
-			return err
-		}
+var errInvalidRideParams = errors.New("invalid ride params")
 
-		// Unmarshal the JSON returned from datastore, so we're able to
-		// properly compare it against the request.
-		rd1, rd2 := entity.Ride{}, entity.Ride{}
-		if err := json.Unmarshal(key.RequestParams, &rd1); err != nil {
-			return entity.ErrIdemKeyParamsMismatch
-		}
+// rideParams are the params rides are requested with. The body stored along
+// with the key is whatever the client sent, so nothing else is taken from it
+// when completing a request.
+type rideParams struct {
+	OriginLat *float64 `json:"origin_lat"`
+	OriginLon *float64 `json:"origin_lon"`
+	TargetLat *float64 `json:"target_lat"`
+	TargetLon *float64 `json:"target_lon"`
+}
 
-		if err := json.Unmarshal(ik.RequestParams, &rd2); err != nil {
-			return entity.ErrIdemKeyParamsMismatch
+// ride checks the params against the same ranges the API does, returning a
+// new ride from them.
+func (p rideParams) ride() (*entity.Ride, error) {
+	for _, c := range []struct {
+		name  string
+		value *float64
+		limit float64
+	}{
+		{"origin_lat", p.OriginLat, 90},
+		{"origin_lon", p.OriginLon, 180},
+		{"target_lat", p.TargetLat, 90},
+		{"target_lon", p.TargetLon, 180},
+	} {
+		if c.value == nil || *c.value < -c.limit || *c.value > c.limit {
+			return nil, fmt.Errorf("%w: %v", errInvalidRideParams, c.name)
 		}
+	}
 
-		// Programs sending multiple requests with different parameters but the
-		// same idempotency key is a bug.
-		if !reflect.DeepEqual(rd1, rd2) {
-			return entity.ErrIdemKeyParamsMismatch
-		}
+	return &entity.Ride{
+		OriginLat: *p.OriginLat,
+		OriginLon: *p.OriginLon,
+		TargetLat: *p.TargetLat,
+		TargetLon: *p.TargetLon,
+	}, nil
+}
 
-		// Only acquire a lock if the key is unlocked or its lock has expired
-		// because it was long enough ago.
-		timeout := time.Duration(r.cfg.IdemKeyTimeout) * time.Second
-		if key.LockedAt != nil && key.LockedAt.After(time.Now().UTC().Add(-1*timeout)) {
-			return entity.ErrIdemKeyRequestInProgress
-		}
+// Get fetches one of the user's rides. Rides belonging to someone else are
+// reported as not found, so that their existence isn't disclosed.
+func (r *ride) Get(ctx context.Context, userID, rideID int64) (entity.Ride, error) {
+	rd, err := r.rides.FindOne(ctx, datastore.RideWithID(rideID), datastore.RideWithUserID(userID))
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return rd, entity.ErrNotFound
+	}
+	return rd, err
+}
 
-		// Lock the key and update latest run unless the request is already
-		// finished.
-		if key.RecoveryPoint != idempotency.RecoveryPointFinished {
-			now := time.Now().UTC()
-			key.LastRunAt = now
-			key.LockedAt = &now
-			err = uows.IdempotencyKeys().Update(ctx, &key)
-			return err
-		}
-		// update the reference with data from persistence layer
-		*ik = key
+// List returns a page of up to limit user's rides, newest first, starting
+// right after the cursor (or from the newest one if it's empty).
+func (r *ride) List(ctx context.Context, userID int64, cursor string, limit int) (data.Page[entity.Ride], error) {
+	pr := data.PageRequest{Cursor: cursor, Limit: limit, OrderBy: []data.Order{data.Desc("id")}}
+	return r.rides.FindPage(ctx, pr, datastore.RideWithUserID(userID))
+}
 
-		return nil
-	})
+// phases lists the atomic phases a ride goes through, from its creation until
+// the receipt is sent. Charges needing the customer to take action wait at
+// RecoveryPointAwaitingAction in between.
+func (r *ride) phases(rd *entity.Ride) []idemkey.Phase {
+	return []idemkey.Phase{
+		{
+			RecoveryPoint: idempotency.RecoveryPointStarted,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, ik *entity.IdempotencyKey) (idemkey.Result, error) {
+				return r.createRide(ctx, uows, ik, rd)
+			},
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointCreated,
+			Run:           r.createCharge,
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointAwaitingAction,
+			Run:           r.confirmCharge,
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointCharged,
+			Run:           r.sendReceipt,
+		},
+	}
+}
 
-	return err
+// cancelPhases lists the atomic phases a ride goes through when canceled, from
+// the refund of its charge until the cancellation notice is sent.
+func (r *ride) cancelPhases(rideID int64) []idemkey.Phase {
+	return []idemkey.Phase{
+		{
+			RecoveryPoint: idempotency.RecoveryPointCancelStarted,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, ik *entity.IdempotencyKey) (idemkey.Result, error) {
+				return r.refundRide(ctx, uows, ik, rideID)
+			},
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointRefunded,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, ik *entity.IdempotencyKey) (idemkey.Result, error) {
+				return r.sendCancellationNotice(ctx, uows, ik, rideID)
+			},
+		},
+	}
 }
 
-func (r *ride) createRide(ctx context.Context, ik *entity.IdempotencyKey, rd *entity.Ride) error {
-	oip := originip.FromCtx(ctx)
+var cancelPathRegexp = regexp.MustCompile(`^/rides/(\d+)/cancel(\?.*)?$`)
 
-	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
-		rd.IdempotencyKeyID = &ik.ID
-		rd.UserID = ik.UserID
-		err := uows.Rides().Save(ctx, rd)
-		if err != nil {
-			return err
-		}
+// canceledRideID extracts the id of the ride being canceled from the path of
+// a cancellation request, telling whether the path is one.
+func canceledRideID(path string) (int64, bool) {
+	m := cancelPathRegexp.FindStringSubmatch(path)
+	if m == nil {
+		return 0, false
+	}
 
-		// in the same transaction insert an audit record for what happened
-		ar := &entity.AuditRecord{
-			Action:       audit.ActionCreateRide,
-			CreatedAt:    time.Now().UTC(),
-			Data:         ik.RequestParams,
-			OriginIP:     oip.IP,
-			ResourceID:   rd.ID,
-			ResourceType: audit.ResourceTypeRide,
-			UserID:       ik.UserID,
-		}
-		err = uows.AuditRecords().Save(ctx, ar)
-		if err != nil {
-			return err
-		}
+	id, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
 
-		ik.RecoveryPoint = idempotency.RecoveryPointCreated
-		return uows.IdempotencyKeys().Update(ctx, ik)
-	})
+func (r *ride) createRide(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	rd *entity.Ride,
+) (idemkey.Result, error) {
+	// save a copy, so that nothing leaks out if the transaction is retried
+	ride := *rd
+	ride.IdempotencyKeyID = &ik.ID
+	ride.UserID = ik.UserID
+
+	// the fare is set once and for all, so that the amount charged and the
+	// one in the receipt are always the same
+	fare := r.pricer.Price(ride)
+	ride.Amount = fare.Amount
+	ride.Currency = fare.Currency
+
+	err := uows.Rides().Save(ctx, &ride)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	// in the same transaction insert an audit record for what happened
+	return auditRide(
+		ctx, uows, ik, ride.ID,
+		audit.ActionCreateRide,
+		idemkey.RecoveryPoint(idempotency.RecoveryPointCreated),
+		map[string]json.RawMessage{"request_params": ik.RequestParams},
+	)
 }
 
-func (r *ride) createCharge(ctx context.Context, ik *entity.IdempotencyKey, rd *entity.Ride) error {
-	var err error
-	var ride entity.Ride
-
-	err = r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
-		handleStripeErr := func(resCode *idempotency.ResponseCode, resBody *idempotency.ResponseBody) {
-			ik.LockedAt = nil
-			ik.ResponseCode = resCode
-			ik.ResponseBody = resBody
-			// short-circuit to the final state
-			ik.RecoveryPoint = idempotency.RecoveryPointFinished
-			err = uows.IdempotencyKeys().Update(ctx, ik)
-			if err != nil {
-				log.Errorf("error updating idem key after stripe error: %v", err)
-			}
-		}
-
-		// check if we're restarting from a Recovery Point and retrieve a ride from db
-		if rd == nil {
-			ride, err = uows.Rides().FindOne(ctx, datastore.RideWithIdemKeyID(ik.ID))
-			if err != nil {
-				return err
-			}
-		} else {
-			ride = *rd
-		}
+func (r *ride) createCharge(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+) (idemkey.Result, error) {
+	// retrieve a ride from db, it might've been created by a previous request
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithIdemKeyID(ik.ID))
+	if err != nil {
+		return nil, err
+	}
 
-		// Pass through our own unique ID rather than the value transmitted
-		// to us so that we can guarantee uniqueness to Stripe across all
-		// Rocket Rides accounts.
-		stripeIK := fmt.Sprintf("go-rocket-ride-%v", ik.ID)
-		customerID := ik.User.StripeCustomerID
-
-		// Rocket Rides is still a new service, so during our prototype phase
-		// we're going to give $20 fixed-cost rides to everyone, regardless of
-		// distance. We'll implement a better algorithm later to better
-		// represent the cost in time and jetfuel on the part of our pilots.
-		params := &stripe.ChargeParams{
-			Params:      stripe.Params{IdempotencyKey: &stripeIK},
-			Amount:      stripe.Int64(2000),
-			Currency:    stripe.String(string(stripe.CurrencyUSD)),
-			Customer:    &customerID,
-			Description: stripe.String(fmt.Sprintf("Charge for ride %v", ride.ID)),
+	// Pass through our own unique ID rather than the value transmitted
+	// to us so that we can guarantee uniqueness to Stripe across all
+	// Rocket Rides accounts.
+	pi, err := r.payments.CreatePaymentIntent(ctx, payment.PaymentIntentParams{
+		IdempotencyKey: fmt.Sprintf("go-rocket-ride-%v", ik.ID),
+		Amount:         ride.Amount,
+		Currency:       ride.Currency,
+		Customer:       ik.User.StripeCustomerID,
+		Description:    fmt.Sprintf("Charge for ride %v", ride.ID),
+	})
+	if err != nil {
+		// Errors coming from the processor are definitive, so short-circuit
+		// the request to its final state and store the error as its response.
+		if res, ok := paymentErrorResponse(err); ok {
+			return auditRide(ctx, uows, ik, ride.ID, audit.ActionChargeFailed, res, map[string]string{
+				"error": err.Error(),
+			})
 		}
 
-		c, err := charge.New(params)
-		if err != nil {
-			if stripeErr, ok := err.(*stripe.Error); ok {
-				var resCode idempotency.ResponseCode
-				var resBody idempotency.ResponseBody
-				defer handleStripeErr(&resCode, &resBody)
-
-				if cardErr, ok := stripeErr.Err.(*stripe.CardError); ok {
-					resCode = idempotency.ResponseCodeErrPayment
-					resBody = idempotency.ResponseBody{Message: entity.ErrPaymentProvider.Error()}
+		log.Errorf("payment request error: %v", err)
+		return nil, err
+	}
 
-					log.Errorf("stripe card error: %v", cardErr.Error())
-					return entity.ErrPaymentProvider
-				}
+	return r.settleCharge(ctx, uows, ik, ride, pi)
+}
 
-				resCode = idempotency.ResponseCodeErrPaymentGeneric
-				resBody = idempotency.ResponseBody{Message: entity.ErrPaymentProviderGeneric.Error()}
+// confirmCharge checks on a payment intent that was waiting on the customer,
+// once the request is retried with the same idempotency key or the completer
+// gets to it. Intents still requiring action after IdemKeyActionTimeout are
+// canceled, so that the request finishes as failed and its key can be reaped
+// instead of waiting forever.
+func (r *ride) confirmCharge(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+) (idemkey.Result, error) {
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithIdemKeyID(ik.ID))
+	if err != nil {
+		return nil, err
+	}
 
-				log.Errorf("stripe api error: %v", stripeErr.Error())
-				return entity.ErrPaymentProviderGeneric
-			}
+	if ride.StripePaymentIntentID == nil {
+		return nil, entity.ErrRideNotCharged
+	}
 
-			log.Errorf("stripe api request error: %v", err)
-			return err
-		}
+	pi, err := r.payments.GetPaymentIntent(ctx, *ride.StripePaymentIntentID)
+	if err != nil {
+		// unlike creating it, failing to look the intent up changes nothing,
+		// so the request is left to be retried
+		log.Errorf("payment request error: %v", err)
+		return nil, err
+	}
 
-		ride.StripeChargeID = &c.ID
-		log.Debugf("stripe charge id: %v", c.ID)
-		err = uows.Rides().Update(ctx, &ride)
+	timeout := time.Duration(r.cfg.IdemKeyActionTimeout) * time.Hour
+	if pi.Status == payment.PaymentIntentRequiresAction && ik.CreatedAt.Before(time.Now().UTC().Add(-1*timeout)) {
+		pi, err = r.payments.CancelPaymentIntent(ctx, payment.CancelPaymentIntentParams{
+			IdempotencyKey: fmt.Sprintf("go-rocket-ride-expire-%v", ik.ID),
+			ID:             pi.ID,
+		})
 		if err != nil {
-			return err
+			// e.g. the customer took action in the meantime, which is found
+			// out once the request is retried
+			log.Errorf("payment request error: %v", err)
+			return nil, err
 		}
+	}
 
-		ik.RecoveryPoint = idempotency.RecoveryPointCharged
-		return uows.IdempotencyKeys().Update(ctx, ik)
-	})
-	return err
+	return r.settleCharge(ctx, uows, ik, ride, pi)
 }
 
-func (r *ride) sendReceipt(ctx context.Context, ik *entity.IdempotencyKey) error {
+// settleCharge stores the payment intent on the ride and moves the request on
+// according to the intent's status.
+func (r *ride) settleCharge(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	ride entity.Ride,
+	pi payment.PaymentIntent,
+) (idemkey.Result, error) {
+	status := string(pi.Status)
+	ride.StripePaymentIntentID = &pi.ID
+	ride.StripePaymentIntentStatus = &status
+	log.Debugf("payment intent id: %v, status: %v", pi.ID, pi.Status)
+
+	details := map[string]string{
+		"stripe_payment_intent_id":     pi.ID,
+		"stripe_payment_intent_status": status,
+	}
+
+	var (
+		action audit.Action
+		res    idemkey.Result
+	)
+	switch pi.Status {
+	case payment.PaymentIntentSucceeded:
+		ride.StripeChargeID = &pi.ChargeID
+		details["stripe_charge_id"] = pi.ChargeID
+		action = audit.ActionChargeRide
+		res = idemkey.RecoveryPoint(idempotency.RecoveryPointCharged)
+	case payment.PaymentIntentRequiresAction:
+		action = audit.ActionChargePending
+		// The client gets what it needs to have the customer take action,
+		// then retries the request with the same key to pick up from here.
+		res = idemkey.Suspend(
+			idempotency.RecoveryPointAwaitingAction,
+			idempotency.ResponseCodeErrPayment,
+			actionRequired{Message: entity.ErrPaymentActionRequired.Error(), ClientSecret: pi.ClientSecret},
+			nil,
+		)
+	case payment.PaymentIntentProcessing:
+		action = audit.ActionChargePending
+		res = idemkey.Suspend(
+			idempotency.RecoveryPointAwaitingAction,
+			idempotency.ResponseCodeErrPaymentGeneric,
+			idempotency.Message{Message: entity.ErrPaymentProcessing.Error()},
+			nil,
+		)
+	case payment.PaymentIntentRequiresPaymentMethod, payment.PaymentIntentCanceled:
+		// the customer failed to take action or the card was declined
+		action = audit.ActionChargeFailed
+		res = idemkey.Response(
+			idempotency.ResponseCodeErrPayment,
+			idempotency.Message{Message: entity.ErrPaymentProvider.Error()},
+			nil,
+		)
+	default:
+		return nil, fmt.Errorf("%w: unexpected payment intent status %v", entity.ErrPaymentProviderGeneric, pi.Status)
+	}
+
+	err := uows.Rides().Update(ctx, &ride)
+	if err != nil {
+		return nil, err
+	}
+
+	return auditRide(ctx, uows, ik, ride.ID, action, res, details)
+}
+
+func (r *ride) sendReceipt(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+) (idemkey.Result, error) {
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithIdemKeyID(ik.ID))
+	if err != nil {
+		return nil, err
+	}
+
 	// Send a receipt asynchronously by adding an entry to the staged_jobs
 	// table. By funneling the job through Postgres, we make this
 	// operation transaction-safe.
-	err := r.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
-		jobArgs := stagedjob.JobArgReceipt{
-			Amount:   int64(20),
-			Currency: "usd",
-			UserID:   ik.UserID,
-		}
+	jobArgs := stagedjob.JobArgReceipt{
+		Amount:   ride.Amount,
+		Currency: ride.Currency,
+		RideID:   ride.ID,
+		UserID:   ik.UserID,
+	}
 
-		args, err := json.Marshal(jobArgs)
-		if err != nil {
-			return err
-		}
+	sj, err := r.jobs.Stage(ctx, uows.StagedJobs(), stagedjob.JobNameSendReceipt, jobArgs)
+	if err != nil {
+		return nil, err
+	}
 
-		sj := &entity.StagedJob{
-			JobName: stagedjob.JobNameSendReceipt,
-			JobArgs: args,
+	// reply with the ride as it's been stored
+	headers := idempotency.ResponseHeaders{
+		"Location": fmt.Sprintf("/rides/%v", ride.ID),
+	}
+
+	return auditRide(
+		ctx, uows, ik, ride.ID,
+		audit.ActionStageReceipt,
+		idemkey.Response(idempotency.ResponseCodeOK, ride, headers),
+		map[string]int64{"staged_job_id": sj.ID},
+	)
+}
+
+func (r *ride) refundRide(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	rideID int64,
+) (idemkey.Result, error) {
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithID(rideID), datastore.RideWithUserID(ik.UserID))
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return rejectCancel(ctx, uows, ik, rideID, idempotency.ResponseCodeNotFound, entity.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Rides can't be canceled twice, nor before they're charged since there'd
+	// be nothing to refund.
+	switch {
+	case ride.CanceledAt != nil:
+		return r.recordRefund(ctx, uows, ik, ride)
+	case ride.StripeChargeID == nil:
+		return rejectCancel(ctx, uows, ik, rideID, idempotency.ResponseCodeConflict, entity.ErrRideNotCharged)
+	}
+
+	re, err := r.payments.Refund(ctx, refundParams(ride))
+	if err != nil {
+		if res, ok := paymentErrorResponse(err); ok {
+			return auditRide(ctx, uows, ik, ride.ID, audit.ActionRefundFailed, res, map[string]string{
+				"error": err.Error(),
+			})
 		}
-		err = uows.StagedJobs().Save(ctx, sj)
-		if err != nil {
-			return err
+
+		log.Errorf("payment request error: %v", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	ride.CanceledAt = &now
+	ride.StripeRefundID = &re.ID
+	log.Debugf("refund id: %v", re.ID)
+	err = uows.Rides().Update(ctx, &ride)
+	if err != nil {
+		return nil, err
+	}
+
+	// in the same transaction insert an audit record for what happened
+	return auditRide(
+		ctx, uows, ik, ride.ID,
+		audit.ActionCancelRide,
+		idemkey.RecoveryPoint(idempotency.RecoveryPointRefunded),
+		map[string]string{"stripe_refund_id": re.ID},
+	)
+}
+
+// refundParams refunds the ride's charge. The key is derived from the ride
+// rather than from the idempotency key, so that Stripe refunds a ride only once
+// even if it's canceled by two requests at the same time.
+func refundParams(ride entity.Ride) payment.RefundParams {
+	return payment.RefundParams{
+		IdempotencyKey: fmt.Sprintf("go-rocket-ride-refund-%v", ride.ID),
+		Charge:         *ride.StripeChargeID,
+	}
+}
+
+// recordRefund handles the cancellation of a ride that's already canceled,
+// which is rejected unless it's the one that made the refund. The refund's
+// webhook may get the ride canceled before the cancellation does, if the phase
+// that made the refund is retried. Then, as long as no cancellation has been
+// recorded for the ride, Stripe replays the refund made with the ride's key:
+// if it's the one the ride holds, the cancellation goes on as if it had got
+// there first.
+func (r *ride) recordRefund(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	ride entity.Ride,
+) (idemkey.Result, error) {
+	if ride.StripeRefundID == nil || ride.StripeChargeID == nil {
+		return rejectCancel(ctx, uows, ik, ride.ID, idempotency.ResponseCodeConflict, entity.ErrRideAlreadyCanceled)
+	}
+
+	n, err := uows.AuditRecords().Count(
+		ctx,
+		datastore.AuditRecordWithResourceType(audit.ResourceTypeRide),
+		datastore.AuditRecordWithResourceID(ride.ID),
+		datastore.AuditRecordWithAction(audit.ActionCancelRide),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return rejectCancel(ctx, uows, ik, ride.ID, idempotency.ResponseCodeConflict, entity.ErrRideAlreadyCanceled)
+	}
+
+	re, err := r.payments.Refund(ctx, refundParams(ride))
+	if err != nil {
+		// e.g. charges refunded through Stripe's dashboard can't be refunded
+		// once again
+		if _, ok := paymentErrorResponse(err); !ok {
+			log.Errorf("payment request error: %v", err)
+			return nil, err
 		}
+	}
+	if err != nil || re.ID != *ride.StripeRefundID {
+		return rejectCancel(ctx, uows, ik, ride.ID, idempotency.ResponseCodeConflict, entity.ErrRideAlreadyCanceled)
+	}
 
-		resCode := idempotency.ResponseCodeOK
-		resBody := idempotency.ResponseBody{Message: "OK"}
+	return auditRide(
+		ctx, uows, ik, ride.ID,
+		audit.ActionCancelRide,
+		idemkey.RecoveryPoint(idempotency.RecoveryPointRefunded),
+		map[string]string{"stripe_refund_id": re.ID},
+	)
+}
 
-		ik.LockedAt = nil
-		ik.ResponseCode = &resCode
-		ik.ResponseBody = &resBody
-		ik.RecoveryPoint = idempotency.RecoveryPointFinished
-		return uows.IdempotencyKeys().Update(ctx, ik)
+// rejectCancel finishes a cancellation that can't go through, responding with
+// the given code and error.
+func rejectCancel(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	rideID int64,
+	code idempotency.ResponseCode,
+	reason error,
+) (idemkey.Result, error) {
+	res := idemkey.Response(code, idempotency.Message{Message: reason.Error()}, nil)
+	return auditRide(ctx, uows, ik, rideID, audit.ActionCancelRejected, res, map[string]string{
+		"error": reason.Error(),
 	})
-	return err
 }
 
-func (r *ride) unlockIdempotencyKey(ctx context.Context, ik *entity.IdempotencyKey) {
-	ik.LockedAt = nil
-	err := r.iks.Update(ctx, ik)
+// auditRide saves the audit record of an action taken on a ride as its request
+// moves on as told by res.
+func auditRide(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	rideID int64,
+	action audit.Action,
+	res idemkey.Result,
+	details interface{},
+) (idemkey.Result, error) {
+	ar := entity.AuditRecord{
+		Action:       action,
+		ResourceID:   rideID,
+		ResourceType: audit.ResourceTypeRide,
+	}
+	return idemkey.Audit(ctx, uows, ik, res, ar, details)
+}
+
+// paymentErrorResponse turns errors the processor turned calls down with into
+// the response finishing the request, telling whether err was one of those.
+func paymentErrorResponse(err error) (idemkey.Result, bool) {
+	var perr *payment.Error
+	if !errors.As(err, &perr) {
+		return nil, false
+	}
+
+	log.Errorf("payment error: %v", perr)
+
+	code := idempotency.ResponseCodeErrPaymentGeneric
+	if payment.IsCardError(perr) {
+		code = idempotency.ResponseCodeErrPayment
+	}
+
+	return idemkey.Response(code, idempotency.Message{Message: perr.Kind.Error()}, nil), true
+}
+
+func (r *ride) sendCancellationNotice(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	rideID int64,
+) (idemkey.Result, error) {
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithID(rideID), datastore.RideWithUserID(ik.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	// just like receipts, notices are staged in the same transaction
+	jobArgs := stagedjob.JobArgCancellationNotice{
+		Amount:   ride.Amount,
+		Currency: ride.Currency,
+		RideID:   ride.ID,
+		UserID:   ik.UserID,
+	}
+
+	sj, err := r.jobs.Stage(ctx, uows.StagedJobs(), stagedjob.JobNameSendCancellationNotice, jobArgs)
 	if err != nil {
-		log.Errorf("unlock idem key error: %v", err)
+		return nil, err
 	}
+
+	return auditRide(
+		ctx, uows, ik, ride.ID,
+		audit.ActionStageCancellationNotice,
+		idemkey.Response(idempotency.ResponseCodeOK, ride, nil),
+		map[string]int64{"staged_job_id": sj.ID},
+	)
 }
@@ -7,49 +7,50 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
 	mocks "github.com/rafael-piovesan/go-rocket-ride/v2/mocks/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
 	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
-	"github.com/stripe/stripe-go/v72"
-	"gopkg.in/h2non/gock.v1"
-)
-
-const (
-	stripeURL = "http://stripeapi"
 )
 
 type testMocks struct {
 	uow     *mocks.UnitOfWork
+	uows    *mocks.UnitOfWorkStore
 	idemKey *mocks.IdempotencyKey
 	ride    *mocks.Ride
 	audit   *mocks.AuditRecord
 	user    *mocks.User
 	job     *mocks.StagedJob
+	event   *mocks.StripeEvent
 }
 
-func init() {
-	maxRetries := int64(0)
-	stripeMockBackend := stripe.GetBackendWithConfig(
-		stripe.APIBackend,
-		&stripe.BackendConfig{
-			URL:               stripe.String(stripeURL),
-			LeveledLogger:     stripe.DefaultLeveledLogger,
-			MaxNetworkRetries: &maxRetries,
-		},
-	)
-	stripe.SetBackend(stripe.APIBackend, stripeMockBackend)
-	stripe.SetBackend(stripe.UploadsBackend, stripeMockBackend)
+func testConfig() config.Config {
+	return config.Config{
+		IdemKeyTimeout:       5,
+		IdemKeyActionTimeout: 24,
+		RideBaseFare:         500,
+		RideCurrency:         "usd",
+		RideMinFare:          2000,
+		RidePerKmFare:        150,
+	}
 }
 
 func getMocks() testMocks {
@@ -59,1084 +60,2180 @@ func getMocks() testMocks {
 func getMocksWithTimes(n int) testMocks {
 	m := testMocks{
 		uow:     &mocks.UnitOfWork{},
+		uows:    &mocks.UnitOfWorkStore{},
 		idemKey: &mocks.IdempotencyKey{},
 		ride:    &mocks.Ride{},
 		audit:   &mocks.AuditRecord{},
 		user:    &mocks.User{},
 		job:     &mocks.StagedJob{},
+		event:   &mocks.StripeEvent{},
 	}
 
-	mockAS := &mocks.UnitOfWorkStore{}
+	mockAS := m.uows
 	mockAS.On("AuditRecords").Return(m.audit)
 	mockAS.On("IdempotencyKeys").Return(m.idemKey)
 	mockAS.On("Rides").Return(m.ride)
 	mockAS.On("StagedJobs").Return(m.job)
+	mockAS.On("StripeEvents").Return(m.event)
 	mockAS.On("Users").Return(m.user)
 
-	var mockUOW *mock.Call
-	mockUOW = m.uow.On("Do", mock.Anything, mock.Anything).
-		Run(func(args mock.Arguments) {
-			fn, ok := args.Get(1).(uow.UnitOfWorkBlock)
-			if !ok {
-				panic("argument mismatch")
-			}
+	// Blocks may be run with or without transaction options, so calls are
+	// expected either way.
+	for _, args := range [][]interface{}{
+		{mock.Anything, mock.Anything},
+		{mock.Anything, mock.Anything, mock.Anything},
+	} {
+		var mockUOW *mock.Call
+		mockUOW = m.uow.On("Do", args...).
+			Run(func(args mock.Arguments) {
+				fn, ok := args.Get(1).(uow.UnitOfWorkBlock)
+				if !ok {
+					panic("argument mismatch")
+				}
 
-			// Call the actual func argument 'fn' passed in to
-			// 'DO(context.Context, datastore.UnitOfWorkStore) error'
-			// as expected from its second parameter and, while doing so, inject the
-			// mocked UnitOfWork instance 'mockUOW' so we're able to test the other calls
-			// made to it inside the 'UnitOfWorkBlock'.
-			mockUOW.Return(fn(mockAS))
-		})
+				// Call the actual func argument 'fn' passed in to
+				// 'DO(context.Context, datastore.UnitOfWorkStore) error'
+				// as expected from its second parameter and, while doing so, inject the
+				// mocked UnitOfWork instance 'mockUOW' so we're able to test the other calls
+				// made to it inside the 'UnitOfWorkBlock'.
+				mockUOW.Return(fn(mockAS))
+			})
 
-	if n >= 0 {
-		mockUOW.Times(n)
+		if n >= 0 {
+			mockUOW.Times(n)
+		}
 	}
 
 	return m
 }
 
-func TestGetIdempotencyKey(t *testing.T) {
-	ctx := context.Background()
+// foundKey makes a mocked SaveOrGet load the given key in place of the one
+// that would have been saved, as if it was already there.
+func foundKey(key entity.IdempotencyKey) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		*args.Get(1).(*entity.IdempotencyKey) = key
+	}
+}
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
+// expectAudits lets audit records be saved through m, collecting them in the
+// order they're saved.
+func expectAudits(m testMocks) *[]entity.AuditRecord {
+	saved := &[]entity.AuditRecord{}
+	m.audit.On("Save", mock.Anything, mock.AnythingOfType("*entity.AuditRecord")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*saved = append(*saved, *args.Get(1).(*entity.AuditRecord))
+		})
+	return saved
+}
 
-	gofakeit.Seed(time.Now().UnixNano())
-	jsonRide, err := json.Marshal(entity.Ride{
-		OriginLat: gofakeit.Float64(),
-		OriginLon: gofakeit.Float64(),
-		TargetLat: gofakeit.Float64(),
-		TargetLon: gofakeit.Float64(),
-	})
-	require.NoError(t, err)
+// auditedActions lists the actions of the given audit records.
+func auditedActions(records []entity.AuditRecord) []audit.Action {
+	actions := []audit.Action{}
+	for _, ar := range records {
+		actions = append(actions, ar.Action)
+	}
+	return actions
+}
+
+// transition decodes the Data of an audit record written along a request's
+// atomic phases.
+func transition(t *testing.T, ar entity.AuditRecord) audit.Transition {
+	var tr audit.Transition
+	require.NoError(t, json.Unmarshal(ar.Data, &tr))
+	return tr
+}
+
+func TestCreateRide(t *testing.T) {
+	oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
+	ctx := originip.NewContext(context.Background(), oip)
+
+	mockCfg := testConfig()
 
-	t.Run("Error on GetIdempotencyKey", func(t *testing.T) {
+	t.Run("Error on CreateRide", func(t *testing.T) {
 		key := gofakeit.UUID()
+		keyID := int64(gofakeit.Number(1, 1000))
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
 			UserID:         userID,
 		}
 
-		retErr := errors.New("err GetIdempotencyKey")
+		rd := &entity.Ride{}
+
+		retErr := errors.New("err CreateRide")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg)}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
-			Return(entity.IdempotencyKey{}, retErr)
+			Return(retErr)
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		_, err := uc.createRide(ctx, m.uows, &ik, rd)
 
 		assert.Equal(t, retErr, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		m.ride.AssertNumberOfCalls(t, "Save", 1)
 	})
 
-	t.Run("Error on CreateIdempotencyKey", func(t *testing.T) {
+	t.Run("Error on CreateAuditRecord", func(t *testing.T) {
 		key := gofakeit.UUID()
+		keyID := int64(gofakeit.Number(1, 1000))
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
 			UserID:         userID,
 		}
 
-		retErr := errors.New("err CreateIdempotencyKey")
+		rd := &entity.Ride{}
+
+		retErr := errors.New("err CreateAuditRecord")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg)}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
-			Return(entity.IdempotencyKey{}, data.ErrRecordNotFound)
+			Return(nil)
 
-		m.idemKey.On("Save", ctx, &ik).
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
 			Once().
 			Return(retErr)
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		_, err := uc.createRide(ctx, m.uows, &ik, rd)
 
 		assert.Equal(t, retErr, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Save", 1)
+		m.ride.AssertNumberOfCalls(t, "Save", 1)
+		m.audit.AssertNumberOfCalls(t, "Save", 1)
 	})
 
-	t.Run("Success on CreateIdempotencyKey", func(t *testing.T) {
+	t.Run("Success on createRide", func(t *testing.T) {
 		key := gofakeit.UUID()
+		keyID := int64(gofakeit.Number(1, 1000))
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
 			UserID:         userID,
 		}
 
+		ik.RecoveryPoint = idempotency.RecoveryPointStarted
+		ik.RequestParams = []byte(`{"target_lon": 1.0}`)
+
+		// roughly 111 km along the equator
+		rd := &entity.Ride{TargetLon: 1.0}
+
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg)}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		var saved *entity.Ride
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
-			Return(entity.IdempotencyKey{}, data.ErrRecordNotFound)
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				saved = args.Get(1).(*entity.Ride)
+				saved.ID = 7
+			})
 
-		m.idemKey.On("Save", ctx, &ik).
-			Once().
-			Return(nil)
+		audits := expectAudits(m)
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		res, err := uc.createRide(ctx, m.uows, &ik, rd)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
 
-		assert.NoError(t, err)
-		assert.Equal(t, idempotency.RecoveryPointStarted, ik.RecoveryPoint)
-		assert.GreaterOrEqual(t, time.Now().UTC(), ik.LastRunAt)
-		if assert.NotNil(t, ik.LockedAt) {
-			assert.GreaterOrEqual(t, time.Now().UTC(), *ik.LockedAt)
-		}
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Save", 1)
+		assert.Equal(t, idempotency.RecoveryPointCreated, ik.RecoveryPoint)
+		assert.Equal(t, int64(17179), saved.Amount)
+		assert.Equal(t, "usd", saved.Currency)
+		assert.Equal(t, userID, saved.UserID)
+		assert.Equal(t, keyID, *saved.IdempotencyKeyID)
+		// the original ride is left untouched
+		assert.Zero(t, rd.Amount)
+		m.ride.AssertNumberOfCalls(t, "Save", 1)
+
+		require.Len(t, *audits, 1)
+		ar := (*audits)[0]
+		assert.Equal(t, audit.ActionCreateRide, ar.Action)
+		assert.Equal(t, audit.ResourceTypeRide, ar.ResourceType)
+		assert.Equal(t, int64(7), ar.ResourceID)
+		assert.Equal(t, userID, ar.UserID)
+		assert.Equal(t, oip.IP, ar.OriginIP)
+		assert.JSONEq(t, fmt.Sprintf(`{
+			"idempotency_key_id": %v,
+			"recovery_point_before": "STARTED",
+			"recovery_point_after": "CREATED",
+			"details": {"request_params": {"target_lon": 1.0}}
+		}`, keyID), string(ar.Data))
 	})
+}
 
-	t.Run("Request parameters mismatch", func(t *testing.T) {
-		gofakeit.Seed(time.Now().UnixNano())
-		jsonRide2, err := json.Marshal(entity.Ride{
-			OriginLat: gofakeit.Float64(),
-			OriginLon: gofakeit.Float64(),
-			TargetLat: gofakeit.Float64(),
-			TargetLon: gofakeit.Float64(),
-		})
-		require.NoError(t, err)
+func TestCreateCharge(t *testing.T) {
+	ctx := context.Background()
 
+	mockCfg := testConfig()
+
+	t.Run("Error on GetRideByIdempotencyKeyID", func(t *testing.T) {
 		key := gofakeit.UUID()
+		keyID := int64(gofakeit.Number(1, 1000))
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
 			UserID:         userID,
-			RequestParams:  jsonRide,
 		}
 
-		retIK := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide2,
-		}
+		retErr := errors.New("err GetRideByIdempotencyKeyID")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		payments := payment.NewFake()
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payments}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(retIK, nil)
+			Return(entity.Ride{}, retErr)
 
-		err = uc.setIdempotencyKey(ctx, &ik)
+		_, err := uc.createCharge(ctx, m.uows, &ik)
 
-		assert.Equal(t, entity.ErrIdemKeyParamsMismatch, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		assert.Equal(t, retErr, err)
+		assert.Empty(t, payments.PaymentIntents())
+		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
 	})
 
-	t.Run("Request in progress", func(t *testing.T) {
+	t.Run("Payment errors", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			err  error
+			code idempotency.ResponseCode
+			msg  string
+		}{
+			{
+				desc: "card error",
+				err:  payment.NewCardError("balance_insufficient", "card is suspicious"),
+				code: idempotency.ResponseCodeErrPayment,
+				msg:  "card error from payment processor",
+			},
+			{
+				desc: "generic error",
+				err:  payment.NewGenericError("", "system is down"),
+				code: idempotency.ResponseCodeErrPaymentGeneric,
+				msg:  "generic error from payment processor",
+			},
+		}
+
+		for _, tc := range tests {
+			key := gofakeit.UUID()
+			keyID := int64(gofakeit.Number(1, 1000))
+			user := &entity.User{
+				ID:               int64(gofakeit.Number(1, 1000)),
+				Email:            gofakeit.Email(),
+				StripeCustomerID: gofakeit.UUID(),
+			}
+			ik := entity.IdempotencyKey{
+				ID:             keyID,
+				IdempotencyKey: key,
+				UserID:         user.ID,
+				User:           user,
+			}
+
+			m := getMocks()
+			payments := payment.NewFake()
+			payments.Err = tc.err
+			uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payments}
+
+			m.ride.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.Ride{}, nil)
+
+			audits := expectAudits(m)
+
+			res, err := uc.createCharge(ctx, m.uows, &ik)
+			require.NoError(t, err, tc.desc)
+			require.NoError(t, res(&ik), tc.desc)
+
+			// errors coming from the processor are definitive and finish the request
+			assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint, tc.desc)
+			assert.Equal(t, tc.code, *ik.ResponseCode, tc.desc)
+			assert.JSONEq(t, fmt.Sprintf(`{"message": %q}`, tc.msg), string(ik.ResponseBody), tc.desc)
+			m.ride.AssertNumberOfCalls(t, "FindOne", 1)
+			m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+			if assert.Len(t, *audits, 1, tc.desc) {
+				tr := transition(t, (*audits)[0])
+				assert.Equal(t, audit.ActionChargeFailed, (*audits)[0].Action, tc.desc)
+				assert.Equal(t, idempotency.RecoveryPointFinished, tr.RecoveryPointAfter, tc.desc)
+				assert.Equal(t, map[string]interface{}{"error": tc.err.Error()}, tr.Details, tc.desc)
+			}
+		}
+	})
+
+	t.Run("Payment unknown error", func(t *testing.T) {
 		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+		keyID := int64(gofakeit.Number(1, 1000))
+		user := &entity.User{
+			ID:               int64(gofakeit.Number(1, 1000)),
+			Email:            gofakeit.Email(),
+			StripeCustomerID: gofakeit.UUID(),
+		}
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
+			UserID:         user.ID,
+			User:           user,
 		}
 
-		now := time.Now().UTC()
-		retIK := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
-			LockedAt:       &now,
-		}
+		retErr := errors.New("unknown error")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		payments := payment.NewFake()
+		payments.Err = retErr
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payments}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(retIK, nil)
+			Return(entity.Ride{}, nil)
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		_, err := uc.createCharge(ctx, m.uows, &ik)
 
-		assert.Equal(t, entity.ErrIdemKeyRequestInProgress, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		// the outcome is unknown, so the request is left to be retried
+		assert.Equal(t, retErr, err)
+		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
 	})
 
-	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
-		// list non-terminal Recovery Point (i.e., all but 'FINISHED')
-		rps := []idempotency.RecoveryPoint{
-			idempotency.RecoveryPointStarted,
-			idempotency.RecoveryPointCreated,
-			idempotency.RecoveryPointCharged,
-		}
-		// randomly pick a Recovery Point
-		ix := gofakeit.Number(0, len(rps)-1)
-
+	t.Run("Error on UpdateRide", func(t *testing.T) {
 		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+		keyID := int64(gofakeit.Number(1, 1000))
+		user := &entity.User{
+			ID:               int64(gofakeit.Number(1, 1000)),
+			Email:            gofakeit.Email(),
+			StripeCustomerID: gofakeit.UUID(),
+		}
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
-			RecoveryPoint:  rps[ix],
+			UserID:         user.ID,
+			User:           user,
 		}
 
-		retErr := errors.New("err UpdateIdempotencyKey")
+		retErr := errors.New("err UpdateRide")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payment.NewFake()}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(ik, nil)
+			Return(entity.Ride{}, nil)
 
-		m.idemKey.On("Update", ctx, mock.Anything).
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
 			Return(retErr)
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		_, err := uc.createCharge(ctx, m.uows, &ik)
 
 		assert.Equal(t, retErr, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
+		m.ride.AssertNumberOfCalls(t, "Update", 1)
 	})
 
-	t.Run("Success on UpdateIdempotencyKey", func(t *testing.T) {
-		// list non-terminal Recovery Point (i.e., all but 'FINISHED')
-		rps := []idempotency.RecoveryPoint{
-			idempotency.RecoveryPointStarted,
-			idempotency.RecoveryPointCreated,
-			idempotency.RecoveryPointCharged,
-		}
-		// randomly pick a Recovery Point
-		ix := gofakeit.Number(0, len(rps)-1)
-
+	t.Run("Success on createCharge", func(t *testing.T) {
 		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+		keyID := int64(gofakeit.Number(1, 1000))
+		user := &entity.User{
+			ID:               int64(gofakeit.Number(1, 1000)),
+			Email:            gofakeit.Email(),
+			StripeCustomerID: gofakeit.UUID(),
+		}
 		ik := entity.IdempotencyKey{
+			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
-			RecoveryPoint:  rps[ix],
+			UserID:         user.ID,
+			User:           user,
 		}
 
+		rd := entity.Ride{ID: 7, Amount: 4321, Currency: "usd"}
+
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		payments := payment.NewFake()
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payments}
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(ik, nil)
+			Return(rd, nil)
 
-		m.idemKey.On("Update", ctx, mock.Anything).
+		var updated entity.Ride
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
-			Return(nil)
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				updated = *args.Get(1).(*entity.Ride)
+			})
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+		audits := expectAudits(m)
 
-		assert.NoError(t, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
-	})
+		res, err := uc.createCharge(ctx, m.uows, &ik)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
 
-	t.Run("No-op on RecoveryPointFinished", func(t *testing.T) {
-		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
-			RecoveryPoint:  idempotency.RecoveryPointFinished,
-		}
+		// the ride's stored fare is the one charged, keyed by our own key
+		assert.Equal(t, []payment.PaymentIntentParams{{
+			IdempotencyKey: fmt.Sprintf("go-rocket-ride-%v", keyID),
+			Amount:         4321,
+			Currency:       "usd",
+			Customer:       user.StripeCustomerID,
+			Description:    "Charge for ride 7",
+		}}, payments.PaymentIntents())
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		assert.Equal(t, idempotency.RecoveryPointCharged, ik.RecoveryPoint)
+		assert.Equal(t, "pi_fake_1", *updated.StripePaymentIntentID)
+		assert.Equal(t, "succeeded", *updated.StripePaymentIntentStatus)
+		assert.Equal(t, "ch_fake_2", *updated.StripeChargeID)
+		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
+		m.ride.AssertNumberOfCalls(t, "Update", 1)
 
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
-			Once().
-			Return(ik, nil)
+		require.Len(t, *audits, 1)
+		assert.Equal(t, audit.ActionChargeRide, (*audits)[0].Action)
+		assert.Equal(t, int64(7), (*audits)[0].ResourceID)
+		assert.Equal(t, map[string]interface{}{
+			"stripe_payment_intent_id":     "pi_fake_1",
+			"stripe_payment_intent_status": "succeeded",
+			"stripe_charge_id":             "ch_fake_2",
+		}, transition(t, (*audits)[0]).Details)
+	})
 
-		err := uc.setIdempotencyKey(ctx, &ik)
+	t.Run("Payment intent statuses", func(t *testing.T) {
+		tests := []struct {
+			status payment.PaymentIntentStatus
+			action audit.Action
+			rp     idempotency.RecoveryPoint
+			code   idempotency.ResponseCode
+			body   string
+		}{
+			{
+				status: payment.PaymentIntentRequiresAction,
+				action: audit.ActionChargePending,
+				rp:     idempotency.RecoveryPointAwaitingAction,
+				code:   idempotency.ResponseCodeErrPayment,
+				body:   `{"message": "payment requires customer action", "client_secret": "pi_fake_1_secret"}`,
+			},
+			{
+				status: payment.PaymentIntentProcessing,
+				action: audit.ActionChargePending,
+				rp:     idempotency.RecoveryPointAwaitingAction,
+				code:   idempotency.ResponseCodeErrPaymentGeneric,
+				body:   `{"message": "payment still processing"}`,
+			},
+			{
+				status: payment.PaymentIntentRequiresPaymentMethod,
+				action: audit.ActionChargeFailed,
+				rp:     idempotency.RecoveryPointFinished,
+				code:   idempotency.ResponseCodeErrPayment,
+				body:   `{"message": "card error from payment processor"}`,
+			},
+		}
 
-		assert.NoError(t, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		for _, tc := range tests {
+			ik := entity.IdempotencyKey{
+				ID:             int64(gofakeit.Number(1, 1000)),
+				IdempotencyKey: gofakeit.UUID(),
+				RecoveryPoint:  idempotency.RecoveryPointCreated,
+				User:           &entity.User{StripeCustomerID: gofakeit.UUID()},
+			}
+
+			m := getMocks()
+			payments := payment.NewFake()
+			payments.IntentStatus = tc.status
+			uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), payments: payments}
+
+			m.ride.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.Ride{}, nil)
+
+			var updated entity.Ride
+			m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+				Once().
+				Return(nil).
+				Run(func(args mock.Arguments) {
+					updated = *args.Get(1).(*entity.Ride)
+				})
+
+			audits := expectAudits(m)
+
+			res, err := uc.createCharge(ctx, m.uows, &ik)
+			require.NoError(t, err, tc.status)
+			require.NoError(t, res(&ik), tc.status)
+
+			assert.Equal(t, tc.rp, ik.RecoveryPoint, tc.status)
+			assert.Equal(t, tc.code, *ik.ResponseCode, tc.status)
+			assert.JSONEq(t, tc.body, string(ik.ResponseBody), tc.status)
+
+			// the intent is kept on the ride, but there's no charge yet
+			assert.Equal(t, "pi_fake_1", *updated.StripePaymentIntentID, tc.status)
+			assert.Equal(t, string(tc.status), *updated.StripePaymentIntentStatus, tc.status)
+			assert.Nil(t, updated.StripeChargeID, tc.status)
+
+			if assert.Len(t, *audits, 1, tc.status) {
+				tr := transition(t, (*audits)[0])
+				assert.Equal(t, tc.action, (*audits)[0].Action, tc.status)
+				assert.Equal(t, idempotency.RecoveryPointCreated, tr.RecoveryPointBefore, tc.status)
+				assert.Equal(t, tc.rp, tr.RecoveryPointAfter, tc.status)
+			}
+		}
 	})
 }
 
-func TestCreateRide(t *testing.T) {
-	oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
-	ctx := originip.NewContext(context.Background(), oip)
+func TestConfirmCharge(t *testing.T) {
+	ctx := context.Background()
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
+	intentID := "pi_fake_1"
+	ik := entity.IdempotencyKey{
+		ID:             int64(gofakeit.Number(1, 1000)),
+		IdempotencyKey: gofakeit.UUID(),
+		CreatedAt:      time.Now().UTC(),
+		RecoveryPoint:  idempotency.RecoveryPointAwaitingAction,
+	}
 
-	t.Run("Error on CreateRide", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
-		}
+	// newPayments returns a provider holding an intent waiting on the customer
+	newPayments := func(t *testing.T) *payment.Fake {
+		payments := payment.NewFake()
+		payments.IntentStatus = payment.PaymentIntentRequiresAction
 
-		rd := &entity.Ride{}
+		pi, err := payments.CreatePaymentIntent(ctx, payment.PaymentIntentParams{IdempotencyKey: "key"})
+		require.NoError(t, err)
+		require.Equal(t, intentID, pi.ID)
+		return payments
+	}
 
-		retErr := errors.New("err CreateRide")
+	t.Run("Error on FindOne", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err FindOne")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: testConfig(), payments: newPayments(t)}
 
-		m.ride.On("Save", ctx, rd).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(retErr)
+			Return(entity.Ride{}, retErr)
 
-		err := uc.createRide(ctx, &ik, rd)
+		_, err := uc.confirmCharge(ctx, m.uows, &ik)
 
 		assert.Equal(t, retErr, err)
-		m.ride.AssertNumberOfCalls(t, "Save", 1)
 	})
 
-	t.Run("Error on CreateAuditRecord", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
-		}
-
-		rd := &entity.Ride{}
-
-		retErr := errors.New("err CreateAuditRecord")
+	t.Run("Error on GetPaymentIntent", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("connection refused")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		payments := newPayments(t)
+		payments.Err = retErr
+		uc := ride{cfg: testConfig(), payments: payments}
 
-		m.ride.On("Save", ctx, rd).
-			Once().
-			Return(nil)
-
-		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(retErr)
+			Return(entity.Ride{StripePaymentIntentID: &intentID}, nil)
 
-		err := uc.createRide(ctx, &ik, rd)
+		_, err := uc.confirmCharge(ctx, m.uows, &ik)
 
 		assert.Equal(t, retErr, err)
-		m.ride.AssertNumberOfCalls(t, "Save", 1)
-		m.audit.AssertNumberOfCalls(t, "Save", 1)
+		m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
 	})
 
-	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
-		}
-
-		rd := &entity.Ride{}
-
-		retErr := errors.New("err UpdateIdempotencyKey")
+	t.Run("Still requires action", func(t *testing.T) {
+		ik := ik
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: testConfig(), payments: newPayments(t)}
 
-		m.ride.On("Save", ctx, rd).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(nil)
+			Return(entity.Ride{StripePaymentIntentID: &intentID}, nil)
 
-		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
 			Return(nil)
 
-		m.idemKey.On("Update", ctx, &ik).
-			Once().
-			Return(retErr)
+		audits := expectAudits(m)
 
-		err := uc.createRide(ctx, &ik, rd)
+		res, err := uc.confirmCharge(ctx, m.uows, &ik)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
 
-		assert.Equal(t, retErr, err)
-		m.ride.AssertNumberOfCalls(t, "Save", 1)
-		m.audit.AssertNumberOfCalls(t, "Save", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		assert.Equal(t, idempotency.RecoveryPointAwaitingAction, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeErrPayment, *ik.ResponseCode)
+		assert.Contains(t, string(ik.ResponseBody), "pi_fake_1_secret")
+		assert.Equal(t, []audit.Action{audit.ActionChargePending}, auditedActions(*audits))
 	})
 
-	t.Run("Success on createRide", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
+	t.Run("Success on confirmCharge", func(t *testing.T) {
+		ik := ik
+
+		m := getMocks()
+		payments := newPayments(t)
+		payments.SetPaymentIntentStatus(intentID, payment.PaymentIntentSucceeded)
+		uc := ride{cfg: testConfig(), payments: payments}
+
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.Ride{StripePaymentIntentID: &intentID}, nil)
+
+		var updated entity.Ride
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				updated = *args.Get(1).(*entity.Ride)
+			})
+
+		audits := expectAudits(m)
+
+		res, err := uc.confirmCharge(ctx, m.uows, &ik)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		assert.Equal(t, idempotency.RecoveryPointCharged, ik.RecoveryPoint)
+		assert.Nil(t, ik.ResponseCode)
+		if assert.Len(t, *audits, 1) {
+			tr := transition(t, (*audits)[0])
+			assert.Equal(t, audit.ActionChargeRide, (*audits)[0].Action)
+			assert.Equal(t, idempotency.RecoveryPointAwaitingAction, tr.RecoveryPointBefore)
+			assert.Equal(t, idempotency.RecoveryPointCharged, tr.RecoveryPointAfter)
 		}
+		assert.Equal(t, "succeeded", *updated.StripePaymentIntentStatus)
+		assert.Equal(t, "ch_fake_2", *updated.StripeChargeID)
+	})
 
-		rd := &entity.Ride{}
+	t.Run("Action taken too late", func(t *testing.T) {
+		ik := ik
+		ik.CreatedAt = time.Now().UTC().Add(-25 * time.Hour)
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		payments := newPayments(t)
+		uc := ride{cfg: testConfig(), payments: payments}
 
-		m.ride.On("Save", ctx, rd).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(nil)
+			Return(entity.Ride{StripePaymentIntentID: &intentID}, nil)
 
-		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+		var updated entity.Ride
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
-			Return(nil)
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				updated = *args.Get(1).(*entity.Ride)
+			})
+
+		audits := expectAudits(m)
+
+		res, err := uc.confirmCharge(ctx, m.uows, &ik)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		// the intent is canceled, finishing the request as failed
+		if assert.Len(t, payments.Cancellations(), 1) {
+			assert.Equal(t, intentID, payments.Cancellations()[0].ID)
+		}
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeErrPayment, *ik.ResponseCode)
+		assert.Equal(t, []audit.Action{audit.ActionChargeFailed}, auditedActions(*audits))
+		assert.Equal(t, "canceled", *updated.StripePaymentIntentStatus)
+		assert.Nil(t, updated.StripeChargeID)
+	})
+
+	t.Run("Error on CancelPaymentIntent", func(t *testing.T) {
+		ik := ik
+		ik.CreatedAt = time.Now().UTC().Add(-25 * time.Hour)
+		// e.g. the customer took action right before the intent got canceled
+		retErr := payment.NewGenericError("payment_intent_unexpected_state", "payment intent has already succeeded")
+
+		m := getMocks()
+		uc := ride{cfg: testConfig(), payments: uncancelable{newPayments(t), retErr}}
 
-		m.idemKey.On("Update", ctx, &ik).
+		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(nil)
+			Return(entity.Ride{StripePaymentIntentID: &intentID}, nil)
 
-		err := uc.createRide(ctx, &ik, rd)
+		_, err := uc.confirmCharge(ctx, m.uows, &ik)
 
-		assert.NoError(t, err)
-		assert.Equal(t, idempotency.RecoveryPointCreated, ik.RecoveryPoint)
-		m.ride.AssertNumberOfCalls(t, "Save", 1)
-		m.audit.AssertNumberOfCalls(t, "Save", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		// left to be retried, which finds out how the intent ended up
+		assert.Equal(t, retErr, err)
+		m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
 	})
 }
 
-func TestCreateCharge(t *testing.T) {
-	defer gock.Off()
+// uncancelable is a payment provider failing to cancel payment intents.
+type uncancelable struct {
+	*payment.Fake
+	err error
+}
+
+func (p uncancelable) CancelPaymentIntent(context.Context, payment.CancelPaymentIntentParams) (payment.PaymentIntent, error) {
+	return payment.PaymentIntent{}, p.err
+}
+
+func TestSendReceipt(t *testing.T) {
 	ctx := context.Background()
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
+	mockCfg := testConfig()
 
 	t.Run("Error on GetRideByIdempotencyKeyID", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
+			UserID: userID,
 		}
 
 		retErr := errors.New("err GetRideByIdempotencyKeyID")
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg)}
 
 		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
 			Return(entity.Ride{}, retErr)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		_, err := uc.sendReceipt(ctx, m.uows, &ik)
 
 		assert.Equal(t, retErr, err)
 		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
+		m.job.AssertNumberOfCalls(t, "Save", 0)
 	})
 
-	t.Run("Stripe card error", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+	t.Run("Error on CreateStagedJob", func(t *testing.T) {
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID: userID,
 		}
 
+		retErr := errors.New("err CreateStagedJob")
+
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), jobs: NewJobs(JobHandlers{})}
 
 		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(entity.Ride{}, nil)
-
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(402).
-			BodyString(`{
-				"error": {
-					"type":"card_error",
-					"code": "balance_insufficient",
-					"message":"card is suspicious"
-				}
-			}`)
+			Return(entity.Ride{Currency: "usd"}, nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
-			Return(nil)
+			Return(retErr)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		_, err := uc.sendReceipt(ctx, m.uows, &ik)
 
-		assert.Equal(t, entity.ErrPaymentProvider, err)
+		assert.Equal(t, retErr, err)
 		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
 	})
 
-	t.Run("Stripe generic error", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+	t.Run("Success on CreateStagedJob", func(t *testing.T) {
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID: userID,
+		}
+
+		rd := entity.Ride{
+			ID:        int64(gofakeit.Number(1, 1000)),
+			OriginLat: gofakeit.Latitude(),
+			OriginLon: gofakeit.Longitude(),
+			Amount:    int64(gofakeit.Number(2000, 10000)),
+			Currency:  "usd",
+			UserID:    userID,
 		}
 
+		body, err := json.Marshal(rd)
+		require.NoError(t, err)
+
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{cfg: mockCfg, pricer: pricing.New(mockCfg), jobs: NewJobs(JobHandlers{})}
 
 		m.ride.On("FindOne", ctx, mock.Anything).
 			Once().
-			Return(entity.Ride{}, nil)
-
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(503).
-			BodyString(`{
-				"error": {
-					"type":"api_error",
-					"message":"system is down"
-				}
-			}`)
+			Return(rd, nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		var sj *entity.StagedJob
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
-			Return(nil)
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				sj = args.Get(1).(*entity.StagedJob)
+				sj.ID = 9
+			})
+
+		audits := expectAudits(m)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		res, err := uc.sendReceipt(ctx, m.uows, &ik)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
 
-		assert.Equal(t, entity.ErrPaymentProviderGeneric, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.JSONEq(t, string(body), string(ik.ResponseBody))
+		assert.Equal(t, "/rides/"+strconv.FormatInt(rd.ID, 10), ik.ResponseHeaders["Location"])
+		m.job.AssertNumberOfCalls(t, "Save", 1)
 		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+
+		// the receipt holds the very same fare stored on the ride
+		var args stagedjob.JobArgReceipt
+		require.NoError(t, json.Unmarshal(sj.JobArgs, &args))
+		exp := stagedjob.JobArgReceipt{Amount: rd.Amount, Currency: rd.Currency, RideID: rd.ID, UserID: userID}
+		assert.Equal(t, exp, args)
+
+		require.Len(t, *audits, 1)
+		assert.Equal(t, audit.ActionStageReceipt, (*audits)[0].Action)
+		assert.Equal(t, rd.ID, (*audits)[0].ResourceID)
+		tr := transition(t, (*audits)[0])
+		assert.Equal(t, idempotency.RecoveryPointFinished, tr.RecoveryPointAfter)
+		assert.Equal(t, map[string]interface{}{"staged_job_id": float64(9)}, tr.Details)
 	})
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := testConfig()
+
+	jsonRide, err := json.Marshal(entity.Ride{
+		OriginLat: gofakeit.Float64(),
+		OriginLon: gofakeit.Float64(),
+		TargetLat: gofakeit.Float64(),
+		TargetLon: gofakeit.Float64(),
+	})
+	require.NoError(t, err)
 
-	t.Run("Stripe unknown error", func(t *testing.T) {
+	t.Run("Error on createRide", func(t *testing.T) {
 		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointStarted,
 		}
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.ride.On("FindOne", ctx, mock.Anything).
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(entity.Ride{}, nil)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			ReplyError(errors.New("unknown error"))
+		// Create Ride
+		retErr := errors.New("error createRide")
+		m.uow.On("Do", mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(retErr)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		err := uc.Create(ctx, &ik, &entity.Ride{})
 
-		assert.Error(t, err)
-		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
+		assert.Equal(t, retErr, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
 	})
 
-	t.Run("Error on UpdateRide", func(t *testing.T) {
+	t.Run("Error on createCharge", func(t *testing.T) {
 		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
 		}
 
-		rd := entity.Ride{StripeChargeID: new(string)}
-
-		retErr := errors.New("err UpdateRide")
-
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.ride.On("FindOne", ctx, mock.Anything).
-			Once().
-			Return(rd, nil)
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
 
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(200).
-			JSON(map[string]string{"foo": "bar"})
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		m.ride.On("Update", ctx, &rd).
+		// Create Charge
+		retErr := errors.New("error createCharge")
+		m.uow.On("Do", mock.Anything, mock.Anything, mock.Anything).
 			Once().
 			Return(retErr)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		err := uc.Create(ctx, &ik, &entity.Ride{})
 
 		assert.Equal(t, retErr, err)
-		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
-		m.ride.AssertNumberOfCalls(t, "Update", 1)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
 	})
 
-	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
+	t.Run("Error on sendReceipt", func(t *testing.T) {
 		key := gofakeit.UUID()
 		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
 			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
 		}
 
-		rd := entity.Ride{StripeChargeID: new(string)}
-
-		retErr := errors.New("err UpdateIdempotencyKey")
-
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
-
-		m.ride.On("FindOne", ctx, mock.Anything).
-			Once().
-			Return(rd, nil)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(200).
-			JSON(map[string]string{"foo": "bar"})
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
 
-		m.ride.On("Update", ctx, &rd).
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(nil)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		// Send Receipt
+		retErr := errors.New("error sendReceipt")
+		m.uow.On("Do", mock.Anything, mock.Anything, mock.Anything).
 			Once().
 			Return(retErr)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		err := uc.Create(ctx, &ik, &entity.Ride{})
 
 		assert.Equal(t, retErr, err)
-		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
-		m.ride.AssertNumberOfCalls(t, "Update", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
 	})
 
-	t.Run("Success on createCharge", func(t *testing.T) {
+	t.Run("No-op on finished recovery point", func(t *testing.T) {
 		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
 			IdempotencyKey: key,
-			UserID:         user.ID,
-			User:           user,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointFinished,
 		}
 
-		rd := entity.Ride{StripeChargeID: new(string)}
-
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
-
-		m.ride.On("FindOne", ctx, mock.Anything).
-			Once().
-			Return(rd, nil)
-
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(200).
-			JSON(map[string]string{"foo": "bar"})
-
-		m.ride.On("Update", ctx, &rd).
-			Once().
-			Return(nil)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.idemKey.On("Update", ctx, &ik).
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(nil)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		err := uc.createCharge(ctx, &ik, nil)
+		err := uc.Create(ctx, &ik, &entity.Ride{})
 
 		assert.NoError(t, err)
-		assert.Equal(t, idempotency.RecoveryPointCharged, ik.RecoveryPoint)
-		m.ride.AssertNumberOfCalls(t, "FindOne", 1)
-		m.ride.AssertNumberOfCalls(t, "Update", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
 	})
-}
-
-func TestSendReceipt(t *testing.T) {
-	ctx := context.Background()
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
-
-	t.Run("Error on CreateStagedJob", func(t *testing.T) {
+	t.Run("Error on unknown recovery point", func(t *testing.T) {
+		key := gofakeit.UUID()
 		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			UserID: userID,
-		}
-
-		retErr := errors.New("err CreateStagedJob")
+			IdempotencyKey: key,
+			UserID:         userID,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  "unknown",
+		}
 
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(retErr)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		err := uc.sendReceipt(ctx, &ik)
+		// locked and then unlocked right away
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(2).
+			Return(nil)
 
-		assert.Equal(t, retErr, err)
-		m.job.AssertNumberOfCalls(t, "Save", 1)
+		err := uc.Create(ctx, &ik, &entity.Ride{})
+
+		assert.Equal(t, entity.ErrIdemKeyUnknownRecoveryPoint, err)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
 	})
 
-	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
-		userID := int64(gofakeit.Number(1, 1000))
+	t.Run("Success on Create", func(t *testing.T) {
+		key := gofakeit.UUID()
+		keyID := int64(gofakeit.Number(1, 1000))
+		user := &entity.User{
+			ID:               int64(gofakeit.Number(1, 1000)),
+			Email:            gofakeit.Email(),
+			StripeCustomerID: gofakeit.UUID(),
+		}
 		ik := entity.IdempotencyKey{
-			UserID: userID,
+			ID:             keyID,
+			IdempotencyKey: key,
+			UserID:         user.ID,
+			User:           user,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointStarted,
 		}
 
-		retErr := errors.New("err UpdateIdempotencyKey")
+		rd := &entity.Ride{Currency: "usd", StripeChargeID: new(string)}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		m := getMocksWithTimes(0)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
-			Once().
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(4).
 			Return(nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		// Get Idempotency Key
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(retErr)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		err := uc.sendReceipt(ctx, &ik)
-
-		assert.Error(t, retErr, err)
-		m.job.AssertNumberOfCalls(t, "Save", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
-	})
+		// Create Ride
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(nil)
 
-	t.Run("Success on CreateStagedJob", func(t *testing.T) {
-		userID := int64(gofakeit.Number(1, 1000))
-		ik := entity.IdempotencyKey{
-			UserID: userID,
-		}
+		// one audit record for each phase
+		audits := expectAudits(m)
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		// Create Charge and Send Receipt
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Twice().
+			Return(*rd, nil)
 
-		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
 			Return(nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		// Send Receipt
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
 			Return(nil)
 
-		err := uc.sendReceipt(ctx, &ik)
+		err := uc.Create(ctx, &ik, rd)
 
 		assert.NoError(t, err)
-		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
-		assert.Nil(t, ik.LockedAt)
-		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
-		assert.Equal(t, idempotency.ResponseBody{Message: "OK"}, *ik.ResponseBody)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 4)
+		m.idemKey.AssertNumberOfCalls(t, "SaveOrGet", 1)
+		m.ride.AssertNumberOfCalls(t, "Save", 1)
+		m.ride.AssertNumberOfCalls(t, "FindOne", 2)
+		m.ride.AssertNumberOfCalls(t, "Update", 1)
 		m.job.AssertNumberOfCalls(t, "Save", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
-	})
-}
-
-func TestUnlockIdempotencyKey(t *testing.T) {
-	ctx := context.Background()
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
+		// the request's history can be told from its audit records alone
+		assert.Equal(
+			t,
+			[]audit.Action{audit.ActionCreateRide, audit.ActionChargeRide, audit.ActionStageReceipt},
+			auditedActions(*audits),
+		)
+		rps := []idempotency.RecoveryPoint{idempotency.RecoveryPointStarted}
+		for _, ar := range *audits {
+			tr := transition(t, ar)
+			assert.Equal(t, keyID, tr.IdempotencyKeyID)
+			assert.Equal(t, rps[len(rps)-1], tr.RecoveryPointBefore)
+			rps = append(rps, tr.RecoveryPointAfter)
+		}
+		assert.Equal(t, []idempotency.RecoveryPoint{
+			idempotency.RecoveryPointStarted,
+			idempotency.RecoveryPointCreated,
+			idempotency.RecoveryPointCharged,
+			idempotency.RecoveryPointFinished,
+		}, rps)
+	})
 
-	t.Run("Error on UpdateIdempotencyKey", func(t *testing.T) {
-		ik := entity.IdempotencyKey{}
+	t.Run("Success on Create after customer action", func(t *testing.T) {
+		intentID := "pi_fake_1"
+		user := &entity.User{
+			ID:               int64(gofakeit.Number(1, 1000)),
+			Email:            gofakeit.Email(),
+			StripeCustomerID: gofakeit.UUID(),
+		}
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RequestParams:  jsonRide,
+			RecoveryPoint:  idempotency.RecoveryPointStarted,
+		}
 
-		retErr := errors.New("err UpdateIdempotencyKey")
+		m := getMocksWithTimes(-1)
+		payments := payment.NewFake()
+		payments.IntentStatus = payment.PaymentIntentRequiresAction
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payments,
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		var stored entity.IdempotencyKey
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				stored = *args.Get(1).(*entity.IdempotencyKey)
+			})
 
-		m.idemKey.On("Update", ctx, &ik).
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 			Once().
-			Return(retErr)
+			Run(foundKey(ik)).
+			Return(false, nil)
 
-		uc.unlockIdempotencyKey(ctx, &ik)
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(nil)
 
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
-	})
+		audits := expectAudits(m)
 
-	t.Run("Success on UpdateIdempotencyKey", func(t *testing.T) {
-		ik := entity.IdempotencyKey{}
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Return(entity.Ride{ID: 1, Currency: "usd", StripePaymentIntentID: &intentID}, nil)
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+			Return(nil)
 
-		m.idemKey.On("Update", ctx, &ik).
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
 			Return(nil)
 
-		uc.unlockIdempotencyKey(ctx, &ik)
+		// the request stops, waiting on the customer
+		err := uc.Create(ctx, &ik, &entity.Ride{})
+
+		require.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointAwaitingAction, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeErrPayment, *ik.ResponseCode)
+		assert.JSONEq(
+			t,
+			`{"message": "payment requires customer action", "client_secret": "pi_fake_1_secret"}`,
+			string(ik.ResponseBody),
+		)
+		m.job.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		assert.Equal(t, []audit.Action{audit.ActionCreateRide, audit.ActionChargePending}, auditedActions(*audits))
+
+		// once the customer is done, retrying with the same key finishes it
+		payments.SetPaymentIntentStatus(intentID, payment.PaymentIntentSucceeded)
+
+		m.idemKey.On("SaveOrGet", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Run(foundKey(stored)).
+			Return(false, nil)
+
+		retry := entity.IdempotencyKey{
+			IdempotencyKey: ik.IdempotencyKey,
+			UserID:         user.ID,
+			User:           user,
+			RequestParams:  jsonRide,
+		}
+		err = uc.Create(ctx, &retry, &entity.Ride{})
 
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		require.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, retry.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *retry.ResponseCode)
+		assert.Len(t, payments.PaymentIntents(), 1)
+		m.ride.AssertNumberOfCalls(t, "Save", 1)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
+		assert.Equal(t, []audit.Action{
+			audit.ActionCreateRide,
+			audit.ActionChargePending,
+			audit.ActionChargeRide,
+			audit.ActionStageReceipt,
+		}, auditedActions(*audits))
 	})
 }
 
-func TestCreate(t *testing.T) {
-	defer gock.Off()
+func TestComplete(t *testing.T) {
 	ctx := context.Background()
 
-	mockCfg := config.Config{IdemKeyTimeout: 5}
+	mockCfg := testConfig()
 
 	jsonRide, err := json.Marshal(entity.Ride{
-		OriginLat: gofakeit.Float64(),
-		OriginLon: gofakeit.Float64(),
-		TargetLat: gofakeit.Float64(),
-		TargetLon: gofakeit.Float64(),
+		OriginLat: gofakeit.Latitude(),
+		OriginLon: gofakeit.Longitude(),
+		TargetLat: gofakeit.Latitude(),
+		TargetLon: gofakeit.Longitude(),
 	})
 	require.NoError(t, err)
 
-	t.Run("Error on createRide", func(t *testing.T) {
-		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+	user := &entity.User{
+		ID:               int64(gofakeit.Number(1, 1000)),
+		Email:            gofakeit.Email(),
+		StripeCustomerID: gofakeit.UUID(),
+	}
+
+	t.Run("Error on invalid request params", func(t *testing.T) {
 		ik := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RequestParams:  []byte("{"),
 			RecoveryPoint:  idempotency.RecoveryPointStarted,
 		}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		m := getMocksWithTimes(0)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
-			Twice().
-			Return(nil)
+		err := uc.Complete(ctx, &ik)
 
-		// Get Idempotency Key
-		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
-			Once().
-			Return(ik, nil)
+		assert.Error(t, err)
+		m.uow.AssertNumberOfCalls(t, "Do", 0)
+	})
 
-		// Create Ride
-		retErr := errors.New("error createRide")
-		m.uow.On("Do", mock.Anything, mock.Anything).
-			Once().
-			Return(retErr)
+	t.Run("Error on out of range request params", func(t *testing.T) {
+		for _, params := range []string{
+			`{"origin_lat": 91, "origin_lon": 0, "target_lat": 0, "target_lon": 0}`,
+			`{"origin_lat": 0, "origin_lon": 0, "target_lat": 0, "target_lon": -180.5}`,
+			`{"origin_lat": 0, "origin_lon": 0, "target_lat": 0}`,
+		} {
+			ik := entity.IdempotencyKey{
+				ID:             int64(gofakeit.Number(1, 1000)),
+				IdempotencyKey: gofakeit.UUID(),
+				UserID:         user.ID,
+				User:           user,
+				RequestParams:  []byte(params),
+				RecoveryPoint:  idempotency.RecoveryPointStarted,
+			}
 
-		err := uc.Create(ctx, &ik, &entity.Ride{})
+			m := getMocksWithTimes(0)
+			uc := ride{
+				cfg:      mockCfg,
+				idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+				pricer:   pricing.New(mockCfg),
+				payments: payment.NewFake(),
+				jobs:     NewJobs(JobHandlers{}),
+			}
 
-		assert.Equal(t, retErr, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+			err := uc.Complete(ctx, &ik)
+
+			assert.ErrorIs(t, err, errInvalidRideParams, params)
+			m.uow.AssertNumberOfCalls(t, "Do", 0)
+		}
 	})
 
-	t.Run("Error on createCharge", func(t *testing.T) {
-		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+	t.Run("Only coordinates taken from request params", func(t *testing.T) {
 		ik := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
-			RequestParams:  jsonRide,
-			RecoveryPoint:  idempotency.RecoveryPointCreated,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RequestParams: []byte(`{"id": 42, "origin_lat": 1.5, "origin_lon": -2.5, "target_lat": 3.5,` +
+				` "target_lon": -4.5, "amount": 1, "user_id": 7, "stripe_charge_id": "ch_123",` +
+				` "canceled_at": "2022-01-01T00:00:00Z", "stripe_refund_id": "re_123"}`),
+			RecoveryPoint: idempotency.RecoveryPointStarted,
 		}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
-
-		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
-			Twice().
-			Return(nil)
+		m := getMocksWithTimes(2)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		// Get Idempotency Key
 		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
 			Once().
 			Return(ik, nil)
 
-		// Create Charge
-		retErr := errors.New("error createCharge")
-		m.uow.On("Do", mock.Anything, mock.Anything).
+		// locked and then unlocked once saving the ride fails
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		var saved entity.Ride
+		retErr := errors.New("err Save")
+		m.ride.On("Save", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
+			Run(func(args mock.Arguments) { saved = *args.Get(1).(*entity.Ride) }).
 			Return(retErr)
 
-		err := uc.Create(ctx, &ik, &entity.Ride{})
-
-		assert.Equal(t, retErr, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		err := uc.Complete(ctx, &ik)
+
+		assert.ErrorIs(t, err, retErr)
+		assert.Equal(t, entity.Ride{
+			IdempotencyKeyID: &ik.ID,
+			OriginLat:        1.5,
+			OriginLon:        -2.5,
+			TargetLat:        3.5,
+			TargetLon:        -4.5,
+			Amount:           saved.Amount,
+			Currency:         mockCfg.RideCurrency,
+			UserID:           user.ID,
+		}, saved)
+		assert.NotEqual(t, int64(1), saved.Amount)
 	})
 
-	t.Run("Error on sendReceipt", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		userID := int64(gofakeit.Number(1, 1000))
+	t.Run("Request in progress", func(t *testing.T) {
+		now := time.Now().UTC()
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
-			UserID:         userID,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
 			RequestParams:  jsonRide,
-			RecoveryPoint:  idempotency.RecoveryPointCharged,
+			RecoveryPoint:  idempotency.RecoveryPointCreated,
 		}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		retIK := ik
+		retIK.LockedAt = &now
 
-		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
-			Twice().
-			Return(nil)
+		m := getMocks()
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		// Get Idempotency Key
 		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
 			Once().
-			Return(ik, nil)
-
-		// Send Receipt
-		retErr := errors.New("error sendReceipt")
-		m.uow.On("Do", mock.Anything, mock.Anything).
-			Once().
-			Return(retErr)
+			Return(retIK, nil)
 
-		err := uc.Create(ctx, &ik, &entity.Ride{})
+		err := uc.Complete(ctx, &ik)
 
-		assert.Equal(t, retErr, err)
+		assert.Equal(t, entity.ErrIdemKeyRequestInProgress, err)
 		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
 	})
 
 	t.Run("No-op on finished recovery point", func(t *testing.T) {
-		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
 		ik := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
 			RequestParams:  jsonRide,
-			RecoveryPoint:  idempotency.RecoveryPointFinished,
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
 		}
 
+		// the request got finished in the meantime
+		retIK := ik
+		retIK.RecoveryPoint = idempotency.RecoveryPointFinished
+
 		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		// Get Idempotency Key
 		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
 			Once().
-			Return(ik, nil)
+			Return(retIK, nil)
 
-		err := uc.Create(ctx, &ik, &entity.Ride{})
+		err := uc.Complete(ctx, &ik)
 
 		assert.NoError(t, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 0)
 	})
 
-	t.Run("Error on unknown recovery point", func(t *testing.T) {
-		key := gofakeit.UUID()
-		userID := int64(gofakeit.Number(1, 1000))
+	t.Run("Success on Complete", func(t *testing.T) {
 		ik := entity.IdempotencyKey{
-			IdempotencyKey: key,
-			UserID:         userID,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
 			RequestParams:  jsonRide,
-			RecoveryPoint:  "unknown",
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
 		}
 
-		m := getMocks()
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		m := getMocksWithTimes(2)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
 		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
 			Once().
 			Return(ik, nil)
 
 		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		// Send Receipt
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
 			Return(nil)
 
-		err := uc.Create(ctx, &ik, &entity.Ride{})
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: int64(gofakeit.Number(1, 1000)), Currency: "usd"}, nil)
 
-		assert.Equal(t, entity.ErrIdemKeyUnknownRecoveryPoint, err)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 1)
+		audits := expectAudits(m)
+
+		err := uc.Complete(ctx, &ik)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Nil(t, ik.LockedAt)
+		assert.Equal(t, user, ik.User)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
+		assert.Equal(t, []audit.Action{audit.ActionStageReceipt}, auditedActions(*audits))
 	})
 
-	t.Run("Success on Create", func(t *testing.T) {
-		key := gofakeit.UUID()
-		keyID := int64(gofakeit.Number(1, 1000))
-		user := &entity.User{
-			ID:               gofakeit.Int64(),
-			Email:            gofakeit.Email(),
-			StripeCustomerID: gofakeit.UUID(),
-		}
+	t.Run("Success on Complete after lock timeout", func(t *testing.T) {
+		stale := time.Now().UTC().Add(-1 * time.Hour)
 		ik := entity.IdempotencyKey{
-			ID:             keyID,
-			IdempotencyKey: key,
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
 			UserID:         user.ID,
 			User:           user,
 			RequestParams:  jsonRide,
-			RecoveryPoint:  idempotency.RecoveryPointStarted,
+			RecoveryPoint:  idempotency.RecoveryPointCharged,
 		}
 
-		rd := &entity.Ride{StripeChargeID: new(string)}
+		// the request was abandoned while holding the lock
+		retIK := ik
+		retIK.LockedAt = &stale
+
+		m := getMocksWithTimes(2)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
 
-		m := getMocksWithTimes(0)
-		uc := ride{cfg: mockCfg, uow: m.uow, iks: m.idemKey}
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(retIK, nil)
 
 		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
-			Times(4).
+			Twice().
 			Return(nil)
 
-		// Get Idempotency Key
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil)
+
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: int64(gofakeit.Number(1, 1000)), Currency: "usd"}, nil)
+
+		audits := expectAudits(m)
+
+		err := uc.Complete(ctx, &ik)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(
+			t,
+			[]audit.Action{audit.ActionIdemKeyLockStolen, audit.ActionStageReceipt},
+			auditedActions(*audits),
+		)
+		if assert.Len(t, *audits, 2) {
+			assert.Equal(t, audit.ResourceTypeIdempotencyKey, (*audits)[0].ResourceType)
+			assert.Equal(t, ik.ID, (*audits)[0].ResourceID)
+		}
+	})
+
+	t.Run("Success on Complete cancellation", func(t *testing.T) {
+		rideID := int64(gofakeit.Number(1, 1000))
+		ik := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         user.ID,
+			User:           user,
+			RequestMethod:  "POST",
+			RequestPath:    fmt.Sprintf("/rides/%v/cancel", rideID),
+			RecoveryPoint:  idempotency.RecoveryPointRefunded,
+		}
+
+		m := getMocksWithTimes(2)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			pricer:   pricing.New(mockCfg),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
+
 		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
 			Once().
 			Return(ik, nil)
 
-		// Create Ride
-		m.ride.On("Save", ctx, rd).
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		// Send Cancellation Notice
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, Currency: "usd", UserID: user.ID}, nil)
+
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
 			Return(nil)
 
+		audits := expectAudits(m)
+
+		err := uc.Complete(ctx, &ik)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
+		assert.Equal(t, []audit.Action{audit.ActionStageCancellationNotice}, auditedActions(*audits))
+	})
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	userID := int64(gofakeit.Number(1, 1000))
+	rideID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Ride not found", func(t *testing.T) {
+		m := getMocks()
+		uc := ride{rides: m.ride}
+
+		// someone else's ride looks just like a missing one
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{}, data.ErrRecordNotFound)
+
+		_, err := uc.Get(ctx, userID, rideID)
+		assert.ErrorIs(t, err, entity.ErrNotFound)
+		m.ride.AssertExpectations(t)
+	})
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		m := getMocks()
+		uc := ride{rides: m.ride}
+		expErr := errors.New("error FindOne")
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{}, expErr)
+
+		_, err := uc.Get(ctx, userID, rideID)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Success on Get", func(t *testing.T) {
+		m := getMocks()
+		uc := ride{rides: m.ride}
+		rd := entity.Ride{ID: rideID, UserID: userID}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(rd, nil)
+
+		res, err := uc.Get(ctx, userID, rideID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, rd, res)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Error on FindPage", func(t *testing.T) {
+		m := getMocks()
+		uc := ride{rides: m.ride}
+		expErr := errors.New("error FindPage")
+
+		m.ride.On("FindPage", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(data.Page[entity.Ride]{}, expErr)
+
+		_, err := uc.List(ctx, userID, "", 2)
+		assert.Equal(t, expErr, err)
+	})
+
+	t.Run("Success on List", func(t *testing.T) {
+		m := getMocks()
+		uc := ride{rides: m.ride}
+		page := data.Page[entity.Ride]{Rows: []entity.Ride{{ID: 3}, {ID: 2}}, NextCursor: "next"}
+
+		// rides are paged through newest first
+		pr := data.PageRequest{Cursor: "cursor", Limit: 2, OrderBy: []data.Order{data.Desc("id")}}
+		m.ride.On("FindPage", ctx, pr, mock.Anything).
+			Once().
+			Return(page, nil)
+
+		res, err := uc.List(ctx, userID, "cursor", 2)
+		if assert.NoError(t, err) {
+			assert.Equal(t, page, res)
+		}
+		m.ride.AssertExpectations(t)
+	})
+}
+
+func TestCanceledRideID(t *testing.T) {
+	tests := []struct {
+		path string
+		id   int64
+		ok   bool
+	}{
+		{path: "/rides/12/cancel", id: 12, ok: true},
+		{path: "/rides/12/cancel?foo=bar", id: 12, ok: true},
+		{path: "/", ok: false},
+		{path: "/rides/12", ok: false},
+		{path: "/rides/foo/cancel", ok: false},
+		{path: "/rides/99999999999999999999/cancel", ok: false},
+	}
+
+	for _, tc := range tests {
+		id, ok := canceledRideID(tc.path)
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.id, id, tc.path)
+	}
+}
+
+func TestRefundRide(t *testing.T) {
+	ctx := originip.NewContext(context.Background(), &originip.OriginIP{IP: gofakeit.IPv4Address()})
+
+	chargeID := gofakeit.UUID()
+	rideID := int64(gofakeit.Number(1, 1000))
+	userID := int64(gofakeit.Number(1, 1000))
+	ik := entity.IdempotencyKey{
+		ID:             int64(gofakeit.Number(1, 1000)),
+		IdempotencyKey: gofakeit.UUID(),
+		UserID:         userID,
+		RecoveryPoint:  idempotency.RecoveryPointCancelStarted,
+	}
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		uc := ride{}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{}, retErr)
+
+		_, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Definitive errors", func(t *testing.T) {
+		now := time.Now().UTC()
+		tests := []struct {
+			desc string
+			ride entity.Ride
+			err  error
+			code idempotency.ResponseCode
+			msg  string
+		}{
+			{
+				desc: "ride not found",
+				err:  data.ErrRecordNotFound,
+				code: idempotency.ResponseCodeNotFound,
+				msg:  entity.ErrNotFound.Error(),
+			},
+			{
+				desc: "ride already canceled",
+				ride: entity.Ride{ID: rideID, StripeChargeID: &chargeID, CanceledAt: &now},
+				code: idempotency.ResponseCodeConflict,
+				msg:  entity.ErrRideAlreadyCanceled.Error(),
+			},
+			{
+				desc: "ride not charged",
+				ride: entity.Ride{ID: rideID},
+				code: idempotency.ResponseCodeConflict,
+				msg:  entity.ErrRideNotCharged.Error(),
+			},
+		}
+
+		for _, tc := range tests {
+			ik := ik
+
+			m := getMocks()
+			uc := ride{}
+
+			m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+				Once().
+				Return(tc.ride, tc.err)
+
+			audits := expectAudits(m)
+
+			res, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+			require.NoError(t, err, tc.desc)
+			require.NoError(t, res(&ik), tc.desc)
+
+			assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint, tc.desc)
+			assert.Equal(t, tc.code, *ik.ResponseCode, tc.desc)
+			assert.JSONEq(t, fmt.Sprintf(`{"message": %q}`, tc.msg), string(ik.ResponseBody), tc.desc)
+			m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+			// rejections are audited against the ride asked for
+			if assert.Len(t, *audits, 1, tc.desc) {
+				assert.Equal(t, audit.ActionCancelRejected, (*audits)[0].Action, tc.desc)
+				assert.Equal(t, rideID, (*audits)[0].ResourceID, tc.desc)
+				assert.Equal(t, map[string]interface{}{"error": tc.msg}, transition(t, (*audits)[0]).Details, tc.desc)
+			}
+		}
+	})
+
+	t.Run("Payment error", func(t *testing.T) {
+		ik := ik
+
+		m := getMocks()
+		payments := payment.NewFake()
+		payments.Err = payment.NewGenericError("charge_already_refunded", "charge has already been refunded")
+		uc := ride{payments: payments}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, StripeChargeID: &chargeID}, nil)
+
+		audits := expectAudits(m)
+
+		res, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		// errors coming from the processor are definitive and finish the request
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeErrPaymentGeneric, *ik.ResponseCode)
+		assert.JSONEq(t, `{"message": "generic error from payment processor"}`, string(ik.ResponseBody))
+		assert.Equal(t, []audit.Action{audit.ActionRefundFailed}, auditedActions(*audits))
+	})
+
+	t.Run("Payment unknown error", func(t *testing.T) {
+		ik := ik
+
+		m := getMocks()
+		payments := payment.NewFake()
+		payments.Err = errors.New("connection refused")
+		uc := ride{payments: payments}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, StripeChargeID: &chargeID}, nil)
+
+		_, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+
+		assert.Error(t, err)
+		m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on UpdateRide", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err UpdateRide")
+
+		m := getMocks()
+		uc := ride{payments: payment.NewFake()}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, StripeChargeID: &chargeID}, nil)
+
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Success on refundRide", func(t *testing.T) {
+		ik := ik
+
+		m := getMocks()
+		payments := payment.NewFake()
+		uc := ride{payments: payments}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, StripeChargeID: &chargeID}, nil)
+
+		var updated entity.Ride
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				updated = *args.Get(1).(*entity.Ride)
+			})
+
+		var ar entity.AuditRecord
 		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
 			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				ar = *args.Get(1).(*entity.AuditRecord)
+			})
+
+		res, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		// the refund is keyed by the ride, so that it's only ever made once
+		assert.Equal(t, []payment.RefundParams{{
+			IdempotencyKey: fmt.Sprintf("go-rocket-ride-refund-%v", rideID),
+			Charge:         chargeID,
+		}}, payments.Refunds())
+
+		assert.Equal(t, idempotency.RecoveryPointRefunded, ik.RecoveryPoint)
+		assert.NotNil(t, updated.CanceledAt)
+		if assert.NotNil(t, updated.StripeRefundID) {
+			assert.Equal(t, "re_fake_1", *updated.StripeRefundID)
+		}
+		assert.Equal(t, audit.ActionCancelRide, ar.Action)
+		assert.Equal(t, audit.ResourceTypeRide, ar.ResourceType)
+		assert.Equal(t, rideID, ar.ResourceID)
+		assert.Equal(t, userID, ar.UserID)
+		assert.JSONEq(t, fmt.Sprintf(`{
+			"idempotency_key_id": %v,
+			"recovery_point_before": "CANCEL_STARTED",
+			"recovery_point_after": "REFUNDED",
+			"details": {"stripe_refund_id": "re_fake_1"}
+		}`, ik.ID), string(ar.Data))
+	})
+
+	t.Run("Refund recorded by its webhook first", func(t *testing.T) {
+		ik := ik
+		now := time.Now().UTC()
+
+		m := getMocks()
+		payments := payment.NewFake()
+		uc := ride{payments: payments}
+
+		// the phase made the refund, though it got retried and the refund's
+		// webhook canceled the ride in the meantime
+		ride := entity.Ride{ID: rideID, StripeChargeID: &chargeID}
+		re, err := payments.Refund(ctx, refundParams(ride))
+		require.NoError(t, err)
+		ride.CanceledAt = &now
+		ride.StripeRefundID = &re.ID
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(ride, nil)
+
+		m.audit.On("Count", ctx, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(0, nil)
+
+		audits := expectAudits(m)
+
+		res, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		// the cancellation goes on, with no other refund made
+		assert.Equal(t, idempotency.RecoveryPointRefunded, ik.RecoveryPoint)
+		assert.Nil(t, ik.ResponseCode)
+		assert.Len(t, payments.Refunds(), 1)
+		m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		if assert.Len(t, *audits, 1) {
+			assert.Equal(t, audit.ActionCancelRide, (*audits)[0].Action)
+			assert.Equal(t, map[string]interface{}{"stripe_refund_id": re.ID}, transition(t, (*audits)[0]).Details)
+		}
+	})
+
+	t.Run("Ride canceled by someone else", func(t *testing.T) {
+		now := time.Now().UTC()
+		refundID := "re_dashboard"
+		tests := []struct {
+			desc      string
+			cancelled int
+		}{
+			// e.g. refunded through Stripe's dashboard
+			{desc: "refunded elsewhere", cancelled: 0},
+			{desc: "canceled by another request", cancelled: 1},
+		}
+
+		for _, tc := range tests {
+			ik := ik
+
+			m := getMocks()
+			uc := ride{payments: payment.NewFake()}
+
+			m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+				Once().
+				Return(entity.Ride{
+					ID:             rideID,
+					StripeChargeID: &chargeID,
+					StripeRefundID: &refundID,
+					CanceledAt:     &now,
+				}, nil)
+
+			m.audit.On("Count", ctx, mock.Anything, mock.Anything, mock.Anything).
+				Once().
+				Return(tc.cancelled, nil)
+
+			audits := expectAudits(m)
+
+			res, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+			require.NoError(t, err, tc.desc)
+			require.NoError(t, res(&ik), tc.desc)
+
+			assert.Equal(t, idempotency.ResponseCodeConflict, *ik.ResponseCode, tc.desc)
+			assert.JSONEq(t, fmt.Sprintf(`{"message": %q}`, entity.ErrRideAlreadyCanceled), string(ik.ResponseBody), tc.desc)
+			if assert.Len(t, *audits, 1, tc.desc) {
+				assert.Equal(t, audit.ActionCancelRejected, (*audits)[0].Action, tc.desc)
+			}
+		}
+	})
+
+	t.Run("Error on Count", func(t *testing.T) {
+		ik := ik
+		now := time.Now().UTC()
+		refundID := "re_123"
+		retErr := errors.New("err Count")
+
+		m := getMocks()
+		uc := ride{payments: payment.NewFake()}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, StripeChargeID: &chargeID, StripeRefundID: &refundID, CanceledAt: &now}, nil)
+
+		m.audit.On("Count", ctx, mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(0, retErr)
+
+		_, err := uc.refundRide(ctx, m.uows, &ik, rideID)
+
+		assert.Equal(t, retErr, err)
+	})
+}
+
+func TestSendCancellationNotice(t *testing.T) {
+	ctx := context.Background()
+
+	rideID := int64(gofakeit.Number(1, 1000))
+	userID := int64(gofakeit.Number(1, 1000))
+	ik := entity.IdempotencyKey{
+		ID:             int64(gofakeit.Number(1, 1000)),
+		IdempotencyKey: gofakeit.UUID(),
+		UserID:         userID,
+		RecoveryPoint:  idempotency.RecoveryPointRefunded,
+	}
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		uc := ride{}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{}, retErr)
+
+		_, err := uc.sendCancellationNotice(ctx, m.uows, &ik, rideID)
+
+		assert.Equal(t, retErr, err)
+		m.job.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on CreateStagedJob", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err CreateStagedJob")
+
+		m := getMocks()
+		uc := ride{jobs: NewJobs(JobHandlers{})}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID, Currency: "usd"}, nil)
+
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.sendCancellationNotice(ctx, m.uows, &ik, rideID)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Error on invalid job args", func(t *testing.T) {
+		ik := ik
+
+		m := getMocks()
+		uc := ride{jobs: NewJobs(JobHandlers{})}
+
+		// a ride without currency makes for a notice that can't be sent
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(entity.Ride{ID: rideID}, nil)
+
+		_, err := uc.sendCancellationNotice(ctx, m.uows, &ik, rideID)
+
+		assert.ErrorIs(t, err, jobs.ErrInvalidJobArgs)
+		m.job.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success on sendCancellationNotice", func(t *testing.T) {
+		ik := ik
+		now := time.Now().UTC()
+		rd := entity.Ride{ID: rideID, Amount: 4321, Currency: "usd", CanceledAt: &now, UserID: userID}
+
+		m := getMocks()
+		uc := ride{jobs: NewJobs(JobHandlers{})}
+
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(rd, nil)
+
+		var sj entity.StagedJob
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				sj = *args.Get(1).(*entity.StagedJob)
+			})
+
+		audits := expectAudits(m)
+
+		res, err := uc.sendCancellationNotice(ctx, m.uows, &ik, rideID)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		assert.Equal(t, stagedjob.JobNameSendCancellationNotice, sj.JobName)
+		assert.JSONEq(
+			t,
+			fmt.Sprintf(`{"amount": 4321, "currency": "usd", "ride_id": %v, "user_id": %v}`, rideID, userID),
+			string(sj.JobArgs),
+		)
+
+		expBody, err := json.Marshal(rd)
+		require.NoError(t, err)
+
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.JSONEq(t, string(expBody), string(ik.ResponseBody))
+
+		if assert.Len(t, *audits, 1) {
+			tr := transition(t, (*audits)[0])
+			assert.Equal(t, audit.ActionStageCancellationNotice, (*audits)[0].Action)
+			assert.Equal(t, idempotency.RecoveryPointRefunded, tr.RecoveryPointBefore)
+			assert.Equal(t, idempotency.RecoveryPointFinished, tr.RecoveryPointAfter)
+		}
+	})
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := testConfig()
+
+	chargeID := gofakeit.UUID()
+	rideID := int64(gofakeit.Number(1, 1000))
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Success on Cancel", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			UserID:         userID,
+			RequestMethod:  "POST",
+			RequestPath:    fmt.Sprintf("/rides/%v/cancel", rideID),
+		}
+
+		m := getMocksWithTimes(3)
+		uc := ride{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
+
+		// Create Idempotency Key, starting off at the cancellation's first phase
+		m.idemKey.On("SaveOrGet", ctx, mock.MatchedBy(func(ik *entity.IdempotencyKey) bool {
+			return ik.RecoveryPoint == idempotency.RecoveryPointCancelStarted
+		}), mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil)
+
+		// one update for each phase
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
 			Return(nil)
 
-		// Create Charge
-		gock.New(stripeURL).
-			Post("/v1/charges").
-			Reply(200).
-			JSON(map[string]string{"foo": "bar"})
+		// Refund Ride
+		m.ride.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Twice().
+			Return(entity.Ride{ID: rideID, Currency: "usd", StripeChargeID: &chargeID, UserID: userID}, nil)
 
-		m.ride.On("Update", ctx, rd).
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
 			Once().
 			Return(nil)
 
-		// Send Receipt
+		audits := expectAudits(m)
+
+		// Send Cancellation Notice
 		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
 			Once().
 			Return(nil)
 
-		err := uc.Create(ctx, &ik, rd)
+		err := uc.Cancel(ctx, &ik, rideID)
 
 		assert.NoError(t, err)
-		m.idemKey.AssertNumberOfCalls(t, "Update", 4)
-		m.idemKey.AssertNumberOfCalls(t, "FindOne", 1)
-		m.ride.AssertNumberOfCalls(t, "Save", 1)
-		m.audit.AssertNumberOfCalls(t, "Save", 1)
-		m.ride.AssertNumberOfCalls(t, "Update", 1)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.Nil(t, ik.LockedAt)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 2)
 		m.job.AssertNumberOfCalls(t, "Save", 1)
+		assert.Equal(
+			t,
+			[]audit.Action{audit.ActionCancelRide, audit.ActionStageCancellationNotice},
+			auditedActions(*audits),
+		)
 	})
 }
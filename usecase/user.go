@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/config"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+)
+
+// SignupPath is where users sign up, which is how abandoned signups are told
+// apart from other requests.
+const SignupPath = "/users"
+
+type user struct {
+	cfg      config.Config
+	idem     idemkey.Runner
+	payments payment.Provider
+	jobs     *jobs.Registry
+}
+
+type User interface {
+	Create(context.Context, *entity.IdempotencyKey, *entity.User) error
+	Complete(context.Context, *entity.IdempotencyKey) error
+}
+
+func NewUser(cfg config.Config, idem idemkey.Runner, payments payment.Provider, jobs *jobs.Registry) User {
+	return &user{
+		cfg:      cfg,
+		idem:     idem,
+		payments: payments,
+		jobs:     jobs,
+	}
+}
+
+// Create signs a new user up. The idempotency key isn't sent by any user, so
+// its UserID must be left unset.
+func (u *user) Create(ctx context.Context, ik *entity.IdempotencyKey, usr *entity.User) error {
+	return u.idem.Run(ctx, ik, u.phases(usr.Email)...)
+}
+
+// Complete pushes an abandoned signup through to the end, picking up from its
+// last recovery point and relying on the request params stored along with the
+// idempotency key.
+func (u *user) Complete(ctx context.Context, ik *entity.IdempotencyKey) error {
+	usr := &entity.User{}
+	if err := json.Unmarshal(ik.RequestParams, usr); err != nil {
+		return err
+	}
+
+	return u.idem.Resume(ctx, ik, u.phases(usr.Email)...)
+}
+
+// phases lists the atomic phases a user goes through, from its creation until
+// the welcome email is sent. Users are looked up by their email, which is
+// unique, in every phase after the first one.
+func (u *user) phases(email string) []idemkey.Phase {
+	email = strings.ToLower(email)
+
+	return []idemkey.Phase{
+		{
+			RecoveryPoint: idempotency.RecoveryPointSignupStarted,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, ik *entity.IdempotencyKey) (idemkey.Result, error) {
+				return u.createUser(ctx, uows, ik, email)
+			},
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointUserCreated,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, _ *entity.IdempotencyKey) (idemkey.Result, error) {
+				return u.createCustomer(ctx, uows, email)
+			},
+		},
+		{
+			RecoveryPoint: idempotency.RecoveryPointCustomerCreated,
+			Run: func(ctx context.Context, uows uow.UnitOfWorkStore, _ *entity.IdempotencyKey) (idemkey.Result, error) {
+				return u.sendWelcome(ctx, uows, email)
+			},
+		},
+	}
+}
+
+func (u *user) createUser(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	ik *entity.IdempotencyKey,
+	email string,
+) (idemkey.Result, error) {
+	oip := originip.FromCtx(ctx)
+
+	_, err := uows.Users().FindOne(ctx, datastore.UserWithEmail(email))
+	if err == nil {
+		return idemkey.Response(
+			idempotency.ResponseCodeConflict,
+			idempotency.Message{Message: entity.ErrEmailTaken.Error()},
+			nil,
+		), nil
+	}
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	usr := &entity.User{Email: email}
+	err = uows.Users().Save(ctx, usr)
+	if err != nil {
+		return nil, err
+	}
+
+	// in the same transaction insert an audit record for what happened
+	ar := &entity.AuditRecord{
+		Action:       audit.ActionCreateUser,
+		CreatedAt:    time.Now().UTC(),
+		Data:         ik.RequestParams,
+		OriginIP:     oip.IP,
+		ResourceID:   usr.ID,
+		ResourceType: audit.ResourceTypeUser,
+		UserID:       usr.ID,
+	}
+	err = uows.AuditRecords().Save(ctx, ar)
+	if err != nil {
+		return nil, err
+	}
+
+	return idemkey.RecoveryPoint(idempotency.RecoveryPointUserCreated), nil
+}
+
+func (u *user) createCustomer(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	email string,
+) (idemkey.Result, error) {
+	usr, err := uows.Users().FindOne(ctx, datastore.UserWithEmail(email))
+	if err != nil {
+		return nil, err
+	}
+
+	// Derived from the user rather than from the idempotency key, so that no
+	// user ever ends up with more than one customer.
+	c, err := u.payments.CreateCustomer(ctx, payment.CustomerParams{
+		IdempotencyKey: fmt.Sprintf("go-rocket-ride-customer-%v", usr.ID),
+		Email:          usr.Email,
+	})
+	if err != nil {
+		// Unlike charges, there's no going back once the user is created, so
+		// every error leaves the request to be retried, either by the client
+		// or by the completer.
+		log.Errorf("payment error: %v", err)
+		if errors.Is(err, entity.ErrPaymentProviderGeneric) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", entity.ErrPaymentProviderGeneric, err)
+	}
+
+	usr.StripeCustomerID = c.ID
+	log.Debugf("customer id: %v", c.ID)
+	err = uows.Users().Update(ctx, &usr)
+	if err != nil {
+		return nil, err
+	}
+
+	return idemkey.RecoveryPoint(idempotency.RecoveryPointCustomerCreated), nil
+}
+
+func (u *user) sendWelcome(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	email string,
+) (idemkey.Result, error) {
+	usr, err := uows.Users().FindOne(ctx, datastore.UserWithEmail(email))
+	if err != nil {
+		return nil, err
+	}
+
+	// just like receipts, welcome emails are staged in the same transaction
+	jobArgs := stagedjob.JobArgWelcome{
+		Email:  usr.Email,
+		UserID: usr.ID,
+	}
+
+	_, err = u.jobs.Stage(ctx, uows.StagedJobs(), stagedjob.JobNameSendWelcome, jobArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := idempotency.ResponseHeaders{
+		"Location": fmt.Sprintf("/users/%v", usr.ID),
+	}
+
+	return idemkey.Response(idempotency.ResponseCodeOK, usr, headers), nil
+}
@@ -0,0 +1,486 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/idempotency"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/stagedjob"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/idemkey"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/usecase/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUser(t *testing.T) {
+	oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
+	ctx := originip.NewContext(context.Background(), oip)
+
+	email := "jane@example.com"
+	ik := entity.IdempotencyKey{
+		IdempotencyKey: gofakeit.UUID(),
+		RequestParams:  json.RawMessage(`{"email":"Jane@Example.com"}`),
+		RecoveryPoint:  idempotency.RecoveryPointSignupStarted,
+	}
+
+	t.Run("Email already taken", func(t *testing.T) {
+		ik := ik
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: 1, Email: email}, nil)
+
+		res, err := uc.createUser(ctx, m.uows, &ik, email)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeConflict, *ik.ResponseCode)
+		assert.JSONEq(t, fmt.Sprintf(`{"message": %q}`, entity.ErrEmailTaken), string(ik.ResponseBody))
+		m.user.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, retErr)
+
+		_, err := uc.createUser(ctx, m.uows, &ik, email)
+
+		assert.Equal(t, retErr, err)
+		m.user.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on Save", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err Save")
+
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, data.ErrRecordNotFound)
+
+		m.user.On("Save", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.createUser(ctx, m.uows, &ik, email)
+
+		assert.Equal(t, retErr, err)
+		m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on CreateAuditRecord", func(t *testing.T) {
+		ik := ik
+		retErr := errors.New("err CreateAuditRecord")
+
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, data.ErrRecordNotFound)
+
+		m.user.On("Save", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil)
+
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.createUser(ctx, m.uows, &ik, email)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Success on createUser", func(t *testing.T) {
+		ik := ik
+		userID := int64(gofakeit.Number(1, 1000))
+
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, data.ErrRecordNotFound)
+
+		var usr entity.User
+		m.user.On("Save", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				u := args.Get(1).(*entity.User)
+				u.ID = userID
+				usr = *u
+			})
+
+		var ar entity.AuditRecord
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				ar = *args.Get(1).(*entity.AuditRecord)
+			})
+
+		res, err := uc.createUser(ctx, m.uows, &ik, email)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		assert.Equal(t, idempotency.RecoveryPointUserCreated, ik.RecoveryPoint)
+		assert.Equal(t, email, usr.Email)
+		assert.Empty(t, usr.StripeCustomerID)
+
+		assert.Equal(t, audit.ActionCreateUser, ar.Action)
+		assert.Equal(t, audit.ResourceTypeUser, ar.ResourceType)
+		assert.Equal(t, userID, ar.ResourceID)
+		assert.Equal(t, userID, ar.UserID)
+		assert.Equal(t, oip.IP, ar.OriginIP)
+		assert.Equal(t, ik.RequestParams, ar.Data)
+	})
+}
+
+func TestCreateCustomer(t *testing.T) {
+	ctx := context.Background()
+
+	email := "jane@example.com"
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		payments := payment.NewFake()
+		uc := user{payments: payments}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, retErr)
+
+		_, err := uc.createCustomer(ctx, m.uows, email)
+
+		assert.Equal(t, retErr, err)
+		assert.Empty(t, payments.Customers())
+	})
+
+	t.Run("Payment errors", func(t *testing.T) {
+		// there's no going back once the user is created, so any error is
+		// left to be retried
+		for _, retErr := range []error{
+			payment.NewCardError("card_declined", "card declined"),
+			payment.NewGenericError("", "system is down"),
+			errors.New("connection refused"),
+		} {
+			m := getMocks()
+			payments := payment.NewFake()
+			payments.Err = retErr
+			uc := user{payments: payments}
+
+			m.user.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.User{ID: userID, Email: email}, nil)
+
+			_, err := uc.createCustomer(ctx, m.uows, email)
+
+			assert.ErrorIs(t, err, entity.ErrPaymentProviderGeneric, retErr.Error())
+			m.user.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("Error on Update", func(t *testing.T) {
+		retErr := errors.New("err Update")
+
+		m := getMocks()
+		uc := user{payments: payment.NewFake()}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email}, nil)
+
+		m.user.On("Update", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.createCustomer(ctx, m.uows, email)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Success on createCustomer", func(t *testing.T) {
+		m := getMocks()
+		payments := payment.NewFake()
+		uc := user{payments: payments}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email}, nil)
+
+		var usr entity.User
+		m.user.On("Update", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				usr = *args.Get(1).(*entity.User)
+			})
+
+		ik := entity.IdempotencyKey{}
+		res, err := uc.createCustomer(ctx, m.uows, email)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		// the customer is keyed by the user, so that it's only ever created once
+		assert.Equal(t, []payment.CustomerParams{{
+			IdempotencyKey: fmt.Sprintf("go-rocket-ride-customer-%v", userID),
+			Email:          email,
+		}}, payments.Customers())
+
+		assert.Equal(t, idempotency.RecoveryPointCustomerCreated, ik.RecoveryPoint)
+		assert.Equal(t, "cus_fake_1", usr.StripeCustomerID)
+	})
+}
+
+func TestSendWelcome(t *testing.T) {
+	ctx := context.Background()
+
+	email := "jane@example.com"
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Error on FindOne", func(t *testing.T) {
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		uc := user{}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, retErr)
+
+		_, err := uc.sendWelcome(ctx, m.uows, email)
+
+		assert.Equal(t, retErr, err)
+		m.job.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on CreateStagedJob", func(t *testing.T) {
+		retErr := errors.New("err CreateStagedJob")
+
+		m := getMocks()
+		uc := user{jobs: NewJobs(JobHandlers{})}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email}, nil)
+
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(retErr)
+
+		_, err := uc.sendWelcome(ctx, m.uows, email)
+
+		assert.Equal(t, retErr, err)
+	})
+
+	t.Run("Error on invalid job args", func(t *testing.T) {
+		m := getMocks()
+		uc := user{jobs: NewJobs(JobHandlers{})}
+
+		// a user that's yet to be saved has no id to welcome
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{Email: email}, nil)
+
+		_, err := uc.sendWelcome(ctx, m.uows, email)
+
+		assert.ErrorIs(t, err, jobs.ErrInvalidJobArgs)
+		m.job.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success on sendWelcome", func(t *testing.T) {
+		usr := entity.User{ID: userID, Email: email, StripeCustomerID: "cus_123"}
+
+		m := getMocks()
+		uc := user{jobs: NewJobs(JobHandlers{})}
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(usr, nil)
+
+		var sj entity.StagedJob
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				sj = *args.Get(1).(*entity.StagedJob)
+			})
+
+		ik := entity.IdempotencyKey{}
+		res, err := uc.sendWelcome(ctx, m.uows, email)
+		require.NoError(t, err)
+		require.NoError(t, res(&ik))
+
+		assert.Equal(t, stagedjob.JobNameSendWelcome, sj.JobName)
+		assert.JSONEq(t, fmt.Sprintf(`{"email": %q, "user_id": %v}`, email, userID), string(sj.JobArgs))
+
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.JSONEq(
+			t,
+			fmt.Sprintf(`{"id": %v, "email": %q, "stripe_customer_id": "cus_123"}`, userID, email),
+			string(ik.ResponseBody),
+		)
+		assert.Equal(t, fmt.Sprintf("/users/%v", userID), ik.ResponseHeaders["Location"])
+	})
+}
+
+func TestSignup(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := testConfig()
+
+	email := "jane@example.com"
+	userID := int64(gofakeit.Number(1, 1000))
+
+	t.Run("Success on Create", func(t *testing.T) {
+		ik := entity.IdempotencyKey{
+			IdempotencyKey: gofakeit.UUID(),
+			RequestMethod:  "POST",
+			RequestPath:    SignupPath,
+			RequestParams:  json.RawMessage(fmt.Sprintf(`{"email":%q}`, email)),
+		}
+
+		m := getMocksWithTimes(4)
+		uc := user{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
+
+		// Create Idempotency Key, starting off at the signup's first phase
+		m.idemKey.On("SaveOrGet", ctx, mock.MatchedBy(func(ik *entity.IdempotencyKey) bool {
+			return ik.RecoveryPoint == idempotency.RecoveryPointSignupStarted && ik.UserID == 0
+		}), mock.Anything, mock.Anything, mock.Anything).
+			Once().
+			Return(true, nil)
+
+		// one update for each phase
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Times(3).
+			Return(nil)
+
+		// Create User
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{}, data.ErrRecordNotFound)
+
+		m.user.On("Save", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*entity.User).ID = userID
+			})
+
+		m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+			Once().
+			Return(nil)
+
+		// Create Customer
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email}, nil)
+
+		m.user.On("Update", ctx, mock.AnythingOfType("*entity.User")).
+			Once().
+			Return(nil)
+
+		// Send Welcome
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email, StripeCustomerID: "cus_123"}, nil)
+
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil)
+
+		err := uc.Create(ctx, &ik, &entity.User{Email: email})
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		assert.Nil(t, ik.LockedAt)
+		m.idemKey.AssertNumberOfCalls(t, "Update", 3)
+		m.audit.AssertNumberOfCalls(t, "Save", 1)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
+	})
+
+	t.Run("Success on Complete", func(t *testing.T) {
+		lockedAt := time.Now().Add(-time.Hour).UTC()
+		stored := entity.IdempotencyKey{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			IdempotencyKey: gofakeit.UUID(),
+			LockedAt:       &lockedAt,
+			RequestMethod:  "POST",
+			RequestPath:    SignupPath,
+			RequestParams:  json.RawMessage(fmt.Sprintf(`{"email":%q}`, email)),
+			RecoveryPoint:  idempotency.RecoveryPointCustomerCreated,
+		}
+		ik := stored
+
+		m := getMocksWithTimes(2)
+		uc := user{
+			cfg:      mockCfg,
+			idem:     idemkey.New(mockCfg, m.uow, m.idemKey),
+			payments: payment.NewFake(),
+			jobs:     NewJobs(JobHandlers{}),
+		}
+
+		m.idemKey.On("FindOne", ctx, mock.Anything, mock.Anything).
+			Once().
+			Return(stored, nil)
+
+		// one update for the lock and another one for the last phase
+		m.idemKey.On("Update", ctx, mock.AnythingOfType("*entity.IdempotencyKey")).
+			Twice().
+			Return(nil)
+
+		m.user.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.User{ID: userID, Email: email, StripeCustomerID: "cus_123"}, nil)
+
+		m.job.On("Save", ctx, mock.AnythingOfType("*entity.StagedJob")).
+			Once().
+			Return(nil)
+
+		err := uc.Complete(ctx, &ik)
+
+		assert.NoError(t, err)
+		assert.Equal(t, idempotency.RecoveryPointFinished, ik.RecoveryPoint)
+		assert.Equal(t, idempotency.ResponseCodeOK, *ik.ResponseCode)
+		m.user.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		m.job.AssertNumberOfCalls(t, "Save", 1)
+	})
+}
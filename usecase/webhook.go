@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/labstack/gommon/log"
+
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/datastore/uow"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+)
+
+type webhook struct {
+	uow uow.UnitOfWork
+}
+
+type Webhook interface {
+	HandleStripeEvent(context.Context, payment.Event) error
+}
+
+func NewWebhook(uow uow.UnitOfWork) Webhook {
+	return &webhook{uow: uow}
+}
+
+// HandleStripeEvent stores the event and acts upon it inside the same
+// transaction. Stripe delivers events at least once, so the ones already
+// stored are skipped, while failures make Stripe deliver them once again.
+// Events about charges that aren't of any ride are stored, but ignored.
+func (w *webhook) HandleStripeEvent(ctx context.Context, evt payment.Event) error {
+	return w.uow.Do(ctx, func(uows uow.UnitOfWorkStore) error {
+		_, err := uows.StripeEvents().FindOne(ctx, datastore.StripeEventWithEventID(evt.ID))
+		if err == nil {
+			log.Debugf("stripe event already processed: %v", evt.ID)
+			return nil
+		}
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+
+		se := &entity.StripeEvent{
+			StripeEventID: evt.ID,
+			Type:          evt.Type,
+			Data:          evt.Payload,
+		}
+		if err := uows.StripeEvents().Save(ctx, se); err != nil {
+			return err
+		}
+
+		switch evt.Type {
+		case payment.EventChargeRefunded:
+			return w.refundRide(ctx, uows, evt)
+		case payment.EventChargeDisputeCreated:
+			return w.disputeRide(ctx, uows, evt)
+		}
+		return nil
+	}, uow.WithIsolation(sql.LevelSerializable))
+}
+
+// refundRide records refunds made outside of rides' cancellations, such as
+// through Stripe's dashboard. Fully refunded rides are canceled as well.
+func (w *webhook) refundRide(ctx context.Context, uows uow.UnitOfWorkStore, evt payment.Event) error {
+	ch, err := evt.Charge()
+	if err != nil {
+		return err
+	}
+
+	ride, ok, err := rideWithCharge(ctx, uows, ch.ID)
+	if !ok || err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var changed bool
+
+	if ch.RefundID != "" && (ride.StripeRefundID == nil || *ride.StripeRefundID != ch.RefundID) {
+		ride.StripeRefundID = &ch.RefundID
+		changed = true
+	}
+	if ch.Refunded && ride.CanceledAt == nil {
+		ride.CanceledAt = &now
+		changed = true
+	}
+
+	// refunds made by canceling rides are already known
+	if !changed {
+		return nil
+	}
+
+	if err := uows.Rides().Update(ctx, &ride); err != nil {
+		return err
+	}
+
+	return saveWebhookAuditRecord(ctx, uows, audit.ActionRefundRide, ride, now, map[string]string{
+		"stripe_event_id":  evt.ID,
+		"stripe_refund_id": ch.RefundID,
+	})
+}
+
+// disputeRide records disputes opened by customers against rides' charges.
+func (w *webhook) disputeRide(ctx context.Context, uows uow.UnitOfWorkStore, evt payment.Event) error {
+	dp, err := evt.Dispute()
+	if err != nil {
+		return err
+	}
+
+	ride, ok, err := rideWithCharge(ctx, uows, dp.ChargeID)
+	if !ok || err != nil {
+		return err
+	}
+
+	if ride.StripeDisputeID != nil && *ride.StripeDisputeID == dp.ID {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	ride.DisputedAt = &now
+	ride.StripeDisputeID = &dp.ID
+	if err := uows.Rides().Update(ctx, &ride); err != nil {
+		return err
+	}
+
+	return saveWebhookAuditRecord(ctx, uows, audit.ActionDisputeRide, ride, now, map[string]string{
+		"stripe_event_id":   evt.ID,
+		"stripe_dispute_id": dp.ID,
+	})
+}
+
+// rideWithCharge finds the ride paid by the given charge, telling whether
+// there's one at all.
+func rideWithCharge(ctx context.Context, uows uow.UnitOfWorkStore, chargeID string) (entity.Ride, bool, error) {
+	if chargeID == "" {
+		return entity.Ride{}, false, nil
+	}
+
+	ride, err := uows.Rides().FindOne(ctx, datastore.RideWithStripeChargeID(chargeID))
+	if errors.Is(err, data.ErrRecordNotFound) {
+		log.Infof("no ride found for stripe charge: %v", chargeID)
+		return entity.Ride{}, false, nil
+	}
+	if err != nil {
+		return entity.Ride{}, false, err
+	}
+
+	return ride, true, nil
+}
+
+func saveWebhookAuditRecord(
+	ctx context.Context,
+	uows uow.UnitOfWorkStore,
+	action audit.Action,
+	ride entity.Ride,
+	now time.Time,
+	fields map[string]string,
+) error {
+	auditData, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	ar := &entity.AuditRecord{
+		Action:       action,
+		CreatedAt:    now,
+		Data:         auditData,
+		OriginIP:     originip.FromCtx(ctx).IP,
+		ResourceID:   ride.ID,
+		ResourceType: audit.ResourceTypeRide,
+		UserID:       ride.UserID,
+	}
+	return uows.AuditRecords().Save(ctx, ar)
+}
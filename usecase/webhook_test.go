@@ -0,0 +1,346 @@
+//go:build unit
+// +build unit
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/audit"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/entity/originip"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/payment"
+	"github.com/rafael-piovesan/go-rocket-ride/v2/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandleStripeEvent(t *testing.T) {
+	oip := &originip.OriginIP{IP: gofakeit.IPv4Address()}
+	ctx := originip.NewContext(context.Background(), oip)
+
+	newEvent := func(typ, object string) payment.Event {
+		return payment.Event{
+			ID:      "evt_" + gofakeit.LetterN(10),
+			Type:    typ,
+			Object:  json.RawMessage(object),
+			Payload: json.RawMessage(`{"id": "evt"}`),
+		}
+	}
+
+	newRide := func() entity.Ride {
+		chargeID := "ch_123"
+		return entity.Ride{
+			ID:             int64(gofakeit.Number(1, 1000)),
+			StripeChargeID: &chargeID,
+			UserID:         int64(gofakeit.Number(1, 1000)),
+		}
+	}
+
+	refunded := `{"id": "ch_123", "refunded": true, "refunds": {"data": [{"id": "re_123"}]}}`
+	partiallyRefunded := `{"id": "ch_123", "refunded": false, "refunds": {"data": [{"id": "re_123"}]}}`
+	disputed := `{"id": "dp_123", "charge": "ch_123"}`
+
+	t.Run("Error on FindOne event", func(t *testing.T) {
+		retErr := errors.New("err FindOne")
+
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{}, retErr)
+
+		err := uc.HandleStripeEvent(ctx, newEvent(payment.EventChargeRefunded, refunded))
+		assert.Equal(t, retErr, err)
+		m.event.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Duplicate event", func(t *testing.T) {
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		evt := newEvent(payment.EventChargeRefunded, refunded)
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{ID: 1, StripeEventID: evt.ID}, nil)
+
+		err := uc.HandleStripeEvent(ctx, evt)
+		assert.NoError(t, err)
+		m.event.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+		m.ride.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error on Save event", func(t *testing.T) {
+		retErr := errors.New("err Save")
+
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+		m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+			Once().
+			Return(retErr)
+
+		err := uc.HandleStripeEvent(ctx, newEvent(payment.EventChargeRefunded, refunded))
+		assert.Equal(t, retErr, err)
+		m.ride.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unhandled event type", func(t *testing.T) {
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		evt := newEvent("customer.created", `{"id": "cus_123"}`)
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+		m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+			Once().
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				se, ok := args.Get(1).(*entity.StripeEvent)
+				assert.True(t, ok)
+				assert.Equal(t, evt.ID, se.StripeEventID)
+				assert.Equal(t, evt.Type, se.Type)
+				assert.Equal(t, evt.Payload, se.Data)
+			})
+
+		err := uc.HandleStripeEvent(ctx, evt)
+		assert.NoError(t, err)
+		m.ride.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Charge of no ride", func(t *testing.T) {
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+		m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+			Once().
+			Return(nil)
+
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.Ride{}, data.ErrRecordNotFound)
+
+		err := uc.HandleStripeEvent(ctx, newEvent(payment.EventChargeRefunded, refunded))
+		assert.NoError(t, err)
+		m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid event object", func(t *testing.T) {
+		for _, typ := range []string{payment.EventChargeRefunded, payment.EventChargeDisputeCreated} {
+			m := getMocks()
+			uc := NewWebhook(m.uow)
+
+			m.event.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+			m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+				Once().
+				Return(nil)
+
+			err := uc.HandleStripeEvent(ctx, newEvent(typ, `[]`))
+			assert.Error(t, err, typ)
+			m.ride.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("Error on Update ride", func(t *testing.T) {
+		retErr := errors.New("err Update")
+
+		m := getMocks()
+		uc := NewWebhook(m.uow)
+
+		m.event.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+		m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+			Once().
+			Return(nil)
+
+		m.ride.On("FindOne", ctx, mock.Anything).
+			Once().
+			Return(newRide(), nil)
+
+		m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+			Once().
+			Return(retErr)
+
+		err := uc.HandleStripeEvent(ctx, newEvent(payment.EventChargeRefunded, refunded))
+		assert.Equal(t, retErr, err)
+		m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Refunds", func(t *testing.T) {
+		refundID := "re_123"
+		otherRefundID := "re_000"
+		canceledAt := gofakeit.Date()
+
+		tests := []struct {
+			desc     string
+			object   string
+			refundID *string
+			canceled bool
+			updated  bool
+			cancels  bool
+		}{
+			{desc: "full refund", object: refunded, updated: true, cancels: true},
+			{desc: "partial refund", object: partiallyRefunded, updated: true},
+			{desc: "another refund", object: refunded, refundID: &otherRefundID, updated: true, cancels: true},
+			{desc: "refund of canceled ride", object: refunded, refundID: &refundID, canceled: true},
+			{desc: "known partial refund", object: partiallyRefunded, refundID: &refundID},
+		}
+
+		for _, tc := range tests {
+			m := getMocks()
+			uc := NewWebhook(m.uow)
+
+			evt := newEvent(payment.EventChargeRefunded, tc.object)
+			rd := newRide()
+			rd.StripeRefundID = tc.refundID
+			if tc.canceled {
+				rd.CanceledAt = &canceledAt
+			}
+
+			m.event.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+			m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+				Once().
+				Return(nil)
+
+			m.ride.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(rd, nil)
+
+			m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+				Return(nil).
+				Run(func(args mock.Arguments) {
+					r, ok := args.Get(1).(*entity.Ride)
+					assert.True(t, ok, tc.desc)
+					if assert.NotNil(t, r.StripeRefundID, tc.desc) {
+						assert.Equal(t, refundID, *r.StripeRefundID, tc.desc)
+					}
+					assert.Equal(t, tc.cancels, r.CanceledAt != nil, tc.desc)
+				})
+
+			m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+				Return(nil).
+				Run(func(args mock.Arguments) {
+					ar, ok := args.Get(1).(*entity.AuditRecord)
+					assert.True(t, ok, tc.desc)
+					assert.Equal(t, audit.ActionRefundRide, ar.Action, tc.desc)
+					assert.Equal(t, oip.IP, ar.OriginIP, tc.desc)
+					assert.Equal(t, rd.ID, ar.ResourceID, tc.desc)
+					assert.Equal(t, audit.ResourceTypeRide, ar.ResourceType, tc.desc)
+					assert.Equal(t, rd.UserID, ar.UserID, tc.desc)
+					assert.JSONEq(
+						t,
+						`{"stripe_event_id": "`+evt.ID+`", "stripe_refund_id": "re_123"}`,
+						string(ar.Data),
+						tc.desc,
+					)
+				})
+
+			err := uc.HandleStripeEvent(ctx, evt)
+			assert.NoError(t, err, tc.desc)
+
+			if tc.updated {
+				m.ride.AssertNumberOfCalls(t, "Update", 1)
+				m.audit.AssertNumberOfCalls(t, "Save", 1)
+			} else {
+				m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+				m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			}
+		}
+	})
+
+	t.Run("Disputes", func(t *testing.T) {
+		disputeID := "dp_123"
+
+		tests := []struct {
+			desc      string
+			disputeID *string
+			updated   bool
+		}{
+			{desc: "new dispute", updated: true},
+			{desc: "known dispute", disputeID: &disputeID},
+		}
+
+		for _, tc := range tests {
+			m := getMocks()
+			uc := NewWebhook(m.uow)
+
+			evt := newEvent(payment.EventChargeDisputeCreated, disputed)
+			rd := newRide()
+			rd.StripeDisputeID = tc.disputeID
+
+			m.event.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(entity.StripeEvent{}, data.ErrRecordNotFound)
+
+			m.event.On("Save", ctx, mock.AnythingOfType("*entity.StripeEvent")).
+				Once().
+				Return(nil)
+
+			m.ride.On("FindOne", ctx, mock.Anything).
+				Once().
+				Return(rd, nil)
+
+			m.ride.On("Update", ctx, mock.AnythingOfType("*entity.Ride")).
+				Return(nil).
+				Run(func(args mock.Arguments) {
+					r, ok := args.Get(1).(*entity.Ride)
+					assert.True(t, ok, tc.desc)
+					assert.NotNil(t, r.DisputedAt, tc.desc)
+					if assert.NotNil(t, r.StripeDisputeID, tc.desc) {
+						assert.Equal(t, disputeID, *r.StripeDisputeID, tc.desc)
+					}
+				})
+
+			m.audit.On("Save", ctx, mock.AnythingOfType("*entity.AuditRecord")).
+				Return(nil).
+				Run(func(args mock.Arguments) {
+					ar, ok := args.Get(1).(*entity.AuditRecord)
+					assert.True(t, ok, tc.desc)
+					assert.Equal(t, audit.ActionDisputeRide, ar.Action, tc.desc)
+					assert.Equal(t, rd.ID, ar.ResourceID, tc.desc)
+					assert.Equal(t, rd.UserID, ar.UserID, tc.desc)
+					assert.JSONEq(
+						t,
+						`{"stripe_event_id": "`+evt.ID+`", "stripe_dispute_id": "dp_123"}`,
+						string(ar.Data),
+						tc.desc,
+					)
+				})
+
+			err := uc.HandleStripeEvent(ctx, evt)
+			assert.NoError(t, err, tc.desc)
+
+			if tc.updated {
+				m.ride.AssertNumberOfCalls(t, "Update", 1)
+				m.audit.AssertNumberOfCalls(t, "Save", 1)
+			} else {
+				m.ride.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+				m.audit.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			}
+		}
+	})
+}